@@ -0,0 +1,91 @@
+// Package api embeds the OpenAPI specification of the proddx API and checks
+// requests and responses against it.
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"io"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// Spec is the OpenAPI specification in YAML.
+//
+//go:embed swagger.yaml
+var Spec []byte
+
+// Validator checks requests and responses against the operations of Spec.
+// Requests for paths or methods missing from the spec are not checked, so
+// that the router can answer them with 404 or 405.
+type Validator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewValidator loads Spec and returns a Validator for it.
+func NewValidator() (*Validator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(Spec)
+	if err != nil {
+		return nil, err
+	}
+	// Operations are matched by path alone, whatever host serves the API.
+	doc.Servers = nil
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{
+		router: router,
+		options: &openapi3filter.Options{
+			// Credentials are checked by the handlers, which also know
+			// about the caller's roles.
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		},
+	}, nil
+}
+
+func (v *Validator) input(r *http.Request) *openapi3filter.RequestValidationInput {
+	route, params, err := v.router.FindRoute(r)
+	if err != nil {
+		return nil
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: params,
+		Route:      route,
+		Options:    v.options,
+	}
+}
+
+// ValidateRequest returns an error describing how r does not conform to the
+// spec. The body of r is read and replaced, so it can still be read by the
+// handler.
+func (v *Validator) ValidateRequest(r *http.Request) error {
+	input := v.input(r)
+	if input == nil {
+		return nil
+	}
+	return openapi3filter.ValidateRequest(r.Context(), input)
+}
+
+// ValidateResponse returns an error describing how the response to r does
+// not conform to the spec.
+func (v *Validator) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) error {
+	input := v.input(r)
+	if input == nil {
+		return nil
+	}
+	return openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options:                v.options,
+	})
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	v, err := NewValidator()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewBufferString(`{"rating": "five"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if err := v.ValidateRequest(r); err == nil {
+		t.Error("Error: Expected a review with a string rating to be rejected")
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/unknown", nil)
+	if err := v.ValidateRequest(r); err != nil {
+		t.Errorf("Error: Expected routes missing from the spec to be skipped: %s", err.Error())
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/v1/reviews/1", nil)
+	header := http.Header{"Content-Type": {"application/json"}}
+	if err := v.ValidateResponse(r, http.StatusOK, header, []byte(`{"id": "1", "rating": 4}`)); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+	if err := v.ValidateResponse(r, http.StatusOK, header, []byte(`{"id": "1", "rating": "4"}`)); err == nil {
+		t.Error("Error: Expected a review with a string rating to be reported")
+	}
+	if err := v.ValidateResponse(r, http.StatusTeapot, header, nil); err == nil {
+		t.Error("Error: Expected an undocumented status to be reported")
+	}
+}
@@ -10,9 +10,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	// WARNING!
 	// Change this to a fully-qualified import path
@@ -21,28 +27,251 @@ import (
 	//
 	//    sw "github.com/myname/myrepo/go"
 	//
+	"api.proddx.com/api"
+	"api.proddx.com/config"
+	"api.proddx.com/logging"
+	"api.proddx.com/mail"
+	"api.proddx.com/metrics"
+	"api.proddx.com/notifications"
+	"api.proddx.com/oidc"
+	"api.proddx.com/outbox"
+	"api.proddx.com/passwords"
 	sw "api.proddx.com/router"
+	"api.proddx.com/server"
 	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"api.proddx.com/tracing"
+	"api.proddx.com/webhooks"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
 )
 
+// oidcProviders configures the identity providers for single sign-on.
+func oidcProviders(cfg *config.Config) map[string]*oidc.Provider {
+	providers := map[string]*oidc.Provider{}
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  fmt.Sprintf("https://%s/v1/auth/oidc/%s/callback", cfg.URLs.API, p.Name),
+		})
+	}
+	return providers
+}
+
+// passwordSettings configures password hashing and the policy for new
+// passwords.
+func passwordSettings(cfg *config.Config) (*passwords.Hasher, *passwords.Policy, error) {
+	hasher, err := passwords.NewHasher(cfg.PasswordHashAlgorithm, cfg.BcryptCost)
+	if err != nil {
+		return nil, nil, err
+	}
+	policy := passwords.NewPolicy(cfg.PasswordMinLength)
+	if cfg.BreachedPasswordsFile != "" {
+		if err = policy.LoadBreached(cfg.BreachedPasswordsFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	return hasher, policy, nil
+}
+
+// eventSinks connects the sinks that review and product events are
+// published to, by the names their deliveries are queued under. close
+// disconnects them.
+func eventSinks(cfg *config.Config, logger *logging.Logger, publisher *webhooks.Publisher) (sinks map[string]outbox.Sink, close func(), err error) {
+	sinks, close = make(map[string]outbox.Sink), func() {}
+	for _, name := range cfg.EventSinks {
+		switch name {
+		case config.SinkWebhook:
+			sinks[name] = publisher
+		case config.SinkLog:
+			sinks[name] = outbox.NewLogSink(logger)
+		case config.SinkNATS:
+			conn, err := nats.Connect(cfg.NATSURL, nats.Name(tracing.ServiceName))
+			if err != nil {
+				return nil, nil, err
+			}
+			sinks[name] = outbox.NewNATSSink(conn, cfg.NATSSubjectPrefix)
+			close = conn.Close
+		}
+	}
+	return sinks, close, nil
+}
+
+// newMailer configures the delivery of account emails and notifications.
+func newMailer(cfg *config.Config, logger *logging.Logger) (mail.Mailer, error) {
+	switch cfg.Mailer {
+	case config.MailerSMTP:
+		return mail.NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case config.MailerFile:
+		return mail.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	}
+	return mail.NewLogMailer(logger, cfg.MailLogBodies), nil
+}
+
 func main() {
-	log.Printf("Server started")
+	cfg, err := config.Load(os.Args[1:], os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %s", err.Error())
+	}
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %s", err.Error())
+	}
+	if err := run(cfg, logger); err != nil {
+		logger.Error("Server error", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Server stopped")
+}
 
-	ctx := context.Background()
+// run serves the API until SIGTERM or SIGINT, then drains in-flight requests
+// and releases the database pool and tracer.
+func run(cfg *config.Config, logger *logging.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-	pool, err := pgxpool.Connect(ctx, os.Getenv("DATABASE_URL"))
+	tp, err := tracing.NewProvider(ctx, cfg.TracesExporter, os.Stdout)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %s", err.Error())
+		return fmt.Errorf("Failed to configure tracing: %w", err)
+	}
+	defer tp.Shutdown(context.Background())
+	otel.SetTracerProvider(tp)
+
+	pool, err := pgxpool.Connect(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to database: %w", err)
 	}
 	defer pool.Close()
 
 	userStore := &storage.UserDatabase{Pool: pool}
 	companyStore := &storage.CompanyDatabase{Pool: pool}
+	memberStore := &storage.MemberDatabase{Pool: pool}
+	challengeStore := &storage.ChallengeDatabase{Pool: pool}
+	apiKeyStore := &storage.APIKeyDatabase{Pool: pool}
 	productStore := &storage.ProductDatabase{Pool: pool}
 	reviewStore := &storage.ReviewDatabase{Pool: pool}
+	webhookStore := &storage.WebhookDatabase{Pool: pool}
+	deliveryStore := &storage.WebhookDeliveryDatabase{Pool: pool}
+	eventStore := &storage.EventDatabase{Pool: pool}
+	preferenceStore := &storage.NotificationPreferenceDatabase{Pool: pool}
+	channelStore := &storage.NotificationChannelDatabase{Pool: pool}
+	sentStore := &storage.SentNotificationDatabase{Pool: pool}
+
+	issuer, err := tokens.LoadIssuer(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+	if err != nil {
+		return fmt.Errorf("Failed to load token keys: %w", err)
+	}
+
+	hasher, policy, err := passwordSettings(cfg)
+	if err != nil {
+		return fmt.Errorf("Failed to configure passwords: %w", err)
+	}
+
+	mailer, err := newMailer(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("Failed to configure the mailer: %w", err)
+	}
+
+	m := metrics.New()
+	m.MustRegister(metrics.NewPoolCollector(func() metrics.PoolStats { return pool.Stat() }))
 
-	router := sw.New(userStore, companyStore, productStore, reviewStore)
+	opts := []sw.Option{
+		sw.WithLogger(logger),
+		sw.WithMetrics(m),
+		sw.WithTracerProvider(tp),
+		sw.WithURLs(cfg.URLs),
+		sw.WithCORS(cfg.CORS),
+		sw.WithIssuer(issuer),
+		sw.WithMemberStore(memberStore),
+		sw.WithChallengeStore(challengeStore),
+		sw.WithAPIKeyStore(apiKeyStore),
+		sw.WithWebhookStores(webhookStore, deliveryStore),
+		sw.WithNotificationPreferenceStore(preferenceStore),
+		sw.WithNotificationChannelStore(channelStore),
+		sw.WithEventStore(eventStore),
+		sw.WithMailer(mailer),
+		sw.WithOIDCProviders(oidcProviders(cfg)),
+		sw.WithPasswordHasher(hasher),
+		sw.WithPasswordPolicy(policy),
+		sw.WithReadinessCheck("database", pool.Ping),
+	}
+	if !cfg.LegacySunset.IsZero() {
+		opts = append(opts, sw.WithLegacySunset(cfg.LegacySunset))
+	}
+	if cfg.StrictJSON {
+		opts = append(opts, sw.WithStrictJSON())
+	}
+	if cfg.OpenAPIValidateRequests {
+		validator, err := api.NewValidator()
+		if err != nil {
+			return fmt.Errorf("Failed to load the OpenAPI spec: %w", err)
+		}
+		opts = append(opts, sw.WithRequestValidation(validator))
+	}
 
-	log.Fatal(http.ListenAndServe(":"+os.Getenv("PORT"), router))
+	sinks, closeSinks, err := eventSinks(cfg, logger, webhooks.NewPublisher(webhookStore, deliveryStore))
+	if err != nil {
+		return fmt.Errorf("Failed to connect event sinks: %w", err)
+	}
+	defer closeSinks()
+	// Owners are emailed and chat channels posted to about reviews whatever
+	// the configured sinks.
+	notifier := notifications.NewNotifier(notifications.Stores{
+		Preferences: preferenceStore,
+		Members:     memberStore,
+		Users:       userStore,
+		Companies:   companyStore,
+		Products:    productStore,
+		Reviews:     reviewStore,
+		Sent:        sentStore,
+	}, mailer, cfg.URLs.Dashboard, logger)
+	sinks["email"] = notifier
+	sinks["chat"] = notifications.NewPoster(channelStore, productStore, sentStore, cfg.URLs.Dashboard, logger)
+
+	// Background work stops before the sinks and the database pool are
+	// closed.
+	workCtx, stopWork := context.WithCancel(ctx)
+	var workers sync.WaitGroup
+	defer func() {
+		stopWork()
+		workers.Wait()
+	}()
+	for _, work := range []func(context.Context){
+		outbox.NewDispatcher(eventStore, logger, sinks).Run,
+		webhooks.NewDispatcher(webhookStore, deliveryStore, logger).Run,
+		notifier.Run,
+	} {
+		workers.Add(1)
+		go func(work func(context.Context)) {
+			defer workers.Done()
+			work(workCtx)
+		}(work)
+	}
+
+	router := sw.New(userStore, companyStore, productStore, reviewStore, opts...)
+
+	srv := server.New(":"+cfg.Port, router)
+	servers := []*http.Server{srv}
+	if cfg.TLSCertFile != "" {
+		certs, err := server.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load the TLS certificate: %w", err)
+		}
+		go certs.Watch(ctx, server.CertReloadInterval, func(err error) {
+			logger.Error("Failed to reload the TLS certificate", "error", err)
+		})
+		srv.TLSConfig = server.TLSConfig(certs)
+		srv.Handler = server.HSTS(cfg.HSTSMaxAge, router)
+		if cfg.HTTPRedirectPort != "" {
+			servers = append(servers, server.New(":"+cfg.HTTPRedirectPort, server.RedirectToHTTPS(cfg.Port)))
+		}
+	}
+	logger.Info("Server started", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+	return server.ListenAndServeAll(ctx, cfg.ShutdownGracePeriod, servers...)
 }
@@ -0,0 +1,510 @@
+// Package config loads the settings of the server.
+//
+// Settings are read from a file, the environment and command-line flags, in
+// increasing order of precedence, and validated together so that a
+// misconfigured server fails at startup with every problem listed.
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/outbox"
+	"api.proddx.com/passwords"
+	"api.proddx.com/server"
+)
+
+// URLs are the hosts that links in emails and redirects point to.
+type URLs struct {
+	// API is the host of this server.
+	API string
+	// Dashboard is the host of the dashboard web app.
+	Dashboard string
+	// Review is the host of the public review pages.
+	Review string
+}
+
+// CORS is the policy for requests from scripts on other sites.
+type CORS struct {
+	// AllowedOrigins are origins such as https://app.proddx.com, wildcard
+	// subdomains such as https://*.proddx.com, or * for any origin.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the answer to a preflight.
+	MaxAge time.Duration
+}
+
+// DefaultCORS holds the methods, headers and max-age used unless others are
+// configured.
+var DefaultCORS = CORS{
+	AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+	AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "Last-Event-ID", "traceparent"},
+	MaxAge:         10 * time.Minute,
+}
+
+// OIDCProvider configures single sign-on with an identity provider.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+}
+
+// Config holds the settings of the server.
+type Config struct {
+	Port        string
+	DatabaseURL string
+	URLs        URLs
+	// CORS allows the dashboard unless other origins are configured.
+	CORS CORS
+
+	JWTKeysDir      string
+	JWTSigningKeyID string
+	OIDCProviders   []OIDCProvider
+
+	PasswordHashAlgorithm string
+	BcryptCost            int
+	PasswordMinLength     int
+	BreachedPasswordsFile string
+
+	LogFormat      string
+	LogLevel       logging.Level
+	TracesExporter string
+
+	// LegacySunset is zero when the default sunset applies.
+	LegacySunset            time.Time
+	OpenAPIValidateRequests bool
+	StrictJSON              bool
+	ShutdownGracePeriod     time.Duration
+
+	// TLSCertFile and TLSKeyFile are empty when serving plain HTTP.
+	TLSCertFile string
+	TLSKeyFile  string
+	// HTTPRedirectPort is empty unless plain HTTP is redirected to HTTPS.
+	HTTPRedirectPort string
+	HSTSMaxAge       time.Duration
+
+	// EventSinks are the names of the sinks events are published to.
+	EventSinks []string
+	// NATSURL is empty unless events are published to NATS.
+	NATSURL           string
+	NATSSubjectPrefix string
+
+	// Mailer is the name of the mailer that delivers email.
+	Mailer string
+	// MailFrom is the sender of email, empty for the log mailer.
+	MailFrom     string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	MailDir      string
+	// MailLogBodies has the log mailer log the bodies of messages, which
+	// carry credentials such as verification links.
+	MailLogBodies bool
+}
+
+// Sinks of events.
+const (
+	SinkWebhook = "webhook"
+	SinkLog     = "log"
+	SinkNATS    = "nats"
+)
+
+// Mailers that deliver email.
+const (
+	MailerLog  = "log"
+	MailerSMTP = "smtp"
+	MailerFile = "file"
+)
+
+type setting struct {
+	key      string
+	value    string
+	required bool
+	usage    string
+}
+
+// settings lists the known keys with their defaults. Each key is also a
+// flag, lowercased with dashes, such as -database-url.
+var settings = []setting{
+	{key: "PORT", value: "5000", usage: "port to listen on"},
+	{key: "DATABASE_URL", required: true, usage: "PostgreSQL connection URL"},
+	{key: "API_URL", required: true, usage: "host of the API, for links in emails and SSO redirects"},
+	{key: "DASHBOARD_URL", required: true, usage: "host of the dashboard"},
+	{key: "REVIEW_URL", required: true, usage: "host of the review pages, for product feedback URLs"},
+	{key: "CORS_ALLOWED_ORIGINS", usage: "comma-separated origins allowed to call the API, https://$DASHBOARD_URL by default"},
+	{key: "CORS_ALLOWED_METHODS", value: strings.Join(DefaultCORS.AllowedMethods, ","), usage: "comma-separated methods allowed from other origins"},
+	{key: "CORS_ALLOWED_HEADERS", value: strings.Join(DefaultCORS.AllowedHeaders, ","), usage: "comma-separated request headers allowed from other origins"},
+	{key: "CORS_ALLOW_CREDENTIALS", value: "false", usage: "let other origins send cookies"},
+	{key: "CORS_MAX_AGE", value: DefaultCORS.MaxAge.String(), usage: "time browsers may cache preflight responses"},
+	{key: "JWT_KEYS_DIR", required: true, usage: "directory of the PEM token signing keys"},
+	{key: "JWT_SIGNING_KEY_ID", required: true, usage: "ID of the key that signs new tokens"},
+	{key: "OIDC_PROVIDERS", usage: "comma-separated single sign-on providers, configured with OIDC_<NAME>_*"},
+	{key: "PASSWORD_HASH_ALGORITHM", value: passwords.Bcrypt, usage: "bcrypt or argon2id"},
+	{key: "BCRYPT_COST", value: "10", usage: "bcrypt cost"},
+	{key: "PASSWORD_MIN_LENGTH", value: strconv.Itoa(passwords.DefaultMinLength), usage: "minimum length of new passwords"},
+	{key: "BREACHED_PASSWORDS_FILE", usage: "file of breached passwords or SHA-1 hashes, one per line"},
+	{key: "LOG_FORMAT", value: logging.JSON, usage: "json or logfmt"},
+	{key: "LOG_LEVEL", value: "info", usage: "debug, info, warn or error"},
+	{key: "OTEL_TRACES_EXPORTER", value: "none", usage: "otlp, stdout or none"},
+	{key: "LEGACY_SUNSET", usage: "date the unprefixed paths are removed, such as 2027-01-01"},
+	{key: "OPENAPI_VALIDATE_REQUESTS", value: "false", usage: "reject requests that do not conform to the OpenAPI spec"},
+	{key: "TLS_CERT_FILE", usage: "PEM certificate chain to serve HTTPS with, reloaded when it changes"},
+	{key: "TLS_KEY_FILE", usage: "PEM private key of the certificate"},
+	{key: "HTTP_REDIRECT_PORT", usage: "port to redirect plain HTTP to HTTPS from, such as 80"},
+	{key: "HSTS_MAX_AGE", value: server.DefaultHSTSMaxAge.String(), usage: "time browsers only use HTTPS for, 0 to disable HSTS"},
+	{key: "JSON_DISALLOW_UNKNOWN_FIELDS", value: "false", usage: "reject request bodies with unknown fields"},
+	{key: "EVENT_SINKS", value: SinkWebhook, usage: "comma-separated sinks of review and product events: webhook, log and nats"},
+	{key: "NATS_URL", usage: "NATS server URL for the nats event sink"},
+	{key: "NATS_SUBJECT_PREFIX", value: outbox.DefaultNATSSubjectPrefix, usage: "prefix of the NATS subjects of events"},
+	{key: "MAILER", value: MailerLog, usage: "log, smtp or file"},
+	{key: "MAIL_FROM", usage: "sender of email, such as Proddx <no-reply@proddx.com>"},
+	{key: "SMTP_ADDR", usage: "host:port of the SMTP server for the smtp mailer"},
+	{key: "SMTP_USERNAME", usage: "user to log in to the SMTP server as, if any"},
+	{key: "SMTP_PASSWORD", usage: "password of SMTP_USERNAME"},
+	{key: "MAIL_DIR", usage: "directory the file mailer writes .eml files to"},
+	{key: "MAIL_LOG_BODIES", value: "false", usage: "log the bodies of messages with the log mailer, for development"},
+	{key: "SHUTDOWN_GRACE_PERIOD", value: server.DefaultGracePeriod.String(), usage: "time in-flight requests get to complete on shutdown"},
+}
+
+// oidcPrefix starts the keys of the settings of each single sign-on provider.
+const oidcPrefix = "OIDC_"
+
+// Error lists every invalid setting.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "Invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Load reads the settings from the file named by the -config flag or
+// CONFIG_FILE, the environment entries in environ and the flags in args.
+// Files hold KEY=value lines like the environment, with # comments.
+func Load(args []string, environ []string) (*Config, error) {
+	env := map[string]string{}
+	configFile := ""
+	for _, entry := range environ {
+		key, value, ok := cut(entry, "=")
+		if ok && key == "CONFIG_FILE" {
+			configFile = value
+		} else if ok && known(key) {
+			env[key] = value
+		}
+	}
+
+	fs := flag.NewFlagSet("proddx-server", flag.ContinueOnError)
+	file := fs.String("config", configFile, "file of KEY=value settings")
+	for _, s := range settings {
+		fs.String(flagName(s.key), s.value, s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, s := range settings {
+		values[s.key] = s.value
+	}
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err = readFile(f, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", *file, err)
+		}
+	}
+	for key, value := range env {
+		values[key] = value
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			values[keyName(f.Name)] = f.Value.String()
+		}
+	})
+	return parse(values)
+}
+
+// readFile reads KEY=value lines into values.
+func readFile(r io.Reader, values map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !known(key) {
+			return fmt.Errorf("line %d: Unknown setting %q", n, key)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		values[key] = value
+	}
+	return scanner.Err()
+}
+
+// parse validates values into a Config.
+func parse(values map[string]string) (*Config, error) {
+	var problems []string
+	invalid := func(key string, err error) {
+		problems = append(problems, fmt.Sprintf("%s: %s", key, err.Error()))
+	}
+	for _, s := range settings {
+		if s.required && values[s.key] == "" {
+			problems = append(problems, s.key+" is required")
+		}
+	}
+
+	cfg := &Config{
+		Port:        values["PORT"],
+		DatabaseURL: values["DATABASE_URL"],
+		URLs: URLs{
+			API:       values["API_URL"],
+			Dashboard: values["DASHBOARD_URL"],
+			Review:    values["REVIEW_URL"],
+		},
+		JWTKeysDir:            values["JWT_KEYS_DIR"],
+		JWTSigningKeyID:       values["JWT_SIGNING_KEY_ID"],
+		PasswordHashAlgorithm: values["PASSWORD_HASH_ALGORITHM"],
+		BreachedPasswordsFile: values["BREACHED_PASSWORDS_FILE"],
+		LogFormat:             values["LOG_FORMAT"],
+		TracesExporter:        values["OTEL_TRACES_EXPORTER"],
+	}
+	var err error
+	if _, err = strconv.ParseUint(cfg.Port, 10, 16); err != nil {
+		invalid("PORT", err)
+	}
+	for _, key := range []string{"API_URL", "DASHBOARD_URL", "REVIEW_URL"} {
+		if strings.Contains(values[key], "://") {
+			problems = append(problems, key+" must be a host without a scheme")
+		}
+	}
+
+	cfg.CORS = CORS{
+		AllowedOrigins: list(values["CORS_ALLOWED_ORIGINS"]),
+		AllowedMethods: list(values["CORS_ALLOWED_METHODS"]),
+		AllowedHeaders: list(values["CORS_ALLOWED_HEADERS"]),
+	}
+	if len(cfg.CORS.AllowedOrigins) == 0 && cfg.URLs.Dashboard != "" {
+		cfg.CORS.AllowedOrigins = []string{"https://" + cfg.URLs.Dashboard}
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if err := validOrigin(origin); err != nil {
+			invalid("CORS_ALLOWED_ORIGINS", err)
+		}
+	}
+	if cfg.CORS.AllowCredentials, err = strconv.ParseBool(values["CORS_ALLOW_CREDENTIALS"]); err != nil {
+		invalid("CORS_ALLOW_CREDENTIALS", err)
+	} else if cfg.CORS.AllowCredentials && contains(cfg.CORS.AllowedOrigins, "*") {
+		problems = append(problems, "CORS_ALLOW_CREDENTIALS cannot be combined with any origin")
+	}
+	if cfg.CORS.MaxAge, err = time.ParseDuration(values["CORS_MAX_AGE"]); err != nil {
+		invalid("CORS_MAX_AGE", err)
+	}
+
+	for _, name := range strings.Split(values["OIDC_PROVIDERS"], ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := oidcPrefix + strings.ToUpper(name) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       values[prefix+"ISSUER"],
+			ClientID:     values[prefix+"CLIENT_ID"],
+			ClientSecret: values[prefix+"CLIENT_SECRET"],
+		}
+		for key, value := range map[string]string{"ISSUER": provider.Issuer, "CLIENT_ID": provider.ClientID, "CLIENT_SECRET": provider.ClientSecret} {
+			if value == "" {
+				problems = append(problems, prefix+key+" is required")
+			}
+		}
+		cfg.OIDCProviders = append(cfg.OIDCProviders, provider)
+	}
+
+	if cfg.BcryptCost, err = strconv.Atoi(values["BCRYPT_COST"]); err != nil {
+		invalid("BCRYPT_COST", err)
+	} else if _, err = passwords.NewHasher(cfg.PasswordHashAlgorithm, cfg.BcryptCost); err != nil {
+		invalid("PASSWORD_HASH_ALGORITHM", err)
+	}
+	if cfg.PasswordMinLength, err = strconv.Atoi(values["PASSWORD_MIN_LENGTH"]); err != nil {
+		invalid("PASSWORD_MIN_LENGTH", err)
+	} else if cfg.PasswordMinLength < 1 {
+		problems = append(problems, "PASSWORD_MIN_LENGTH must be positive")
+	}
+
+	if cfg.LogFormat != logging.JSON && cfg.LogFormat != logging.Logfmt {
+		problems = append(problems, "LOG_FORMAT must be json or logfmt")
+	}
+	if cfg.LogLevel, err = logging.ParseLevel(values["LOG_LEVEL"]); err != nil {
+		invalid("LOG_LEVEL", err)
+	}
+	switch cfg.TracesExporter {
+	case "none", "otlp", "stdout":
+	default:
+		problems = append(problems, "OTEL_TRACES_EXPORTER must be otlp, stdout or none")
+	}
+
+	if value := values["LEGACY_SUNSET"]; value != "" {
+		if cfg.LegacySunset, err = time.Parse("2006-01-02", value); err != nil {
+			invalid("LEGACY_SUNSET", err)
+		}
+	}
+	if cfg.OpenAPIValidateRequests, err = strconv.ParseBool(values["OPENAPI_VALIDATE_REQUESTS"]); err != nil {
+		invalid("OPENAPI_VALIDATE_REQUESTS", err)
+	}
+	if cfg.StrictJSON, err = strconv.ParseBool(values["JSON_DISALLOW_UNKNOWN_FIELDS"]); err != nil {
+		invalid("JSON_DISALLOW_UNKNOWN_FIELDS", err)
+	}
+	if cfg.ShutdownGracePeriod, err = time.ParseDuration(values["SHUTDOWN_GRACE_PERIOD"]); err != nil {
+		invalid("SHUTDOWN_GRACE_PERIOD", err)
+	}
+
+	cfg.TLSCertFile = values["TLS_CERT_FILE"]
+	cfg.TLSKeyFile = values["TLS_KEY_FILE"]
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		problems = append(problems, "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	cfg.HTTPRedirectPort = values["HTTP_REDIRECT_PORT"]
+	if cfg.HTTPRedirectPort != "" {
+		if _, err = strconv.ParseUint(cfg.HTTPRedirectPort, 10, 16); err != nil {
+			invalid("HTTP_REDIRECT_PORT", err)
+		} else if cfg.TLSCertFile == "" {
+			problems = append(problems, "HTTP_REDIRECT_PORT requires TLS_CERT_FILE")
+		}
+	}
+	if cfg.HSTSMaxAge, err = time.ParseDuration(values["HSTS_MAX_AGE"]); err != nil {
+		invalid("HSTS_MAX_AGE", err)
+	}
+
+	cfg.EventSinks = list(values["EVENT_SINKS"])
+	for _, sink := range cfg.EventSinks {
+		if sink != SinkWebhook && sink != SinkLog && sink != SinkNATS {
+			problems = append(problems, fmt.Sprintf("EVENT_SINKS: Unknown sink %q", sink))
+		}
+	}
+	cfg.NATSURL = values["NATS_URL"]
+	cfg.NATSSubjectPrefix = values["NATS_SUBJECT_PREFIX"]
+	if contains(cfg.EventSinks, SinkNATS) && cfg.NATSURL == "" {
+		problems = append(problems, "NATS_URL is required by the nats event sink")
+	}
+
+	cfg.Mailer = values["MAILER"]
+	cfg.MailFrom = values["MAIL_FROM"]
+	cfg.SMTPAddr = values["SMTP_ADDR"]
+	cfg.SMTPUsername = values["SMTP_USERNAME"]
+	cfg.SMTPPassword = values["SMTP_PASSWORD"]
+	cfg.MailDir = values["MAIL_DIR"]
+	if cfg.MailLogBodies, err = strconv.ParseBool(values["MAIL_LOG_BODIES"]); err != nil {
+		invalid("MAIL_LOG_BODIES", err)
+	}
+	switch cfg.Mailer {
+	case MailerLog:
+	case MailerSMTP:
+		if _, _, err = net.SplitHostPort(cfg.SMTPAddr); err != nil {
+			problems = append(problems, "SMTP_ADDR must be a host and port, required by the smtp mailer")
+		}
+	case MailerFile:
+		if cfg.MailDir == "" {
+			problems = append(problems, "MAIL_DIR is required by the file mailer")
+		}
+	default:
+		problems = append(problems, "MAILER must be log, smtp or file")
+	}
+	if cfg.Mailer != MailerLog {
+		if _, err = mail.ParseAddress(cfg.MailFrom); err != nil {
+			problems = append(problems, "MAIL_FROM must be an email address, required by the "+cfg.Mailer+" mailer")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// validOrigin checks that origin is *, or a scheme and host with an
+// optional *. wildcard for subdomains.
+func validOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("%q is not an origin such as https://app.proddx.com", origin)
+	}
+	return nil
+}
+
+// list splits a comma-separated value.
+func list(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// known reports whether key is a setting, including the settings of single
+// sign-on providers.
+func known(key string) bool {
+	if strings.HasPrefix(key, oidcPrefix) {
+		return true
+	}
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+func keyName(flag string) string {
+	return strings.ReplaceAll(strings.ToUpper(flag), "-", "_")
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+)
+
+var required = []string{
+	"DATABASE_URL=postgres://localhost/proddx",
+	"API_URL=api.proddx.com",
+	"DASHBOARD_URL=app.proddx.com",
+	"REVIEW_URL=review.proddx.com",
+	"JWT_KEYS_DIR=keys",
+	"JWT_SIGNING_KEY_ID=2022-03",
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, append([]string{"HOME=/root"}, required...))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if cfg.Port != "5000" || cfg.LogFormat != logging.JSON || cfg.LogLevel != logging.Info || cfg.BcryptCost != 10 || cfg.PasswordMinLength != 8 {
+		t.Errorf("Error: Unexpected defaults %+v", cfg)
+	}
+	if cfg.ShutdownGracePeriod != 30*time.Second || !cfg.LegacySunset.IsZero() || cfg.OpenAPIValidateRequests {
+		t.Errorf("Error: Unexpected defaults %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.CORS.AllowedOrigins, []string{"https://app.proddx.com"}) || cfg.CORS.MaxAge != DefaultCORS.MaxAge || len(cfg.CORS.AllowedMethods) != 5 {
+		t.Errorf("Error: Unexpected CORS defaults %+v", cfg.CORS)
+	}
+	if !reflect.DeepEqual(cfg.EventSinks, []string{SinkWebhook}) || cfg.NATSSubjectPrefix != "proddx.events" {
+		t.Errorf("Error: Unexpected event sinks %v %s", cfg.EventSinks, cfg.NATSSubjectPrefix)
+	}
+	if cfg.Mailer != MailerLog || cfg.MailLogBodies {
+		t.Errorf("Error: Unexpected mailer %s - %t", cfg.Mailer, cfg.MailLogBodies)
+	}
+	if cfg.URLs != (URLs{API: "api.proddx.com", Dashboard: "app.proddx.com", Review: "review.proddx.com"}) {
+		t.Errorf("Error: Unexpected URLs %+v", cfg.URLs)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "proddx.env")
+	contents := `# Settings shared by every instance
+PORT=6000
+LOG_LEVEL=debug
+LOG_FORMAT="logfmt"
+OIDC_PROVIDERS=google
+OIDC_GOOGLE_ISSUER=https://accounts.google.com
+OIDC_GOOGLE_CLIENT_ID=client
+OIDC_GOOGLE_CLIENT_SECRET=secret
+`
+	if err := os.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	environ := append([]string{"CONFIG_FILE=" + file, "PORT=7000", "LOG_LEVEL=warn"}, required...)
+	cfg, err := Load([]string{"-port", "8000", "-legacy-sunset", "2027-06-01"}, environ)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if cfg.Port != "8000" {
+		t.Errorf("Error: Expected flags to override the environment, got port %s", cfg.Port)
+	}
+	if cfg.LogLevel != logging.Warn || cfg.LogFormat != logging.Logfmt {
+		t.Errorf("Error: Expected the environment to override the file, got %s %s", cfg.LogLevel, cfg.LogFormat)
+	}
+	if !cfg.LegacySunset.Equal(time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Error: Unexpected sunset %s", cfg.LegacySunset)
+	}
+	expected := []OIDCProvider{{Name: "google", Issuer: "https://accounts.google.com", ClientID: "client", ClientSecret: "secret"}}
+	if !reflect.DeepEqual(cfg.OIDCProviders, expected) {
+		t.Errorf("Error: Unexpected providers %+v", cfg.OIDCProviders)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	environ := []string{
+		"API_URL=https://api.proddx.com",
+		"PORT=http",
+		"LOG_LEVEL=verbose",
+		"BCRYPT_COST=40",
+		"OIDC_PROVIDERS=okta",
+		"SHUTDOWN_GRACE_PERIOD=30",
+		"CORS_ALLOWED_ORIGINS=*,app.proddx.com",
+		"CORS_ALLOW_CREDENTIALS=true",
+		"TLS_CERT_FILE=cert.pem",
+		"HTTP_REDIRECT_PORT=80",
+		"EVENT_SINKS=webhook,nats",
+		"MAILER=smtp",
+		"MAIL_LOG_BODIES=yes",
+	}
+	_, err := Load(nil, environ)
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Error: Expected a configuration error, got %v", err)
+	}
+	expected := []string{
+		"API_URL must be a host without a scheme",
+		"CORS_ALLOW_CREDENTIALS cannot be combined with any origin",
+		"DASHBOARD_URL is required",
+		"DATABASE_URL is required",
+		"JWT_KEYS_DIR is required",
+		"JWT_SIGNING_KEY_ID is required",
+		"MAIL_FROM must be an email address, required by the smtp mailer",
+		"NATS_URL is required by the nats event sink",
+		"OIDC_OKTA_CLIENT_ID is required",
+		"OIDC_OKTA_CLIENT_SECRET is required",
+		"OIDC_OKTA_ISSUER is required",
+		"REVIEW_URL is required",
+		"SMTP_ADDR must be a host and port, required by the smtp mailer",
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+	}
+	for _, problem := range expected {
+		if !contains(cfgErr.Problems, problem) {
+			t.Errorf("Error: Expected problem %q in %v", problem, cfgErr.Problems)
+		}
+	}
+	if len(cfgErr.Problems) != len(expected)+6 {
+		t.Errorf("Error: Expected PORT, LOG_LEVEL, BCRYPT_COST, SHUTDOWN_GRACE_PERIOD, CORS_ALLOWED_ORIGINS and MAIL_LOG_BODIES to be invalid: %v", cfgErr.Problems)
+	}
+
+	file := filepath.Join(t.TempDir(), "proddx.env")
+	os.WriteFile(file, []byte("JWT_SECRET=secret\n"), 0600)
+	if _, err := Load([]string{"-config", file}, required); err == nil {
+		t.Errorf("Error: Expected an unknown setting in the file to be rejected")
+	}
+}
@@ -0,0 +1,6 @@
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_company_id_fkey,
+    ADD CONSTRAINT products_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE;
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_company_id_fkey,
+    ADD CONSTRAINT reviews_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE;
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_product_id_fkey,
+    ADD CONSTRAINT reviews_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS company_members(
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    member_role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP,
+    PRIMARY KEY (company_id, user_id)
+);
+INSERT INTO company_members(company_id, user_id, member_role, created_at)
+    SELECT c.id, u.id, 'owner', c.created_at FROM companies c JOIN users u ON u.id::text = c.company_user_id
+    ON CONFLICT DO NOTHING;
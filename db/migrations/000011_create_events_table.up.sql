@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS events(
+    sequence BIGSERIAL PRIMARY KEY,
+    id UUID NOT NULL UNIQUE,
+    company_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
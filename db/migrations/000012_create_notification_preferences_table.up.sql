@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS notification_preferences(
+    company_id UUID PRIMARY KEY REFERENCES companies(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL,
+    threshold INTEGER NOT NULL DEFAULT 0,
+    frequency VARCHAR(20) NOT NULL,
+    digested_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notification_preferences_digest_idx ON notification_preferences(digested_at) WHERE mode = 'digest';
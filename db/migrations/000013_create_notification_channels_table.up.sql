@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS notification_channels(
+    id UUID PRIMARY KEY,
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS notification_channels_company_id_idx ON notification_channels(company_id);
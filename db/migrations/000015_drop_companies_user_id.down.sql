@@ -0,0 +1,6 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS company_user_id VARCHAR(60);
+UPDATE companies c SET company_user_id = (
+    SELECT m.user_id::text FROM company_members m
+    WHERE m.company_id = c.id AND m.member_role = 'owner'
+    ORDER BY m.created_at LIMIT 1
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS event_deliveries;
+DROP INDEX IF EXISTS events_created_at_idx;
+DROP INDEX IF EXISTS events_unqueued_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS queued;
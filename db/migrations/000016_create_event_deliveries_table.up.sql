@@ -0,0 +1,14 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS queued BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS events_unqueued_idx ON events(sequence) WHERE NOT queued;
+CREATE INDEX IF NOT EXISTS events_created_at_idx ON events(created_at);
+CREATE TABLE IF NOT EXISTS event_deliveries(
+    sequence BIGINT NOT NULL REFERENCES events(sequence) ON DELETE CASCADE,
+    sink VARCHAR(50) NOT NULL,
+    company_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (sequence, sink)
+);
+CREATE INDEX IF NOT EXISTS event_deliveries_pending_idx ON event_deliveries(sink, company_id, sequence) WHERE status = 'pending';
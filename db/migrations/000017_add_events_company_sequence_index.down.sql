@@ -0,0 +1 @@
+DROP INDEX IF EXISTS events_company_id_sequence_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS events_company_id_sequence_idx ON events(company_id, sequence);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS sent_notifications(
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    recipient VARCHAR(150) NOT NULL,
+    sent_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (event_id, recipient)
+);
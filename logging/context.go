@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+// scope holds the logger of a request. Middleware further down the chain
+// adds fields to it, such as the user once authenticated, which then also
+// appear on the lines logged by the middleware that created it.
+type scope struct {
+	mu     sync.Mutex
+	logger *Logger
+}
+
+// NewContext returns a context carrying l as the logger of a request.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{logger: l})
+}
+
+// FromContext returns the logger of the request, or Default outside
+// NewContext.
+func FromContext(ctx context.Context) *Logger {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return Default()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logger
+}
+
+// AddFields adds the key/value pairs kv to the logger of the request.
+func AddFields(ctx context.Context, kv ...interface{}) {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = s.logger.With(kv...)
+}
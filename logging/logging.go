@@ -0,0 +1,189 @@
+// Package logging writes structured log lines as JSON or logfmt.
+//
+// Lines have a time, level and message followed by key/value pairs. Loggers
+// carry fields that are added to each of their lines, and a request's logger
+// travels in its context so that the handlers' lines share the fields of
+// the request, such as its ID.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Formats of log lines.
+const (
+	JSON   = "json"
+	Logfmt = "logfmt"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return strconv.Itoa(int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level called name.
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("Unknown log level %s", name)
+}
+
+// output is shared by a logger and the loggers derived from it with With,
+// so their lines are not interleaved.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+	level  Level
+}
+
+// Logger writes log lines of at least its level.
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+// New returns a logger writing lines of at least level to w in format.
+func New(w io.Writer, format string, level Level) (*Logger, error) {
+	if format != JSON && format != Logfmt {
+		return nil, fmt.Errorf("Unknown log format %s", format)
+	}
+	return &Logger{out: &output{w: w, format: format, level: level}}, nil
+}
+
+var std = &Logger{out: &output{w: os.Stderr, format: Logfmt, level: Info}}
+
+// Default returns the logger writing logfmt lines of level info and above to
+// standard error, used when no other logger is configured.
+func Default() *Logger {
+	return std
+}
+
+// With returns a logger that adds the key/value pairs kv to every line.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Enabled reports whether lines of level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.Log(Debug, msg, kv...) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.Log(Info, msg, kv...) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.Log(Warn, msg, kv...) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.Log(Error, msg, kv...) }
+
+// Log writes a line of level with msg, the fields of l and the key/value
+// pairs kv.
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	pairs := make([]interface{}, 0, 6+len(l.fields)+len(kv))
+	pairs = append(pairs, "time", time.Now().UTC().Format(time.RFC3339Nano), "level", level.String(), "msg", msg)
+	pairs = append(pairs, l.fields...)
+	pairs = append(pairs, kv...)
+	if len(pairs)%2 != 0 {
+		pairs = append(pairs, "(missing)")
+	}
+
+	var b strings.Builder
+	if l.out.format == JSON {
+		writeJSON(&b, pairs)
+	} else {
+		writeLogfmt(&b, pairs)
+	}
+	b.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	io.WriteString(l.out.w, b.String())
+}
+
+func writeJSON(b *strings.Builder, pairs []interface{}) {
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(pairs[i]))
+		b.Write(key)
+		b.WriteByte(':')
+		value, err := json.Marshal(plain(pairs[i+1]))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(pairs[i+1]))
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+}
+
+func writeLogfmt(b *strings.Builder, pairs []interface{}) {
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(fmt.Sprint(pairs[i]))
+		b.WriteByte('=')
+		value := fmt.Sprint(plain(pairs[i+1]))
+		if needsQuotes(value) {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(value)
+	}
+}
+
+// plain turns values that do not encode well on their own, such as errors
+// and durations, into strings.
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func needsQuotes(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r > '~' {
+			return true
+		}
+	}
+	return false
+}
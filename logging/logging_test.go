@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, JSON, Info)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	logger.With("request_id", "abc").Error("Storage error", "error", errors.New("not found"), "status", 404)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	expected := map[string]interface{}{"level": "error", "msg": "Storage error", "request_id": "abc", "error": "not found", "status": 404.0}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("Error: Expected %s to be %v: %v", key, value, line[key])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, line["time"].(string)); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Logfmt, Warn)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	logger.Info("Request")
+	logger.Warn("Forbidden", "route", "FindReview", "detail", `say "hi"`, "duration", 1500*time.Millisecond)
+
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("Error: Expected lines below the level to be skipped: %s", line)
+	}
+	for _, field := range []string{"level=warn", "msg=Forbidden", "route=FindReview", `detail="say \"hi\""`, "duration=1.5s"} {
+		if !strings.Contains(line, " "+field) {
+			t.Errorf("Error: Expected %s in %s", field, line)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, Logfmt, Info)
+	if FromContext(context.Background()) != Default() {
+		t.Error("Error: Expected the default logger outside a request")
+	}
+
+	ctx := NewContext(context.Background(), logger.With("request_id", "abc"))
+	AddFields(ctx, "user_id", "42")
+	FromContext(ctx).Info("Request")
+
+	if !strings.Contains(buf.String(), "request_id=abc user_id=42") {
+		t.Errorf("Error: Expected the fields of the request: %s", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("WARN"); err != nil || level != Warn {
+		t.Errorf("Error: Unexpected level %s", level)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Error: Expected an unknown level to be rejected")
+	}
+	if _, err := New(&bytes.Buffer{}, "xml", Info); err == nil {
+		t.Error("Error: Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer drops each message as an .eml file into a directory, for
+// development or for another program to deliver.
+type FileMailer struct {
+	dir  string
+	from *mail.Address
+}
+
+// NewFileMailer writes messages from the address from into dir, creating
+// it if needed.
+func NewFileMailer(dir string, from string) (*FileMailer, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: sender}, nil
+}
+
+// Send writes the message to a temporary file and renames it, so that
+// readers of the directory never see partial messages.
+func (m *FileMailer) Send(msg Message) error {
+	now := time.Now()
+	body, err := compose(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(m.dir, ".message-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), filepath.Base(f.Name())[len(".message-"):])
+	return os.Rename(f.Name(), filepath.Join(m.dir, name))
+}
@@ -0,0 +1,163 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"api.proddx.com/logging"
+)
+
+var testMessage = Message{
+	To:      "owner@domain.com",
+	Subject: "New 2★ review",
+	Text:    "Product One received a review.",
+	HTML:    "<p>Product One received a review.</p>",
+}
+
+// parts reads the text and HTML alternatives of a composed message.
+func parts(t *testing.T, r io.Reader) (*mail.Message, map[string]string) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Error: Expected alternative parts: %s", mediaType)
+	}
+	bodies := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+	return msg, bodies
+}
+
+func TestLogMailer(t *testing.T) {
+	for _, bodies := range []bool{false, true} {
+		var out bytes.Buffer
+		logger, _ := logging.New(&out, logging.Logfmt, logging.Info)
+		if err := NewLogMailer(logger, bodies).Send(testMessage); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if !strings.Contains(out.String(), testMessage.To) {
+			t.Errorf("Error: Expected the recipient to be logged: %s", out.String())
+		}
+		if strings.Contains(out.String(), testMessage.Text) != bodies {
+			t.Errorf("Error: Expected the body to be logged only when enabled (%t): %s", bodies, out.String())
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer, err := NewFileMailer(dir, "Proddx <no-reply@proddx.com>")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err = mailer.Send(testMessage); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".eml") {
+		t.Fatalf("Error: Expected one .eml file: %v", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	defer f.Close()
+	msg, bodies := parts(t, f)
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != testMessage.Subject || msg.Header.Get("To") != testMessage.To || !strings.Contains(msg.Header.Get("From"), "no-reply@proddx.com") {
+		t.Errorf("Error: Unexpected headers %v", msg.Header)
+	}
+	if bodies["text/plain"] != testMessage.Text || bodies["text/html"] != testMessage.HTML {
+		t.Errorf("Error: Unexpected bodies %v", bodies)
+	}
+}
+
+// smtpServer accepts one message and sends its recipient and data on
+// received.
+func smtpServer(t *testing.T, received chan<- []string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		var rcpt string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+			case "EHLO", "HELO", "MAIL", "NOOP", "RSET":
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				rcpt = line
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				data, _ := tp.ReadDotBytes()
+				received <- []string{rcpt, string(data)}
+				tp.PrintfLine("250 Queued")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Unsupported")
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestSMTPMailer(t *testing.T) {
+	received := make(chan []string, 1)
+	mailer, err := NewSMTPMailer(smtpServer(t, received), "", "", "no-reply@proddx.com")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err = mailer.Send(testMessage); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	message := <-received
+	if message[0] != "RCPT TO:<owner@domain.com>" {
+		t.Errorf("Error: Unexpected recipient %s", message[0])
+	}
+	_, bodies := parts(t, strings.NewReader(message[1]))
+	if bodies["text/plain"] != testMessage.Text {
+		t.Errorf("Error: Unexpected bodies %v", bodies)
+	}
+}
@@ -0,0 +1,83 @@
+// Package metrics collects the Prometheus metrics of the API.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "proddx"
+
+// Metrics holds the metrics of one server. The methods of a nil *Metrics do
+// nothing, so that handlers can record metrics whether or not they are
+// enabled.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	reviews  *prometheus.CounterVec
+}
+
+// New returns Metrics registered with a registry of their own, which also
+// collects Go runtime and process metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Requests handled, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		reviews: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reviews_submitted_total",
+			Help:      "Reviews submitted, by rating.",
+		}, []string{"rating"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.reviews,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a request handled by route.
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ReviewSubmitted records a new review with rating.
+func (m *Metrics) ReviewSubmitted(rating uint) {
+	if m == nil {
+		return
+	}
+	m.reviews.WithLabelValues(strconv.FormatUint(uint64(rating), 10)).Inc()
+}
+
+// MustRegister adds collectors, such as a PoolCollector, to the metrics.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeStats struct{}
+
+func (fakeStats) AcquireCount() int64            { return 12 }
+func (fakeStats) AcquireDuration() time.Duration { return 1500 * time.Millisecond }
+func (fakeStats) AcquiredConns() int32           { return 2 }
+func (fakeStats) CanceledAcquireCount() int64    { return 1 }
+func (fakeStats) ConstructingConns() int32       { return 0 }
+func (fakeStats) EmptyAcquireCount() int64       { return 3 }
+func (fakeStats) IdleConns() int32               { return 4 }
+func (fakeStats) MaxConns() int32                { return 10 }
+func (fakeStats) TotalConns() int32              { return 6 }
+
+func TestMetrics(t *testing.T) {
+	m := New()
+	m.ObserveRequest("FindReview", "GET", 200, 20*time.Millisecond)
+	m.ObserveRequest("FindReview", "GET", 200, 30*time.Millisecond)
+	m.ObserveRequest("FindReview", "GET", 404, time.Millisecond)
+	m.ReviewSubmitted(5)
+
+	if value := testutil.ToFloat64(m.requests.WithLabelValues("FindReview", "GET", "200")); value != 2 {
+		t.Errorf("Error: Unexpected request count %f", value)
+	}
+	if value := testutil.ToFloat64(m.reviews.WithLabelValues("5")); value != 1 {
+		t.Errorf("Error: Unexpected review count %f", value)
+	}
+	if count := testutil.CollectAndCount(m.duration); count != 1 {
+		t.Errorf("Error: Unexpected number of histograms %d", count)
+	}
+
+	var disabled *Metrics
+	disabled.ObserveRequest("FindReview", "GET", 200, time.Millisecond)
+	disabled.ReviewSubmitted(5)
+}
+
+func TestPoolCollector(t *testing.T) {
+	c := NewPoolCollector(func() PoolStats { return fakeStats{} })
+	expected := `
+# HELP proddx_db_pool_acquired_connections Connections currently in use.
+# TYPE proddx_db_pool_acquired_connections gauge
+proddx_db_pool_acquired_connections 2
+# HELP proddx_db_pool_acquires_total Connections acquired from the pool.
+# TYPE proddx_db_pool_acquires_total counter
+proddx_db_pool_acquires_total 12
+# HELP proddx_db_pool_acquire_duration_seconds_total Time spent acquiring connections from the pool.
+# TYPE proddx_db_pool_acquire_duration_seconds_total counter
+proddx_db_pool_acquire_duration_seconds_total 1.5
+`
+	names := []string{"proddx_db_pool_acquired_connections", "proddx_db_pool_acquires_total", "proddx_db_pool_acquire_duration_seconds_total"}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+	if count := testutil.CollectAndCount(c); count != 9 {
+		t.Errorf("Error: Unexpected number of pool metrics %d", count)
+	}
+}
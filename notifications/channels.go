@@ -0,0 +1,225 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/outbox"
+	"api.proddx.com/storage"
+	"api.proddx.com/webhooks"
+)
+
+const (
+	// postTimeout bounds each post to a channel.
+	postTimeout = 10 * time.Second
+	userAgent   = "Proddx-Notifications/1.0"
+)
+
+// Formats lists the formats of the messages posted to channels.
+var Formats = []string{storage.ChannelSlack, storage.ChannelTeams, storage.ChannelJSON}
+
+// KnownFormat reports whether format is one of Formats.
+func KnownFormat(format string) bool {
+	for _, known := range Formats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Poster posts new reviews to the chat channels of their company.
+type Poster struct {
+	channels     storage.NotificationChannel
+	products     storage.Product
+	sent         storage.SentNotification
+	dashboardURL string
+	client       *http.Client
+	logger       *logging.Logger
+}
+
+// NewPoster links the messages it posts to the dashboard at dashboardURL, a
+// host. Channels are posted to like webhook endpoints, which may only be
+// public addresses.
+func NewPoster(cs storage.NotificationChannel, ps storage.Product, ss storage.SentNotification, dashboardURL string, logger *logging.Logger) *Poster {
+	return &Poster{
+		channels:     cs,
+		products:     ps,
+		sent:         ss,
+		dashboardURL: dashboardURL,
+		client:       webhooks.NewClient(postTimeout),
+		logger:       logger,
+	}
+}
+
+// chatReview is a new review as posted to channels.
+type chatReview struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"product_id"`
+	Product   string    `json:"product_name"`
+	Rating    uint      `json:"rating"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+}
+
+// Publish posts a new review to the channels of its company. When some of
+// the posts fail, the outbox publishes the event again and only the channels
+// that were not posted to yet are.
+func (p *Poster) Publish(ctx context.Context, event outbox.Event) error {
+	if event.Type != storage.EventReviewCreated {
+		return nil
+	}
+	var rev reviewData
+	if err := json.Unmarshal(event.Data, &rev); err != nil {
+		return err
+	}
+	channels, err := p.channels.List(ctx, rev.CompanyID)
+	if err != nil || len(channels) == 0 {
+		return err
+	}
+
+	review := chatReview{
+		ID:        rev.ID,
+		ProductID: rev.ProductID,
+		Product:   "a deleted product",
+		Rating:    rev.Rating,
+		Comment:   snippet(rev.Comment),
+		CreatedAt: rev.CreatedAt,
+		URL:       fmt.Sprintf("https://%s/reviews/%s", p.dashboardURL, rev.ID),
+	}
+	if product, err := p.products.Find(ctx, rev.ProductID); err == nil {
+		review.Product = product.ProductName
+	}
+	byID := map[string]*storage.NotificationChannelModel{}
+	ids := make([]string, len(channels))
+	for i := range channels {
+		ids[i] = channels[i].ID.String()
+		byID[ids[i]] = &channels[i]
+	}
+	return sendOnce(ctx, p.sent, p.logger, event.ID, ids, func(id string) error {
+		err := p.post(ctx, byID[id], review)
+		if err != nil {
+			p.logger.Warn("Notification channel error", "company_id", rev.CompanyID, "channel_id", id, "error", err)
+		}
+		return err
+	})
+}
+
+func (p *Poster) post(ctx context.Context, channel *storage.NotificationChannelModel, review chatReview) error {
+	body, err := json.Marshal(message(channel.Format, review))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("channel responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// summaryLine describes a review in one line of plain text.
+func summaryLine(review chatReview) string {
+	return fmt.Sprintf("New %d★ review of %s", review.Rating, review.Product)
+}
+
+// message formats a review for a channel.
+func message(format string, review chatReview) interface{} {
+	switch format {
+	case storage.ChannelSlack:
+		return slackMessage(review)
+	case storage.ChannelTeams:
+		return teamsMessage(review)
+	}
+	return map[string]interface{}{
+		"event":  storage.EventReviewCreated,
+		"text":   summaryLine(review),
+		"review": review,
+	}
+}
+
+// slackMessage builds Block Kit blocks, with text as the fallback of
+// notifications.
+func slackMessage(review chatReview) interface{} {
+	fields := fmt.Sprintf("*New review of %s*\n%s  %d out of 5", slackEscape(review.Product), stars(review.Rating), review.Rating)
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": fields},
+		},
+	}
+	if review.Comment != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": "> " + slackEscape(review.Comment)},
+		})
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type": "actions",
+		"elements": []interface{}{
+			map[string]interface{}{
+				"type": "button",
+				"text": map[string]string{"type": "plain_text", "text": "View review"},
+				"url":  review.URL,
+			},
+		},
+	})
+	return map[string]interface{}{
+		"text":   summaryLine(review),
+		"blocks": blocks,
+	}
+}
+
+// slackEscape escapes the characters that mrkdwn uses for links and
+// mentions.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// teamsMessage builds an Adaptive Card, which Teams workflows and incoming
+// webhooks accept.
+func teamsMessage(review chatReview) interface{} {
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": "New review of " + review.Product, "weight": "Bolder", "size": "Medium", "wrap": true},
+		map[string]interface{}{"type": "TextBlock", "text": fmt.Sprintf("%s  %d out of 5", stars(review.Rating), review.Rating), "color": "Warning"},
+	}
+	if review.Comment != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": review.Comment, "wrap": true, "isSubtle": true})
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+					"actions": []interface{}{
+						map[string]interface{}{"type": "Action.OpenUrl", "title": "View review", "url": review.URL},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/storage"
+	"api.proddx.com/webhooks"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestPoster(t *testing.T) {
+	var mu sync.Mutex
+	posts := map[string]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Error: Expected JSON to %s: %s", r.URL.Path, body)
+		}
+		mu.Lock()
+		posts[r.URL.Path] = payload
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	tc := newTestCompany(t)
+	channels := new(storage.NotificationChannelMemoryStore)
+	for _, path := range []string{"/broken", "/" + storage.ChannelSlack, "/" + storage.ChannelTeams, "/" + storage.ChannelJSON} {
+		format := strings.TrimPrefix(path, "/")
+		if path == "/broken" {
+			format = storage.ChannelJSON
+		}
+		cm := &storage.NotificationChannelModel{ID: uuid.NewV4(), CompanyID: tc.id, URL: srv.URL + path, Format: format, CreatedAt: time.Now()}
+		if err := channels.Save(context.Background(), cm); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	poster := NewPoster(channels, tc.stores.Products, tc.stores.Sent, "app.proddx.com", logging.Default())
+	event := tc.review(t, 2, "Stopped working <after> a week & more", time.Now())
+	if err := poster.Publish(context.Background(), event); !errors.Is(err, webhooks.ErrForbiddenAddress) {
+		t.Fatalf("Error: Expected channels on private addresses to be refused: %v", err)
+	}
+	poster.client = srv.Client()
+
+	if err := poster.Publish(context.Background(), event); err == nil {
+		t.Error("Error: Expected the failed post to be reported")
+	}
+	if len(posts) != 3 {
+		t.Fatalf("Error: Expected a post to each working channel: %v", posts)
+	}
+
+	slack, _ := json.Marshal(posts["/slack"])
+	for _, expected := range []string{`"text":"New 2★ review of Product One"`, "★★☆☆☆", "Stopped working \\u0026lt;after\\u0026gt; a week \\u0026amp; more", `"type":"button"`, "https://app.proddx.com/reviews/"} {
+		if !strings.Contains(string(slack), expected) {
+			t.Errorf("Error: Expected %s in the Slack message: %s", expected, slack)
+		}
+	}
+	teams, _ := json.Marshal(posts["/teams"])
+	for _, expected := range []string{"application/vnd.microsoft.card.adaptive", "AdaptiveCard", "New review of Product One", "Action.OpenUrl"} {
+		if !strings.Contains(string(teams), expected) {
+			t.Errorf("Error: Expected %s in the Teams card: %s", expected, teams)
+		}
+	}
+	review, _ := posts["/json"]["review"].(map[string]interface{})
+	if posts["/json"]["event"] != storage.EventReviewCreated || review["product_name"] != "Product One" || review["rating"] != 2.0 {
+		t.Errorf("Error: Unexpected JSON message: %v", posts["/json"])
+	}
+
+	// Publishing the event again only posts to the channel that failed.
+	posts = map[string]map[string]interface{}{}
+	if err := poster.Publish(context.Background(), event); err == nil {
+		t.Error("Error: Expected the failed post to be reported again")
+	}
+	if len(posts) != 0 {
+		t.Errorf("Error: Expected the working channels not to be posted to twice: %v", posts)
+	}
+
+	event.Type = storage.EventReviewUpdated
+	poster.Publish(context.Background(), event)
+	if len(posts) != 0 {
+		t.Errorf("Error: Expected only new reviews to be posted: %v", posts)
+	}
+}
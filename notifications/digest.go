@@ -0,0 +1,135 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"api.proddx.com/storage"
+)
+
+// digestReviews is how many reviews a digest lists, lowest rated first.
+const digestReviews = 10
+
+// Run sends the digests that are due until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := n.SendDigests(ctx); err != nil && ctx.Err() == nil {
+			n.logger.Error("Digest error", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDigests emails the digests that are due and returns how many it sent.
+// A digest is claimed before it is sent, so one that fails to send is
+// skipped rather than sent twice by several schedulers.
+func (n *Notifier) SendDigests(ctx context.Context) (int, error) {
+	now := n.now()
+	due, err := n.stores.Preferences.ClaimDigests(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, prefs := range due {
+		ok, err := n.sendDigest(ctx, &prefs, now)
+		if err != nil {
+			n.logger.Error("Digest error", "company_id", prefs.CompanyID, "error", err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest emails a digest of the reviews from prefs.DigestedAt to now, if
+// there are any.
+func (n *Notifier) sendDigest(ctx context.Context, prefs *storage.NotificationPreferenceModel, now time.Time) (bool, error) {
+	companyID := prefs.CompanyID.String()
+	reviews, err := n.stores.Reviews.List(ctx, companyID, "")
+	if err != nil {
+		return false, err
+	}
+	data := digestMail{
+		Company:   n.companyName(ctx, companyID),
+		Frequency: prefs.Frequency,
+		From:      prefs.DigestedAt,
+		To:        now,
+		URL:       fmt.Sprintf("https://%s/reviews", n.dashboardURL),
+	}
+	products := map[string]string{}
+	productStats := map[string]*productSummary{}
+	total := uint(0)
+	for _, model := range reviews {
+		if model.CreatedAt.Before(prefs.DigestedAt) || !model.CreatedAt.Before(now) {
+			continue
+		}
+		summary := n.summary(ctx, reviewData{
+			ProductID: model.ProductID.String(),
+			Comment:   model.Comment,
+			Rating:    model.Rating,
+			CreatedAt: model.CreatedAt,
+		}, products)
+		data.Reviews = append(data.Reviews, summary)
+		total += model.Rating
+		stats, ok := productStats[summary.Product]
+		if !ok {
+			stats = &productSummary{Name: summary.Product}
+			productStats[summary.Product] = stats
+		}
+		stats.Count++
+		stats.total += model.Rating
+	}
+	data.Count = len(data.Reviews)
+	if data.Count == 0 {
+		return false, nil
+	}
+	recipients, err := n.recipients(ctx, companyID)
+	if err != nil || len(recipients) == 0 {
+		return false, err
+	}
+
+	data.Average = average(total, data.Count)
+	for _, stats := range productStats {
+		stats.Average = average(stats.total, stats.Count)
+		data.Products = append(data.Products, *stats)
+	}
+	sort.Slice(data.Products, func(i, j int) bool {
+		if data.Products[i].Count != data.Products[j].Count {
+			return data.Products[i].Count > data.Products[j].Count
+		}
+		return data.Products[i].Name < data.Products[j].Name
+	})
+	sort.SliceStable(data.Reviews, func(i, j int) bool {
+		if data.Reviews[i].Rating != data.Reviews[j].Rating {
+			return data.Reviews[i].Rating < data.Reviews[j].Rating
+		}
+		return data.Reviews[i].CreatedAt.After(data.Reviews[j].CreatedAt)
+	})
+	if len(data.Reviews) > digestReviews {
+		data.Reviews = data.Reviews[:digestReviews]
+	}
+
+	reviewsWord := "reviews"
+	if data.Count == 1 {
+		reviewsWord = "review"
+	}
+	msg, err := render("digest", fmt.Sprintf("Your %s review digest: %d new %s", prefs.Frequency, data.Count, reviewsWord), data)
+	if err != nil {
+		return false, err
+	}
+	return true, n.send(ctx, "", msg, recipients)
+}
+
+func average(total uint, count int) string {
+	return fmt.Sprintf("%.1f", float64(total)/float64(count))
+}
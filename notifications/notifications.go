@@ -0,0 +1,248 @@
+// Package notifications tells companies about new reviews: it emails their
+// owners, as reviews arrive or in digests according to each company's
+// preferences, and posts reviews to their chat channels.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/mail"
+	"api.proddx.com/outbox"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// DefaultThreshold is the rating that reviews must be rated below to be
+	// notified of, unless a company chooses otherwise.
+	DefaultThreshold = 3
+	// DigestInterval is how often due digests are looked for.
+	DigestInterval = 5 * time.Minute
+
+	// ownerRole is the role of the members that are notified.
+	ownerRole = "owner"
+)
+
+// Default returns the preferences of a company that has not saved any.
+func Default(companyID uuid.UUID) *storage.NotificationPreferenceModel {
+	return &storage.NotificationPreferenceModel{
+		CompanyID: companyID,
+		Mode:      storage.NotifyBelowThreshold,
+		Threshold: DefaultThreshold,
+		Frequency: storage.DigestDaily,
+	}
+}
+
+// Stores are the stores a Notifier reads from.
+type Stores struct {
+	Preferences storage.NotificationPreference
+	Members     storage.Member
+	Users       storage.User
+	Companies   storage.Company
+	Products    storage.Product
+	Reviews     storage.Review
+	Sent        storage.SentNotification
+}
+
+// Notifier emails new reviews as an outbox sink and sends the digests that
+// are due in the background.
+type Notifier struct {
+	stores       Stores
+	mailer       mail.Mailer
+	dashboardURL string
+	logger       *logging.Logger
+
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewNotifier links the emails it sends to the dashboard at dashboardURL, a
+// host.
+func NewNotifier(stores Stores, mailer mail.Mailer, dashboardURL string, logger *logging.Logger) *Notifier {
+	return &Notifier{
+		stores:       stores,
+		mailer:       mailer,
+		dashboardURL: dashboardURL,
+		logger:       logger,
+		interval:     DigestInterval,
+		now:          time.Now,
+	}
+}
+
+type reviewData struct {
+	ID        string    `json:"id"`
+	CompanyID string    `json:"company_id"`
+	ProductID string    `json:"product_id"`
+	Comment   string    `json:"comment"`
+	Rating    uint      `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Publish emails the owners of a company about a new review if its
+// preferences ask for it. When some of the emails fail, the outbox publishes
+// the event again and only the owners who were not emailed yet are.
+func (n *Notifier) Publish(ctx context.Context, event outbox.Event) error {
+	if event.Type != storage.EventReviewCreated {
+		return nil
+	}
+	var rev reviewData
+	if err := json.Unmarshal(event.Data, &rev); err != nil {
+		return err
+	}
+	prefs, err := n.preferences(ctx, rev.CompanyID)
+	if err != nil {
+		return err
+	}
+	if !notify(prefs, rev.Rating) {
+		return nil
+	}
+	recipients, err := n.recipients(ctx, rev.CompanyID)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	data := reviewMail{
+		Company: n.companyName(ctx, rev.CompanyID),
+		Review:  n.summary(ctx, rev, nil),
+		URL:     fmt.Sprintf("https://%s/reviews/%s", n.dashboardURL, rev.ID),
+	}
+	msg, err := render("review", fmt.Sprintf("New %d★ review of %s", rev.Rating, data.Review.Product), data)
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, event.ID, msg, recipients)
+}
+
+// notify reports whether a review is emailed as it arrives.
+func notify(prefs *storage.NotificationPreferenceModel, rating uint) bool {
+	switch prefs.Mode {
+	case storage.NotifyEvery:
+		return true
+	case storage.NotifyBelowThreshold:
+		return rating < prefs.Threshold
+	}
+	return false
+}
+
+func (n *Notifier) preferences(ctx context.Context, companyID string) (*storage.NotificationPreferenceModel, error) {
+	prefs, err := n.stores.Preferences.Find(ctx, companyID)
+	if err != nil || prefs != nil {
+		return prefs, err
+	}
+	return Default(uuid.FromStringOrNil(companyID)), nil
+}
+
+// recipients returns the verified email addresses of the owners of a
+// company.
+func (n *Notifier) recipients(ctx context.Context, companyID string) ([]string, error) {
+	members, err := n.stores.Members.List(ctx, companyID, "")
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, member := range members {
+		if member.Role != ownerRole {
+			continue
+		}
+		user, err := n.stores.Users.Find(ctx, member.UserID.String())
+		if err != nil {
+			n.logger.Warn("Notification recipient not found", "company_id", companyID, "user_id", member.UserID, "error", err)
+			continue
+		}
+		if user.EmailVerified {
+			emails = append(emails, user.Email)
+		}
+	}
+	return emails, nil
+}
+
+func (n *Notifier) companyName(ctx context.Context, companyID string) string {
+	company, err := n.stores.Companies.Find(ctx, companyID)
+	if err != nil {
+		return ""
+	}
+	return company.CompanyName
+}
+
+// summary describes a review for emails, looking up its product name unless
+// products already has it.
+func (n *Notifier) summary(ctx context.Context, rev reviewData, products map[string]string) reviewSummary {
+	name, ok := products[rev.ProductID]
+	if !ok {
+		if product, err := n.stores.Products.Find(ctx, rev.ProductID); err == nil {
+			name = product.ProductName
+		}
+		if products != nil {
+			products[rev.ProductID] = name
+		}
+	}
+	if name == "" {
+		name = "a deleted product"
+	}
+	return reviewSummary{
+		Product:   name,
+		Rating:    rev.Rating,
+		Comment:   snippet(rev.Comment),
+		CreatedAt: rev.CreatedAt,
+	}
+}
+
+// send emails msg to each of recipients. eventID is empty for emails about
+// no event, which are not recorded.
+func (n *Notifier) send(ctx context.Context, eventID string, msg mail.Message, recipients []string) error {
+	email := func(to string) error {
+		msg.To = to
+		return n.mailer.Send(msg)
+	}
+	if eventID == "" {
+		return sendEach(recipients, email)
+	}
+	return sendOnce(ctx, n.stores.Sent, n.logger, eventID, recipients, email)
+}
+
+// sendEach calls send for each of recipients, going on past those it fails
+// for.
+func sendEach(recipients []string, send func(recipient string) error) error {
+	failed := 0
+	var sendErr error
+	for _, recipient := range recipients {
+		if err := send(recipient); err != nil {
+			failed++
+			sendErr = err
+		}
+	}
+	if sendErr != nil {
+		return fmt.Errorf("failed to notify %d of %d recipients: %w", failed, len(recipients), sendErr)
+	}
+	return nil
+}
+
+// sendOnce is sendEach for the notifications of an event, which are recorded
+// in sent as they succeed, so that each recipient is notified once however
+// often the event is published.
+func sendOnce(ctx context.Context, sent storage.SentNotification, logger *logging.Logger, eventID string, recipients []string, send func(recipient string) error) error {
+	var due []string
+	for _, recipient := range recipients {
+		ok, err := sent.Exists(ctx, eventID, recipient)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			due = append(due, recipient)
+		}
+	}
+	return sendEach(due, func(recipient string) error {
+		if err := send(recipient); err != nil {
+			return err
+		}
+		sm := &storage.SentNotificationModel{EventID: uuid.FromStringOrNil(eventID), Recipient: recipient, SentAt: time.Now()}
+		if err := sent.Save(ctx, sm); err != nil {
+			logger.Error("Notification storage error", "event_id", eventID, "error", err)
+		}
+		return nil
+	})
+}
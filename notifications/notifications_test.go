@@ -0,0 +1,222 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/mail"
+	"api.proddx.com/outbox"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+// mailRecorder fails to send to the addresses in failing.
+type mailRecorder struct {
+	mu       sync.Mutex
+	messages []mail.Message
+	failing  map[string]bool
+}
+
+func (mr *mailRecorder) Send(msg mail.Message) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if mr.failing[msg.To] {
+		return errors.New("mailbox unavailable")
+	}
+	mr.messages = append(mr.messages, msg)
+	return nil
+}
+
+type testCompany struct {
+	id       uuid.UUID
+	product  *storage.ProductModel
+	notifier *Notifier
+	mailer   *mailRecorder
+	stores   Stores
+}
+
+// newTestCompany has a verified owner, an owner whose email is not
+// verified, an admin and a product.
+func newTestCompany(t *testing.T) *testCompany {
+	ctx := context.Background()
+	tc := &testCompany{
+		id:     uuid.NewV4(),
+		mailer: new(mailRecorder),
+		stores: Stores{
+			Preferences: new(storage.NotificationPreferenceMemoryStore),
+			Members:     new(storage.MemberMemoryStore),
+			Users:       new(storage.UserMemoryStore),
+			Companies:   new(storage.CompanyMemoryStore),
+			Products:    new(storage.ProductMemoryStore),
+			Reviews:     new(storage.ReviewMemoryStore),
+			Sent:        new(storage.SentNotificationMemoryStore),
+		},
+	}
+	for _, user := range []struct {
+		email    string
+		role     string
+		verified bool
+	}{
+		{"owner@domain.com", ownerRole, true},
+		{"unverified@domain.com", ownerRole, false},
+		{"admin@domain.com", "admin", true},
+	} {
+		um := &storage.UserModel{ID: uuid.NewV4(), Email: user.email, EmailVerified: user.verified, CreatedAt: time.Now()}
+		if err := tc.stores.Users.Save(ctx, um); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if err := tc.stores.Members.Save(ctx, &storage.MemberModel{CompanyID: tc.id, UserID: um.ID, Role: user.role, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if err := tc.stores.Companies.Save(ctx, &storage.CompanyModel{ID: tc.id, CompanyName: "Company One", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	tc.product = &storage.ProductModel{ID: uuid.NewV4(), CompanyID: tc.id, ProductName: "Product One", CreatedAt: time.Now()}
+	if err := tc.stores.Products.Save(ctx, tc.product); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	tc.notifier = NewNotifier(tc.stores, tc.mailer, "app.proddx.com", logging.Default())
+	return tc
+}
+
+func (tc *testCompany) review(t *testing.T, rating uint, comment string, createdAt time.Time) outbox.Event {
+	rm := &storage.ReviewModel{ID: uuid.NewV4(), CompanyID: tc.id, ProductID: tc.product.ID, Comment: comment, Rating: rating, CreatedAt: createdAt}
+	if err := tc.stores.Reviews.Save(context.Background(), rm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	data, _ := json.Marshal(reviewData{ID: rm.ID.String(), CompanyID: tc.id.String(), ProductID: tc.product.ID.String(), Comment: comment, Rating: rating, CreatedAt: createdAt})
+	return outbox.Event{ID: uuid.NewV4().String(), Type: storage.EventReviewCreated, CompanyID: tc.id.String(), CreatedAt: createdAt, Data: data}
+}
+
+func TestPublish(t *testing.T) {
+	tc := newTestCompany(t)
+
+	for _, test := range []struct {
+		prefs    *storage.NotificationPreferenceModel
+		rating   uint
+		expected int
+	}{
+		{nil, 2, 1},
+		{nil, 5, 0},
+		{&storage.NotificationPreferenceModel{CompanyID: tc.id, Mode: storage.NotifyEvery, Frequency: storage.DigestDaily}, 5, 1},
+		{&storage.NotificationPreferenceModel{CompanyID: tc.id, Mode: storage.NotifyBelowThreshold, Threshold: 2, Frequency: storage.DigestDaily}, 2, 0},
+		{&storage.NotificationPreferenceModel{CompanyID: tc.id, Mode: storage.NotifyDigest, Frequency: storage.DigestDaily}, 2, 0},
+		{&storage.NotificationPreferenceModel{CompanyID: tc.id, Mode: storage.NotifyOff, Frequency: storage.DigestDaily}, 2, 0},
+	} {
+		if test.prefs != nil {
+			if err := tc.stores.Preferences.Save(context.Background(), test.prefs); err != nil {
+				t.Fatalf("Error: %s", err.Error())
+			}
+		}
+		tc.mailer.messages = nil
+		if err := tc.notifier.Publish(context.Background(), tc.review(t, test.rating, "Review", time.Now())); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if len(tc.mailer.messages) != test.expected {
+			t.Errorf("Error: Expected %d emails with %+v: %v", test.expected, test.prefs, tc.mailer.messages)
+		}
+	}
+
+	tc.mailer.messages = nil
+	tc.stores.Preferences.Save(context.Background(), Default(tc.id))
+	if err := tc.notifier.Publish(context.Background(), tc.review(t, 2, "Broke after a week", time.Now())); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	msg := tc.mailer.messages[0]
+	if msg.To != "owner@domain.com" || msg.Subject != "New 2★ review of Product One" {
+		t.Errorf("Error: Unexpected email %s %s", msg.To, msg.Subject)
+	}
+	for _, body := range []string{msg.Text, msg.HTML} {
+		if !strings.Contains(body, "★★☆☆☆") || !strings.Contains(body, "Broke after a week") || !strings.Contains(body, "https://app.proddx.com/reviews/") {
+			t.Errorf("Error: Unexpected body %s", body)
+		}
+	}
+}
+
+func TestPublishOncePerRecipient(t *testing.T) {
+	tc := newTestCompany(t)
+	um := &storage.UserModel{ID: uuid.NewV4(), Email: "partner@domain.com", EmailVerified: true, CreatedAt: time.Now()}
+	if err := tc.stores.Users.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := tc.stores.Members.Save(context.Background(), &storage.MemberModel{CompanyID: tc.id, UserID: um.ID, Role: ownerRole, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	event := tc.review(t, 1, "Broken", time.Now())
+
+	// A failing address does not keep the others from being emailed.
+	tc.mailer.failing = map[string]bool{"owner@domain.com": true}
+	if err := tc.notifier.Publish(context.Background(), event); err == nil {
+		t.Error("Error: Expected the failed email to be reported")
+	}
+	if len(tc.mailer.messages) != 1 || tc.mailer.messages[0].To != "partner@domain.com" {
+		t.Fatalf("Error: Expected the other owner to be emailed: %v", tc.mailer.messages)
+	}
+
+	// Publishing the event again only emails the owner who was not emailed.
+	tc.mailer.failing = nil
+	for i := 0; i < 2; i++ {
+		if err := tc.notifier.Publish(context.Background(), event); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if len(tc.mailer.messages) != 2 || tc.mailer.messages[1].To != "owner@domain.com" {
+		t.Errorf("Error: Expected each owner to be emailed once: %v", tc.mailer.messages)
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	tc := newTestCompany(t)
+	now := time.Now()
+	tc.notifier.now = func() time.Time { return now }
+	prefs := &storage.NotificationPreferenceModel{CompanyID: tc.id, Mode: storage.NotifyDigest, Frequency: storage.DigestDaily, DigestedAt: now.Add(-24 * time.Hour)}
+	if err := tc.stores.Preferences.Save(context.Background(), prefs); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	tc.review(t, 1, "Arrived <broken>", now.Add(-2*time.Hour))
+	tc.review(t, 4, "Works well", now.Add(-time.Hour))
+	tc.review(t, 5, "From the previous digest", now.Add(-25*time.Hour))
+
+	sent, err := tc.notifier.SendDigests(context.Background())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if sent != 1 || len(tc.mailer.messages) != 1 {
+		t.Fatalf("Error: Expected one digest: %d %v", sent, tc.mailer.messages)
+	}
+	msg := tc.mailer.messages[0]
+	if msg.Subject != "Your daily review digest: 2 new reviews" {
+		t.Errorf("Error: Unexpected subject %s", msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "rated 2.5 on average") || !strings.Contains(msg.Text, "Product One: 2 reviews") || strings.Contains(msg.Text, "previous digest") {
+		t.Errorf("Error: Unexpected text %s", msg.Text)
+	}
+	if strings.Index(msg.Text, "Arrived") > strings.Index(msg.Text, "Works well") {
+		t.Errorf("Error: Expected the lowest rated reviews first: %s", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, "Arrived &lt;broken&gt;") {
+		t.Errorf("Error: Expected comments to be escaped: %s", msg.HTML)
+	}
+
+	sent, _ = tc.notifier.SendDigests(context.Background())
+	if sent != 0 {
+		t.Errorf("Error: Expected the digest to be sent once: %d", sent)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	if s := snippet(long); len([]rune(s)) > snippetLength+1 || !strings.HasSuffix(s, "word…") {
+		t.Errorf("Error: Unexpected snippet %q", s)
+	}
+	if s := snippet("  Short\n comment "); s != "Short comment" {
+		t.Errorf("Error: Unexpected snippet %q", s)
+	}
+}
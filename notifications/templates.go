@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	"api.proddx.com/mail"
+)
+
+// snippetLength is how many characters of a comment emails quote.
+const snippetLength = 200
+
+//go:embed templates
+var files embed.FS
+
+var funcs = map[string]interface{}{
+	"stars": stars,
+	"date": func(t time.Time) string {
+		return t.UTC().Format("Jan 2, 2006")
+	},
+}
+
+// Each email has a text template and an HTML template of the same name.
+var (
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(funcs).ParseFS(files, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(funcs).ParseFS(files, "templates/*.html"))
+)
+
+type reviewSummary struct {
+	Product   string
+	Rating    uint
+	Comment   string
+	CreatedAt time.Time
+}
+
+type reviewMail struct {
+	Company string
+	Review  reviewSummary
+	URL     string
+}
+
+type productSummary struct {
+	Name    string
+	Count   int
+	Average string
+
+	total uint
+}
+
+type digestMail struct {
+	Company   string
+	Frequency string
+	From      time.Time
+	To        time.Time
+	Count     int
+	Average   string
+	Products  []productSummary
+	Reviews   []reviewSummary
+	URL       string
+}
+
+// render executes the templates of an email.
+func render(name string, subject string, data interface{}) (mail.Message, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return mail.Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return mail.Message{}, err
+	}
+	return mail.Message{Subject: subject, Text: text.String(), HTML: html.String()}, nil
+}
+
+// stars draws a rating out of five.
+func stars(rating uint) string {
+	if rating > 5 {
+		rating = 5
+	}
+	return strings.Repeat("★", int(rating)) + strings.Repeat("☆", 5-int(rating))
+}
+
+// snippet shortens a comment to snippetLength characters at a word boundary.
+func snippet(comment string) string {
+	comment = strings.Join(strings.Fields(comment), " ")
+	if utf8.RuneCountInString(comment) <= snippetLength {
+		return comment
+	}
+	runes := []rune(comment)[:snippetLength]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > snippetLength/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
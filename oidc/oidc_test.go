@@ -0,0 +1,107 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"api.proddx.com/oidc/oidctest"
+)
+
+var noRedirects = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// authorize follows the provider's login page back to the redirect URL and
+// returns the authorization code and state it carries.
+func authorize(t *testing.T, authURL string) (string, string) {
+	res, err := noRedirects.Get(authURL)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("Error: Expected a redirect from the provider: %d", res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func testProvider(server *oidctest.Server) *Provider {
+	return NewProvider(Config{
+		Issuer:       server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  "https://api.proddx.com/auth/oidc/test/callback",
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	server := oidctest.NewServer("proddx", "secret")
+	defer server.Close()
+	server.Email = "sso@domain.com"
+	provider := testProvider(server)
+
+	verifier, _ := RandomString()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	code, state := authorize(t, authURL)
+	if state != "state" {
+		t.Errorf("Error: State inconsistency: %s - %s", state, "state")
+	}
+
+	claims, err := provider.Authenticate(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if claims.Email != "sso@domain.com" || !claims.EmailVerified || claims.Subject != server.Subject {
+		t.Errorf("Error: Unexpected claims: %v", claims)
+	}
+
+	if _, err = provider.Authenticate(context.Background(), code, verifier, "nonce"); err == nil {
+		t.Error("Error: Expected an authorization code to be redeemable once")
+	}
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	server := oidctest.NewServer("proddx", "secret")
+	defer server.Close()
+	provider := testProvider(server)
+
+	cases := map[string]func(verifier string) (string, string){
+		"wrong verifier": func(verifier string) (string, string) { return verifier + "x", "nonce" },
+		"wrong nonce":    func(verifier string) (string, string) { return verifier, "other" },
+	}
+	for name, alter := range cases {
+		verifier, _ := RandomString()
+		authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		code, _ := authorize(t, authURL)
+		verifier, nonce := alter(verifier)
+		if _, err = provider.Authenticate(context.Background(), code, verifier, nonce); err == nil {
+			t.Errorf("Error: Expected %s to be rejected", name)
+		}
+	}
+
+	wrongClient := NewProvider(Config{Issuer: server.URL, ClientID: "other", RedirectURL: "https://api.proddx.com/callback"})
+	verifier, _ := RandomString()
+	authURL, _ := wrongClient.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	res, err := noRedirects.Get(authURL)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Error: Expected an unknown client to be rejected: %d", res.StatusCode)
+	}
+}
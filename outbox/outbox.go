@@ -0,0 +1,208 @@
+// Package outbox relays the events that the storage layer writes with each
+// change to reviews and products to sinks such as webhooks, the log or a
+// NATS server. Since events are written in the transaction of the change,
+// none are lost when the server stops between saving and publishing.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/storage"
+)
+
+// Defaults of a Dispatcher. With these a delivery is attempted for about
+// three hours before it is marked as failed.
+const (
+	PollInterval = time.Second
+	MaxAttempts  = 10
+	BaseBackoff  = 30 * time.Second
+	MaxBackoff   = time.Hour
+	// Retention is how long events are kept after they were written, for
+	// inspecting their deliveries.
+	Retention = 7 * 24 * time.Hour
+)
+
+const (
+	batchSize      = 100
+	publishTimeout = 10 * time.Second
+	// claimLease outlasts publishTimeout, so that no other dispatcher
+	// publishes a claimed delivery meanwhile.
+	claimLease    = 2 * publishTimeout
+	pruneInterval = time.Hour
+)
+
+// Event is an event as sinks publish it. Events are published at least
+// once, so sinks and their consumers may see an event again, with the same
+// ID.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CompanyID string          `json:"company_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sink publishes events somewhere. Each sink has its own queue of
+// deliveries, so an event that a sink fails to publish is retried on that
+// sink alone.
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Dispatcher passes the events in the outbox on to its sinks, which it knows
+// by name. Each sink receives the events of a company in the order they
+// were written: when an event fails, it is retried with exponential backoff
+// and the later events of its company wait for it on that sink, while other
+// companies and sinks carry on. After MaxAttempts the delivery is marked as
+// failed and the later events go through. Several dispatchers may share the
+// same outbox.
+type Dispatcher struct {
+	events storage.Event
+	sinks  map[string]Sink
+	names  []string
+	logger *logging.Logger
+
+	interval    time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	now         func() time.Time
+}
+
+func NewDispatcher(events storage.Event, logger *logging.Logger, sinks map[string]Sink) *Dispatcher {
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Dispatcher{
+		events:      events,
+		sinks:       sinks,
+		names:       names,
+		logger:      logger,
+		interval:    PollInterval,
+		maxAttempts: MaxAttempts,
+		backoff:     BaseBackoff,
+		maxBackoff:  MaxBackoff,
+		now:         time.Now,
+	}
+}
+
+// Run passes events on until ctx is done, and removes events once they are
+// older than Retention.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	var pruned time.Time
+	for {
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				d.logger.Error("Event dispatch error", "error", err)
+			}
+			// Each batch takes one event of a company to a sink, so
+			// dispatching goes on until nothing is due.
+			if n == 0 || err != nil {
+				break
+			}
+		}
+		if now := d.now(); now.Sub(pruned) >= pruneInterval {
+			if _, err := d.events.Prune(ctx, now.Add(-Retention)); err != nil && ctx.Err() == nil {
+				d.logger.Error("Event prune error", "error", err)
+			}
+			pruned = now
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch queues the deliveries of new events, then claims a batch of due
+// deliveries, attempts each once and returns how many it claimed. Claims
+// are saved before any sink is called, and the deliveries of a batch are
+// attempted concurrently since each is for a different company or sink.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	if _, err := d.events.Enqueue(ctx, d.names, batchSize); err != nil {
+		return 0, err
+	}
+	claimed, err := d.events.Claim(ctx, d.now(), claimLease, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	errs := make([]error, len(claimed))
+	var wg sync.WaitGroup
+	for i := range claimed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = d.attempt(ctx, &claimed[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// attempt publishes a delivery and saves its outcome. Only errors saving it
+// are returned, since failed attempts are recorded in the delivery.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *storage.EventDeliveryModel) error {
+	now := d.now()
+	delivery.Attempts++
+	delivery.LastError = ""
+	sink, ok := d.sinks[delivery.Sink]
+	err := errors.New("sink is not configured")
+	if ok {
+		publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err = sink.Publish(publishCtx, eventFromStorage(&delivery.Event))
+		cancel()
+	}
+	switch {
+	case err == nil:
+		delivery.Status = storage.DeliverySucceeded
+	case delivery.Attempts >= d.maxAttempts || !ok:
+		delivery.Status = storage.DeliveryFailed
+		delivery.LastError = err.Error()
+		d.logger.Error("Event delivery failed for good", "event_id", delivery.Event.ID, "sink", delivery.Sink, "attempts", delivery.Attempts, "error", err)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.retryAfter(delivery.Attempts))
+		d.logger.Warn("Event delivery failed", "event_id", delivery.Event.ID, "sink", delivery.Sink, "attempts", delivery.Attempts, "error", err)
+	}
+	return d.events.SaveDelivery(ctx, delivery)
+}
+
+// retryAfter doubles the backoff with every failed attempt, up to the
+// maximum.
+func (d *Dispatcher) retryAfter(attempts int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.maxBackoff {
+		backoff = d.maxBackoff
+	}
+	return backoff
+}
+
+func eventFromStorage(model *storage.EventModel) Event {
+	return Event{
+		ID:        model.ID.String(),
+		Type:      model.EventType,
+		CompanyID: model.CompanyID.String(),
+		CreatedAt: model.CreatedAt,
+		Data:      model.Data,
+	}
+}
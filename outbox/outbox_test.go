@@ -0,0 +1,172 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+// recorder is a sink that records the events it publishes, and fails as
+// many publishes of the events of a company as it is told to.
+type recorder struct {
+	mu        sync.Mutex
+	published []Event
+	fail      map[string]int
+}
+
+func (rc *recorder) Publish(ctx context.Context, event Event) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.fail[event.CompanyID] > 0 {
+		rc.fail[event.CompanyID]--
+		return errors.New("unavailable")
+	}
+	rc.published = append(rc.published, event)
+	return nil
+}
+
+// ratings returns the ratings of the reviews of the events published for a
+// company, in order.
+func (rc *recorder) ratings(companyID uuid.UUID) []uint {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var ratings []uint
+	for _, event := range rc.published {
+		if event.CompanyID == companyID.String() {
+			var data struct{ Rating uint }
+			json.Unmarshal(event.Data, &data)
+			ratings = append(ratings, data.Rating)
+		}
+	}
+	return ratings
+}
+
+func saveReviews(t *testing.T, rs storage.Review, companyID uuid.UUID, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		rm := &storage.ReviewModel{
+			ID:        uuid.NewV4(),
+			CompanyID: companyID,
+			ProductID: uuid.NewV4(),
+			Comment:   "Lorem ipsum dolor sit amet",
+			Rating:    uint(i + 1),
+			CreatedAt: time.Now(),
+		}
+		if err := rs.Save(context.Background(), rm); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+}
+
+func testDispatcher(events storage.Event, sinks map[string]Sink) (*Dispatcher, *time.Time) {
+	logger, _ := logging.New(ioutil.Discard, logging.Logfmt, logging.Error)
+	d := NewDispatcher(events, logger, sinks)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	return d, &now
+}
+
+// drain dispatches until no delivery is due.
+func drain(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		n, err := d.Dispatch(context.Background())
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if n == 0 {
+			return
+		}
+	}
+	t.Fatal("Error: Deliveries are still due")
+}
+
+func TestDispatch(t *testing.T) {
+	events := new(storage.EventMemoryStore)
+	reviewStore := &storage.ReviewMemoryStore{Events: events}
+	first, second := uuid.NewV4(), uuid.NewV4()
+	saveReviews(t, reviewStore, first, 3)
+	saveReviews(t, reviewStore, second, 2)
+
+	flaky := &recorder{fail: map[string]int{first.String(): 1}}
+	steady := new(recorder)
+	d, now := testDispatcher(events, map[string]Sink{"flaky": flaky, "steady": steady})
+	drain(t, d)
+
+	// The failed event holds up the later events of its company on its sink
+	// alone.
+	if fmt.Sprint(steady.ratings(first)) != "[1 2 3]" || fmt.Sprint(steady.ratings(second)) != "[1 2]" {
+		t.Errorf("Error: Expected every event on the steady sink: %v - %v", steady.ratings(first), steady.ratings(second))
+	}
+	if len(flaky.ratings(first)) != 0 || fmt.Sprint(flaky.ratings(second)) != "[1 2]" {
+		t.Errorf("Error: Expected only the events of the second company on the flaky sink: %v - %v", flaky.ratings(first), flaky.ratings(second))
+	}
+
+	*now = now.Add(BaseBackoff)
+	drain(t, d)
+	if fmt.Sprint(flaky.ratings(first)) != "[1 2 3]" {
+		t.Errorf("Error: Expected the failed event to be retried first: %v", flaky.ratings(first))
+	}
+	if len(steady.published) != 5 {
+		t.Errorf("Error: Expected no event to be published twice: %d", len(steady.published))
+	}
+}
+
+func TestDispatchGivesUp(t *testing.T) {
+	events := new(storage.EventMemoryStore)
+	reviewStore := &storage.ReviewMemoryStore{Events: events}
+	companyID := uuid.NewV4()
+	saveReviews(t, reviewStore, companyID, 3)
+
+	sink := &recorder{fail: map[string]int{companyID.String(): 2}}
+	d, now := testDispatcher(events, map[string]Sink{"sink": sink})
+	d.maxAttempts = 2
+	drain(t, d)
+	*now = now.Add(BaseBackoff)
+	drain(t, d)
+
+	// The first event failed for good, and the later events went through.
+	if fmt.Sprint(sink.ratings(companyID)) != "[2 3]" {
+		t.Errorf("Error: Expected the failed event to be skipped: %v", sink.ratings(companyID))
+	}
+	if d.retryAfter(1) != BaseBackoff || d.retryAfter(3) != 4*BaseBackoff || d.retryAfter(MaxAttempts*10) != MaxBackoff {
+		t.Errorf("Error: Unexpected backoff: %s - %s", d.retryAfter(1), d.retryAfter(3))
+	}
+}
+
+// natsConn records the messages it is asked to publish.
+type natsConn struct {
+	subjects []string
+	flushed  int
+}
+
+func (c *natsConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	return nil
+}
+
+func (c *natsConn) FlushWithContext(ctx context.Context) error {
+	c.flushed++
+	return nil
+}
+
+func TestNATSSink(t *testing.T) {
+	conn := new(natsConn)
+	sink := NewNATSSink(conn, DefaultNATSSubjectPrefix)
+	event := Event{ID: uuid.NewV4().String(), Type: storage.EventProductCreated, CompanyID: uuid.NewV4().String()}
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(conn.subjects) != 1 || conn.subjects[0] != "proddx.events."+event.CompanyID+".product.created" || conn.flushed != 1 {
+		t.Errorf("Error: Unexpected messages: %v - %d", conn.subjects, conn.flushed)
+	}
+}
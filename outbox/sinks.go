@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"api.proddx.com/logging"
+)
+
+// LogSink writes a line for each event, for development and auditing.
+type LogSink struct {
+	logger *logging.Logger
+}
+
+func NewLogSink(logger *logging.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Publish(ctx context.Context, event Event) error {
+	s.logger.Info("Event", "event_id", event.ID, "type", event.Type, "company_id", event.CompanyID, "data", string(event.Data))
+	return nil
+}
+
+// NATSConn publishes messages to a NATS server, like *nats.Conn.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+	FlushWithContext(ctx context.Context) error
+}
+
+// DefaultNATSSubjectPrefix starts the subjects of events unless another
+// prefix is configured.
+const DefaultNATSSubjectPrefix = "proddx.events"
+
+// NATSSink publishes each event as JSON to <prefix>.<company ID>.<type>, such
+// as proddx.events.<company ID>.review.created, so that subscribers can
+// filter by company and type with wildcards.
+type NATSSink struct {
+	conn   NATSConn
+	prefix string
+}
+
+func NewNATSSink(conn NATSConn, prefix string) *NATSSink {
+	return &NATSSink{conn: conn, prefix: prefix}
+}
+
+// Publish waits until the server has received the event, since NATS
+// otherwise publishes asynchronously and would lose events the connection
+// fails to send.
+func (s *NATSSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err = s.conn.Publish(s.prefix+"."+event.CompanyID+"."+event.Type, data); err != nil {
+		return err
+	}
+	return s.conn.FlushWithContext(ctx)
+}
@@ -0,0 +1,130 @@
+// Package passwords hashes and verifies user passwords and enforces the
+// password policy for new passwords.
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// Argon2id uses 64 MiB of memory like the second recommended option of RFC
+// 9106, but a single pass, which keeps logins fast on small instances.
+const (
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// passwords hashed with either algorithm, so that the algorithm or bcrypt
+// cost can be changed without invalidating stored hashes.
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+}
+
+// NewHasher returns a Hasher for algorithm. cost is only used by bcrypt and
+// defaults to bcrypt.DefaultCost when zero.
+func NewHasher(algorithm string, cost int) (*Hasher, error) {
+	switch algorithm {
+	case "", Bcrypt:
+		if cost == 0 {
+			cost = bcrypt.DefaultCost
+		}
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return &Hasher{Algorithm: Bcrypt, BcryptCost: cost}, nil
+	case Argon2id:
+		return &Hasher{Algorithm: Argon2id}, nil
+	}
+	return nil, fmt.Errorf("Unsupported password hash algorithm %s", algorithm)
+}
+
+// DefaultHasher returns a bcrypt Hasher with the default cost.
+func DefaultHasher() *Hasher {
+	return &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.DefaultCost}
+}
+
+// Hash returns the encoded hash of password.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Argon2id {
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+	return string(bytes), err
+}
+
+// Verify reports whether password matches hash.
+func (h *Hasher) Verify(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// parameters than h would use now.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if h.Algorithm == Argon2id {
+		params, _, _, err := decodeArgon2(hash)
+		return err != nil || params != argon2Params{argon2Time, argon2Memory, argon2Threads}
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.BcryptCost
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return params, nil, nil, errors.New("Invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("Unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, errors.New("Invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,89 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasher(t *testing.T) {
+	for _, algorithm := range []string{Bcrypt, Argon2id} {
+		hasher, err := NewHasher(algorithm, bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		hash, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if !hasher.Verify("correct horse", hash) {
+			t.Errorf("Error: %s: %s", algorithm, "Password was not verified")
+		}
+		if hasher.Verify("wrong horse", hash) {
+			t.Errorf("Error: %s: %s", algorithm, "Wrong password was verified")
+		}
+		if hasher.NeedsRehash(hash) {
+			t.Errorf("Error: %s: %s", algorithm, "Current hash needs rehash")
+		}
+	}
+	if _, err := NewHasher("md5", 0); err == nil {
+		t.Error("Error: Expected an error for an unsupported algorithm")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	cheap, _ := NewHasher(Bcrypt, bcrypt.MinCost)
+	costly, _ := NewHasher(Bcrypt, bcrypt.MinCost+1)
+	argon, _ := NewHasher(Argon2id, 0)
+
+	hash, err := cheap.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !costly.NeedsRehash(hash) || !argon.NeedsRehash(hash) {
+		t.Error("Error: Expected a rehash when the cost or algorithm changes")
+	}
+	if !argon.Verify("correct horse", hash) {
+		t.Error("Error: Expected bcrypt hashes to verify after switching to argon2id")
+	}
+
+	hash, err = argon.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !cheap.NeedsRehash(hash) || !cheap.Verify("correct horse", hash) {
+		t.Error("Error: Expected argon2id hashes to verify and be rehashed with bcrypt")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	list := []string{
+		"password1",
+		strings.ToLower(digest("qwertyuiop")) + ":3862",
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(list, "\n")), 0600); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	policy := NewPolicy(DefaultMinLength)
+	if err := policy.LoadBreached(path); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	cases := map[string]bool{
+		"short":            false,
+		"password1":        false,
+		"qwertyuiop":       false,
+		"correct horse":    true,
+		"ünïcödé":          false,
+		"ünïcödé pässwörd": true,
+	}
+	for password, valid := range cases {
+		if err := policy.Check(password); (err == nil) != valid {
+			t.Errorf("Error: Expected %q valid=%t: %v", password, valid, err)
+		}
+	}
+}
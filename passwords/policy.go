@@ -0,0 +1,76 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMinLength is the minimum password length recommended by NIST SP
+// 800-63B.
+const DefaultMinLength = 8
+
+// Policy decides which new passwords are acceptable.
+type Policy struct {
+	MinLength int
+	breached  map[string]bool
+}
+
+// NewPolicy returns a Policy requiring at least minLength characters.
+func NewPolicy(minLength int) *Policy {
+	return &Policy{MinLength: minLength, breached: map[string]bool{}}
+}
+
+// LoadBreached adds the passwords listed in the file at path to the
+// passwords that are rejected. The file has one entry per line, either the
+// password itself or its SHA-1 hash in hex as in the Pwned Passwords
+// downloads, optionally followed by ":count".
+func (p *Policy) LoadBreached(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash := strings.SplitN(line, ":", 2)[0]; isSHA1(hash) {
+			p.breached[strings.ToUpper(hash)] = true
+			continue
+		}
+		p.breached[digest(line)] = true
+	}
+	return scanner.Err()
+}
+
+// Check returns an error describing why password is not acceptable.
+func (p *Policy) Check(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if p.breached[digest(password)] {
+		return fmt.Errorf("Password appears in a list of breached passwords")
+	}
+	return nil
+}
+
+func digest(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,41 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api.proddx.com/requestid"
+)
+
+func TestError(t *testing.T) {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusNotFound, CodeNotFound, "Company not found")
+	})
+	handler = requestid.Middleware(handler)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/companies/1", nil)
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("Error: Unexpected response: %d - %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	expected := Problem{
+		Type:      "urn:proddx:problem:not_found",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "Company not found",
+		Instance:  "/companies/1",
+		Code:      CodeNotFound,
+		RequestID: w.Header().Get(requestid.Header),
+	}
+	if p != expected || p.RequestID == "" {
+		t.Errorf("Error: Unexpected problem: %+v", p)
+	}
+}
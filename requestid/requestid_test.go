@@ -0,0 +1,37 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	cases := map[string]bool{
+		"":                       false,
+		"abc-123":                true,
+		"has space":              false,
+		strings.Repeat("a", 129): false,
+	}
+	for sent, kept := range cases {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if sent != "" {
+			r.Header.Set(Header, sent)
+		}
+		handler.ServeHTTP(w, r)
+
+		if seen == "" || w.Header().Get(Header) != seen {
+			t.Errorf("Error: Request ID inconsistency: %s - %s", seen, w.Header().Get(Header))
+		}
+		if kept != (seen == sent) {
+			t.Errorf("Error: Expected %q to be kept=%t: %s", sent, kept, seen)
+		}
+	}
+}
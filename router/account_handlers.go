@@ -0,0 +1,202 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"api.proddx.com/mail"
+	"api.proddx.com/passwords"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+)
+
+// findAccount returns the caller and the first company they own, if any.
+func findAccount(userStorage storage.User, companyStorage storage.Company, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := userStorage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("User storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		resp := account{User: *userFromStorage(record)}
+		resp.User.Password = ""
+		memberships, err := memberStorage.List(r.Context(), "", record.ID.String())
+		if err != nil {
+			logger(r).Error("Member storage error", "error", err)
+		}
+		var owned *storage.MemberModel
+		for index, m := range memberships {
+			if m.Role == roleOwner && (owned == nil || m.CreatedAt.Before(owned.CreatedAt)) {
+				owned = &memberships[index]
+			}
+		}
+		if owned != nil {
+			if comp, err := companyStorage.Find(r.Context(), owned.CompanyID.String()); err == nil {
+				resp.Company = companyFromStorage(comp)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func changePassword(storage storage.User, hasher *passwords.Hasher, policy *passwords.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(passwordChangeRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		record, err := storage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !hasher.Verify(req.CurrentPassword, record.UserPassword) {
+			logger(r).Warn("Incorrect password")
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Incorrect password")
+			return
+		}
+		if err = policy.Check(req.NewPassword); err != nil {
+			logger(r).Warn("Password policy error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, codeWeakPassword, err.Error())
+			return
+		}
+		if record.UserPassword, err = hasher.Hash(req.NewPassword); err != nil {
+			logger(r).Error("Hashing error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+		if err = storage.Update(r.Context(), record); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func changeEmail(storage storage.User, issuer *tokens.Issuer, mailer mail.Mailer, hasher *passwords.Hasher, apiURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(emailChangeRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		record, err := storage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !hasher.Verify(req.Password, record.UserPassword) {
+			logger(r).Warn("Incorrect password")
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Incorrect password")
+			return
+		}
+		if existing, err := storage.Find(r.Context(), req.Email); err == nil && existing.ID != record.ID {
+			logger(r).Warn("Email is already in use")
+			problem.Error(w, r, http.StatusConflict, codeEmailTaken, "Email is already in use")
+			return
+		}
+
+		if record.Email != req.Email {
+			record.Email = req.Email
+			record.EmailVerified = false
+			if err = storage.Update(r.Context(), record); err != nil {
+				logger(r).Error("Storage error", "error", err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+				return
+			}
+			if err = sendVerification(issuer, mailer, apiURL, record); err != nil {
+				logger(r).Error("Mail error", "error", err)
+			}
+		}
+
+		resp := userFromStorage(record)
+		resp.Password = ""
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(*resp)
+	}
+}
+
+// deleteAccount deletes the caller's user and memberships, and the companies
+// the caller is the only member of with their data. A company with other
+// members is left to them, so the caller must transfer its ownership first
+// if they are its only owner.
+func deleteAccount(userStorage storage.User, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := tokens.UserID(r.Context())
+		if _, err := userStorage.Find(r.Context(), id); err != nil {
+			logger(r).Error("User storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		memberships, err := memberStorage.List(r.Context(), "", id)
+		if err != nil {
+			logger(r).Error("Member storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+		var companies []string
+		for _, m := range memberships {
+			if m.Role != roleOwner {
+				continue
+			}
+			records, err := memberStorage.List(r.Context(), m.CompanyID.String(), "")
+			if err != nil {
+				logger(r).Error("Member storage error", "error", err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+				return
+			}
+			owners := 0
+			for _, other := range records {
+				if other.Role == roleOwner {
+					owners++
+				}
+			}
+			switch {
+			case len(records) == 1:
+				companies = append(companies, m.CompanyID.String())
+			case owners < 2:
+				logger(r).Warn("A company must keep at least one owner", "company", m.CompanyID.String())
+				problem.Error(w, r, http.StatusConflict, codeLastOwner, "Transfer ownership of company "+m.CompanyID.String()+" before deleting the account")
+				return
+			}
+		}
+
+		err = userStorage.DeleteAccount(r.Context(), id, companies)
+		if errors.Is(err, storage.ErrMembersRemain) {
+			logger(r).Warn("A company must keep at least one owner", "error", err)
+			problem.Error(w, r, http.StatusConflict, codeLastOwner, "Transfer ownership of your companies before deleting the account")
+			return
+		}
+		if err != nil {
+			logger(r).Error("User storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
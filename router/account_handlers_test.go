@@ -0,0 +1,219 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api.proddx.com/passwords"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func saveAccount(t *testing.T, us storage.User, cs storage.Company, password string) (*storage.UserModel, *storage.CompanyModel) {
+	hash, err := passwords.DefaultHasher().Hash(password)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	um := &storage.UserModel{
+		ID:            uuid.NewV4(),
+		Email:         "user@domain.com",
+		UserPassword:  hash,
+		EmailVerified: true,
+		CreatedAt:     time.Now(),
+	}
+	if err = us.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	cm := &storage.CompanyModel{
+		ID:          uuid.NewV4(),
+		CompanyName: "Company One",
+		Email:       um.Email,
+		CreatedAt:   time.Now(),
+	}
+	if err = cs.Save(context.Background(), cm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	return um, cm
+}
+
+func TestFindAccount(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	um, cm := saveAccount(t, userStore, companyStore, "password")
+	owner := &storage.MemberModel{CompanyID: cm.ID, UserID: um.ID, Role: roleOwner, CreatedAt: time.Now()}
+	if err := memberStore.Save(context.Background(), owner); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/v1/me", nil)
+	authorize(t, r, um.ID.String())
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET /me to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	var res account
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if res.User.ID != um.ID.String() || res.User.Password != "" {
+		t.Errorf("Error: %s: %v", "User inconsistency", res.User)
+	}
+	if res.Company == nil || res.Company.ID != cm.ID.String() {
+		t.Errorf("Error: %s: %v", "Company inconsistency", res.Company)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	um, _ := saveAccount(t, userStore, companyStore, "password")
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+
+	req := passwordChangeRequest{
+		CurrentPassword: "wrong-password",
+		NewPassword:     "new-password",
+	}
+	reqJSON, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPut, "/v1/me/password", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected route PUT /me/password to reject a wrong current password: %d", w.Code)
+	}
+
+	req.CurrentPassword = "password"
+	reqJSON, _ = json.Marshal(req)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPut, "/v1/me/password", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected route PUT /me/password to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	record, err := userStore.Find(context.Background(), um.ID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !passwords.DefaultHasher().Verify(req.NewPassword, record.UserPassword) {
+		t.Error("Error: Password was not changed")
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	mailer := new(mailRecorder)
+	um, _ := saveAccount(t, userStore, companyStore, "password")
+
+	req := emailChangeRequest{
+		Email:    "new@domain.com",
+		Password: "password",
+	}
+	router := New(userStore, companyStore, productStore, reviewStore, WithMailer(mailer), WithIssuer(testIssuer), conforms(t))
+	invalidJSON, _ := json.Marshal(emailChangeRequest{Email: "not an email", Password: "password"})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPut, "/v1/me/email", bytes.NewReader(invalidJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected route PUT /me/email to reject an invalid email: %d - %s", w.Code, w.Body.String())
+	}
+
+	reqJSON, _ := json.Marshal(req)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPut, "/v1/me/email", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route PUT /me/email to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	record, err := userStore.Find(context.Background(), um.ID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.Email != req.Email || record.EmailVerified {
+		t.Errorf("Error: Expected unverified email %s: %s - %t", req.Email, record.Email, record.EmailVerified)
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To != req.Email {
+		t.Errorf("Error: Expected one verification email to %s: %v", req.Email, mailer.messages)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	companyStore := new(storage.CompanyMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	userStore := &storage.UserMemoryStore{Companies: companyStore, Members: memberStore}
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	um, cm := saveAccount(t, userStore, companyStore, "password")
+	shared := &storage.CompanyModel{ID: uuid.NewV4(), CompanyName: "Company Two", CreatedAt: time.Now()}
+	if err := companyStore.Save(context.Background(), shared); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	for _, companyID := range []uuid.UUID{cm.ID, shared.ID} {
+		owner := &storage.MemberModel{CompanyID: companyID, UserID: um.ID, Role: roleOwner, CreatedAt: time.Now()}
+		if err := memberStore.Save(context.Background(), owner); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	saveMember(t, memberStore, shared.ID, roleAdmin)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodDelete, "/v1/me", nil)
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected route DELETE /me to refuse leaving members without an owner: %d - %s", w.Code, w.Body.String())
+	}
+	if _, err := companyStore.Find(context.Background(), cm.ID.String()); err != nil {
+		t.Error("Error: Company was deleted although the account was not")
+	}
+
+	saveMember(t, memberStore, shared.ID, roleOwner)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodDelete, "/v1/me", nil)
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected route DELETE /me to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	if _, err := userStore.Find(context.Background(), um.ID.String()); err == nil {
+		t.Error("Error: User was not deleted")
+	}
+	if records, _ := memberStore.List(context.Background(), "", um.ID.String()); len(records) != 0 {
+		t.Errorf("Error: Memberships were not deleted: %v", records)
+	}
+	if _, err := companyStore.Find(context.Background(), cm.ID.String()); err == nil {
+		t.Error("Error: Company was not deleted with the account")
+	}
+	if _, err := companyStore.Find(context.Background(), shared.ID.String()); err != nil {
+		t.Error("Error: Company with other members was deleted with the account")
+	}
+}
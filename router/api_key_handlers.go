@@ -0,0 +1,221 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	scopeReadOnly  = "read-only"
+	scopeReadWrite = "read-write"
+)
+
+// scopeRoles maps API key scopes to the member role they are treated as.
+var scopeRoles = map[string]string{
+	scopeReadOnly:  roleViewer,
+	scopeReadWrite: roleAdmin,
+}
+
+const (
+	apiKeyHeader    = "X-API-Key"
+	apiKeyPrefix    = "pdx_"
+	apiKeyPrefixLen = 12
+)
+
+type contextKey string
+
+const apiKeyContextKey contextKey = "api_key"
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestAPIKey returns the API key the request was authenticated with, or
+// nil for requests authenticated with a user token.
+func requestAPIKey(ctx context.Context) *storage.APIKeyModel {
+	key, _ := ctx.Value(apiKeyContextKey).(*storage.APIKeyModel)
+	return key
+}
+
+// authenticate accepts either an API key in the X-API-Key header or a user
+// token validated by the issuer.
+func authenticate(keyStorage storage.APIKey, issuer *tokens.Issuer, next http.Handler) http.Handler {
+	validated := issuer.Validation(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			validated.ServeHTTP(w, r)
+			return
+		}
+		record, err := keyStorage.FindByHash(r.Context(), hashAPIKey(key))
+		if err != nil {
+			logger(r).Warn("API key error", "error", err)
+			problem.Error(w, r, http.StatusUnauthorized, codeInvalidAPIKey, "Invalid API key")
+			return
+		}
+		if err = keyStorage.Touch(r.Context(), record.ID.String(), time.Now()); err != nil {
+			logger(r).Error("API key storage error", "error", err)
+		}
+		logging.AddFields(r.Context(), "api_key_id", record.ID.String())
+		ctx := context.WithValue(r.Context(), apiKeyContextKey, record)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authorized reports whether the caller may act on the company with at least
+// the given role, either as a member or through an API key of that company.
+func authorized(memberStorage storage.Member, r *http.Request, companyID, role string) bool {
+	if key := requestAPIKey(r.Context()); key != nil {
+		return key.CompanyID.String() == companyID && roleRanks[scopeRoles[key.Scope]] >= roleRanks[role]
+	}
+	return hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), companyID, role)
+}
+
+// callerCompanies returns the IDs of the companies the caller can read.
+func callerCompanies(memberStorage storage.Member, r *http.Request) (map[string]bool, error) {
+	if key := requestAPIKey(r.Context()); key != nil {
+		return map[string]bool{key.CompanyID.String(): true}, nil
+	}
+	return memberCompanies(r.Context(), memberStorage, tokens.UserID(r.Context()))
+}
+
+func listAPIKeys(keyStorage storage.APIKey, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if !hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), id, roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+
+		records, err := keyStorage.List(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		resp := []apiKey{}
+		for _, record := range records {
+			resp = append(resp, *apiKeyFromStorage(&record))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func createAPIKey(keyStorage storage.APIKey, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(apiKeyRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		if !hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), id, roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+
+		secret, err := generateAPIKey()
+		if err != nil {
+			logger(r).Warn("API key error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		model := &storage.APIKeyModel{
+			ID:        uuid.NewV4(),
+			CompanyID: uuid.FromStringOrNil(id),
+			KeyName:   req.Name,
+			KeyPrefix: secret[:apiKeyPrefixLen],
+			KeyHash:   hashAPIKey(secret),
+			Scope:     req.Scope,
+			CreatedAt: time.Now(),
+		}
+		if err = keyStorage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		resp := apiKeyFromStorage(model)
+		resp.Key = secret
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(*resp)
+	}
+}
+
+func revokeAPIKey(keyStorage storage.APIKey, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		keyID := params.ByName("key_id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if _, err := uuid.FromString(keyID); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if !hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), id, roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+
+		if err := keyStorage.Delete(r.Context(), id, keyID); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
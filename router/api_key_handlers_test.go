@@ -0,0 +1,190 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func saveAPIKey(t *testing.T, ks storage.APIKey, companyID uuid.UUID, scope string) string {
+	secret, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	km := &storage.APIKeyModel{
+		ID:        uuid.NewV4(),
+		CompanyID: companyID,
+		KeyName:   "Backend",
+		KeyPrefix: secret[:apiKeyPrefixLen],
+		KeyHash:   hashAPIKey(secret),
+		Scope:     scope,
+		CreatedAt: time.Now(),
+	}
+	if err = ks.Save(context.Background(), km); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	return secret
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	apiKeyStore := new(storage.APIKeyMemoryStore)
+
+	companyID := uuid.NewV4()
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	analystID := saveMember(t, memberStore, companyID, roleAnalyst)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithAPIKeyStore(apiKeyStore))
+
+	route := fmt.Sprintf("/v1/companies/%s/api-keys", companyID)
+	reqJSON, _ := json.Marshal(apiKeyRequest{Name: "Backend", Scope: scopeReadWrite})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, analystID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected route POST %s to be forbidden for analysts: %d", route, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	var created apiKey
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix) || created.Scope != scopeReadWrite {
+		t.Errorf("Error: Unexpected API key: %v", created)
+	}
+	records, _ := apiKeyStore.List(context.Background(), companyID.String())
+	if len(records) != 1 || records[0].KeyHash == created.Key || records[0].KeyHash != hashAPIKey(created.Key) {
+		t.Errorf("Error: Expected the API key to be stored hashed: %v", records)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), created.Key) {
+		t.Errorf("Error: API key listed in plain text: %s", w.Body.String())
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	apiKeyStore := new(storage.APIKeyMemoryStore)
+
+	companyID := uuid.NewV4()
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	secret := saveAPIKey(t, apiKeyStore, companyID, scopeReadOnly)
+	record, _ := apiKeyStore.FindByHash(context.Background(), hashAPIKey(secret))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithAPIKeyStore(apiKeyStore))
+
+	route := fmt.Sprintf("/v1/companies/%s/api-keys/%s", companyID, record.ID)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodDelete, route, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected route DELETE %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/v1/reviews", nil)
+	r.Header.Set(apiKeyHeader, secret)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked API key to be rejected: %d", w.Code)
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	apiKeyStore := new(storage.APIKeyMemoryStore)
+
+	companyID := uuid.NewV4()
+	rm := &storage.ReviewModel{
+		ID:        uuid.NewV4(),
+		CompanyID: companyID,
+		ProductID: uuid.NewV4(),
+		Comment:   "Lorem ipsum dolor sit amet",
+		Rating:    3,
+		CreatedAt: time.Now(),
+	}
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	readOnly := saveAPIKey(t, apiKeyStore, companyID, scopeReadOnly)
+	readWrite := saveAPIKey(t, apiKeyStore, companyID, scopeReadWrite)
+	otherCompany := saveAPIKey(t, apiKeyStore, uuid.NewV4(), scopeReadWrite)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithAPIKeyStore(apiKeyStore))
+
+	productJSON, _ := json.Marshal(productRequest{CompanyID: companyID.String(), Name: "Product One"})
+	attempts := []struct {
+		key    string
+		method string
+		route  string
+		body   []byte
+		status int
+	}{
+		{readOnly, http.MethodGet, "/v1/reviews", nil, http.StatusOK},
+		{otherCompany, http.MethodGet, "/v1/reviews?company_id=" + companyID.String(), nil, http.StatusForbidden},
+		{"pdx_invalid", http.MethodGet, "/v1/reviews", nil, http.StatusUnauthorized},
+		{readOnly, http.MethodPost, "/v1/products", productJSON, http.StatusForbidden},
+		{readWrite, http.MethodPost, "/v1/products", productJSON, http.StatusCreated},
+		{readWrite, http.MethodDelete, "/v1/companies/" + companyID.String(), nil, http.StatusForbidden},
+		{readWrite, http.MethodGet, fmt.Sprintf("/v1/companies/%s/api-keys", companyID), nil, http.StatusUnauthorized},
+	}
+	for _, attempt := range attempts {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(attempt.method, attempt.route, bytes.NewReader(attempt.body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(apiKeyHeader, attempt.key)
+		router.ServeHTTP(w, r)
+
+		if w.Code != attempt.status {
+			t.Errorf("Expected route %s %s to return %d: %d - %s", attempt.method, attempt.route, attempt.status, w.Code, w.Body.String())
+		}
+	}
+
+	record, err := apiKeyStore.FindByHash(context.Background(), hashAPIKey(readOnly))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.LastUsedAt == nil {
+		t.Errorf("Error: %s", "Last use of the API key was not recorded")
+	}
+}
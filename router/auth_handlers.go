@@ -2,77 +2,92 @@ package router
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
+	"api.proddx.com/mail"
+	"api.proddx.com/passwords"
+	"api.proddx.com/problem"
 	"api.proddx.com/storage"
 	"api.proddx.com/tokens"
 	uuid "github.com/satori/go.uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
-
-func checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
-
-func login(storage storage.User) http.HandlerFunc {
+func login(storage storage.User, issuer *tokens.Issuer, hasher *passwords.Hasher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := new(loginRequest)
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
-		if &req.Email == nil || &req.Password == nil {
-			fmt.Println("Error:", "email and password are required")
-			http.Error(w, "email and password are required", http.StatusBadRequest)
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
 			return
 		}
-		record, err := storage.Find(req.Email)
+		record, err := storage.Find(r.Context(), req.Email)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Incorrect email or password")
 			return
 		}
-		if !checkPasswordHash(req.Password, record.UserPassword) {
-			fmt.Println("Error:", "Incorrect password")
-			http.Error(w, "Incorrect password", http.StatusUnauthorized)
+		if !hasher.Verify(req.Password, record.UserPassword) {
+			logger(r).Warn("Incorrect password")
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Incorrect email or password")
 			return
 		}
-		token, err := tokens.New(record.ID.String())
+		if hasher.NeedsRehash(record.UserPassword) {
+			hash, err := hasher.Hash(req.Password)
+			if err == nil {
+				record.UserPassword = hash
+				err = storage.Update(r.Context(), record)
+			}
+			if err != nil {
+				logger(r).Error("Rehash error", "error", err)
+			}
+		}
+		if record.TOTPEnabled {
+			challenge, err := issuer.NewChallenge(record.ID.String())
+			if err != nil {
+				logger(r).Error("Token error", "error", err)
+				problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]string{"challenge_token": challenge})
+			return
+		}
+		token, err := issuer.New(record.ID.String())
 		if err != nil {
-			fmt.Println("Token error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
 			return
 		}
 		res := map[string]string{"token": token}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusCreated)
-		w.Header().Add("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(res)
 	}
 }
 
-func register(userStorage storage.User, companyStorage storage.Company) http.HandlerFunc {
+func register(userStorage storage.User, companyStorage storage.Company, memberStorage storage.Member, issuer *tokens.Issuer, mailer mail.Mailer, hasher *passwords.Hasher, policy *passwords.Policy, apiURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req registrationRequest
 		var err error
-		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-			fmt.Println("Marshalling error", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err = decodeJSON(r, &req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
 
-		if &req.Email == nil || &req.Name == nil || &req.Password == nil {
-			errString := "name, email and password are required"
-			fmt.Println("Error:", errString)
-			http.Error(w, errString, http.StatusBadRequest)
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		if err = policy.Check(req.Password); err != nil {
+			logger(r).Warn("Password policy error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, codeWeakPassword, err.Error())
 			return
 		}
 
@@ -81,33 +96,49 @@ func register(userStorage storage.User, companyStorage storage.Company) http.Han
 			Email:     req.Email,
 			CreatedAt: time.Now(),
 		}
-		if u.Password, err = hashPassword(req.Password); err != nil {
-			fmt.Println("Hashing error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if u.Password, err = hasher.Hash(req.Password); err != nil {
+			logger(r).Error("Hashing error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 		userModel := userToStorage(&u)
-		if err = userStorage.Save(userModel); err != nil {
-			fmt.Println("User storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err = userStorage.Save(r.Context(), userModel); err != nil {
+			logger(r).Error("User storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 		comp := company{
 			ID:        uuid.NewV4().String(),
-			UserID:    u.ID,
 			Name:      req.Name,
 			Email:     req.Email,
 			Logo:      "",
 			CreatedAt: time.Now(),
 		}
 		compModel := companyToStorage(&comp)
-		if err = companyStorage.Save(compModel); err != nil {
-			fmt.Println("Company storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err = companyStorage.Save(r.Context(), compModel); err != nil {
+			logger(r).Error("Company storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+		if err = saveOwner(r.Context(), memberStorage, comp.ID, u.ID); err != nil {
+			logger(r).Error("Member storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
+		if err = sendVerification(issuer, mailer, apiURL, userModel); err != nil {
+			logger(r).Error("Mail error", "error", err)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusCreated)
-		w.Header().Add("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(comp)
 	}
 }
+
+func listKeys(issuer *tokens.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(issuer.JWKS())
+	}
+}
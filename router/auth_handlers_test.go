@@ -2,20 +2,40 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"api.proddx.com/passwords"
 	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
 	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var testIssuer = func() *tokens.Issuer {
+	issuer, err := tokens.NewEphemeralIssuer()
+	if err != nil {
+		panic(err)
+	}
+	return issuer
+}()
+
+func authorize(t *testing.T, r *http.Request, userID string) {
+	token, err := testIssuer.New(userID)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+}
+
 func TestLogin(t *testing.T) {
 	email := "user@example.com"
 	password := "password"
-	hash, err := hashPassword(password)
+	hash, err := passwords.DefaultHasher().Hash(password)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -29,7 +49,7 @@ func TestLogin(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
-	if err = userStore.Save(&model); err != nil {
+	if err = userStore.Save(context.Background(), &model); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
@@ -43,8 +63,9 @@ func TestLogin(t *testing.T) {
 	}
 
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(reqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodPost, "/v1/login", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusCreated {
@@ -76,8 +97,9 @@ func TestRegister(t *testing.T) {
 	reviewStore := new(storage.ReviewMemoryStore)
 
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodPost, "/register", bytes.NewReader(reqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodPost, "/v1/register", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusCreated {
@@ -91,3 +113,94 @@ func TestRegister(t *testing.T) {
 		t.Error("Error:", "Record inconsistency")
 	}
 }
+
+func TestRegisterPasswordPolicy(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithPasswordPolicy(passwords.NewPolicy(12)))
+
+	for _, password := range []string{"", "password"} {
+		reqJSON, _ := json.Marshal(registrationRequest{Name: "Company One", Email: "company@domain.com", Password: password})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/register", bytes.NewReader(reqJSON))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected route POST /register to reject password %q: %d", password, w.Code)
+		}
+	}
+	if _, err := userStore.Find(context.Background(), "company@domain.com"); err == nil {
+		t.Error("Error: Account created with a password rejected by the policy")
+	}
+}
+
+func TestLoginRehash(t *testing.T) {
+	cheap, err := passwords.NewHasher(passwords.Bcrypt, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	hash, err := cheap.Hash("password")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	model := storage.UserModel{
+		ID:           uuid.NewV4(),
+		Email:        "user@example.com",
+		UserPassword: hash,
+		CreatedAt:    time.Now(),
+	}
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	if err = userStore.Save(context.Background(), &model); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	argon, _ := passwords.NewHasher(passwords.Argon2id, 0)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithPasswordHasher(argon))
+
+	for i := 0; i < 2; i++ {
+		reqJSON, _ := json.Marshal(loginRequest{Email: model.Email, Password: "password"})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/login", bytes.NewReader(reqJSON))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected route POST /login to be valid: %d - %s", w.Code, w.Body.String())
+		}
+	}
+	record, err := userStore.Find(context.Background(), model.ID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.UserPassword == hash || argon.NeedsRehash(record.UserPassword) {
+		t.Errorf("Error: Expected the password to be rehashed with argon2id: %s", record.UserPassword)
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET /.well-known/jwks.json to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	var res tokens.JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(res.Keys) != 1 || res.Keys[0].KeyID != "ephemeral" {
+		t.Errorf("Error: Unexpected key set: %v", res.Keys)
+	}
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"api.proddx.com/problem"
+)
+
+// Limits of request bodies. Anonymous review submissions get less room than
+// the authenticated routes.
+const (
+	defaultBodyLimit = 64 << 10
+	reviewBodyLimit  = 16 << 10
+)
+
+type strictJSONContextKey struct{}
+
+// jsonBodies returns middleware for routes that read a JSON body of at most
+// limit bytes. Larger bodies are refused with 413 Payload Too Large, and
+// bodies of another content type or none with 415 Unsupported Media Type;
+// only empty bodies may leave out the Content-Type. With strict, decodeJSON
+// rejects fields the handler does not know.
+func jsonBodies(strict bool) func(limit int64, next http.Handler) http.Handler {
+	return func(limit int64, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				tooLarge(w, r, limit)
+				return
+			}
+			contentType := r.Header.Get("Content-Type")
+			if contentType != "" && !isJSON(contentType) {
+				unsupportedMedia(w, r, contentType)
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+			if err != nil {
+				logger(r).Warn("Body read error", "error", err)
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeBadRequest, "Request body could not be read")
+				return
+			}
+			if int64(len(body)) > limit {
+				tooLarge(w, r, limit)
+				return
+			}
+			if len(body) > 0 && contentType == "" {
+				unsupportedMedia(w, r, contentType)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if strict {
+				r = r.WithContext(context.WithValue(r.Context(), strictJSONContextKey{}, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func tooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	logger(r).Warn("Request body too large", "limit", limit)
+	problem.Error(w, r, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", limit))
+}
+
+func unsupportedMedia(w http.ResponseWriter, r *http.Request, contentType string) {
+	logger(r).Warn("Unsupported content type", "content_type", contentType)
+	problem.Error(w, r, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia, "Request body must be application/json")
+}
+
+// isJSON reports whether contentType is application/json or a JSON based
+// type such as application/merge-patch+json.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// decodeJSON decodes the body of r into v, rejecting unknown fields when
+// the route is strict.
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	if strict, _ := r.Context().Value(strictJSONContextKey{}).(bool); strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestRequestBodies(t *testing.T) {
+	companyID := uuid.NewV4()
+	productStore := new(storage.ProductMemoryStore)
+	productID := saveProduct(t, productStore, companyID)
+	review := `{"company_id": "` + companyID.String() + `", "product_id": "` + productID + `", "comment": "Great", "rating": 5`
+	for _, tc := range []struct {
+		name        string
+		body        string
+		contentType string
+		chunked     bool
+		strict      bool
+		status      int
+		code        string
+	}{
+		{"json", review + "}", "application/json; charset=UTF-8", false, false, http.StatusCreated, ""},
+		{"no content type", review + "}", "", false, false, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia},
+		{"unknown field", review + `, "extra": true}`, "application/json", false, false, http.StatusCreated, ""},
+		{"strict unknown field", review + `, "extra": true}`, "application/json", false, true, http.StatusBadRequest, problem.CodeInvalidJSON},
+		{"form", "comment=Great", "application/x-www-form-urlencoded", false, false, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia},
+		{"too large", review + `, "padding": "` + strings.Repeat("a", reviewBodyLimit) + `"}`, "application/json", false, false, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge},
+		{"chunked too large", review + `, "padding": "` + strings.Repeat("a", reviewBodyLimit) + `"}`, "application/json", true, false, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge},
+	} {
+		opts := []Option{WithIssuer(testIssuer), conforms(t)}
+		if tc.strict {
+			opts = append(opts, WithStrictJSON())
+		}
+		router := New(new(storage.UserMemoryStore), new(storage.CompanyMemoryStore), productStore, new(storage.ReviewMemoryStore), opts...)
+
+		var body io.Reader = strings.NewReader(tc.body)
+		if tc.chunked {
+			// Hide the length so that the body is sent chunked.
+			body = io.MultiReader(body)
+		}
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", body)
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		router.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("Error: Expected %s to return %d, got %d: %s", tc.name, tc.status, w.Code, w.Body.String())
+			continue
+		}
+		if tc.code == "" {
+			continue
+		}
+		var res problem.Problem
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if res.Code != tc.code {
+			t.Errorf("Error: Expected %s to fail with %s, got %s", tc.name, tc.code, res.Code)
+		}
+	}
+}
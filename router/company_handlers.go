@@ -2,27 +2,27 @@ package router
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
+	"api.proddx.com/problem"
 	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
 	"github.com/julienschmidt/httprouter"
 	uuid "github.com/satori/go.uuid"
 )
 
-func insertCompany(storage storage.Company) http.HandlerFunc {
+func insertCompany(storage storage.Company, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		reqBody := new(companyRequest)
-		if err := json.NewDecoder(r.Body).Decode(reqBody); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, reqBody); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
 
-		if !(reqBody.UserID != "" && reqBody.Name != "" && reqBody.Email != "") {
-			fmt.Println("Error: user_id, name and email are required")
-			http.Error(w, "user_id, name and email are required", http.StatusBadRequest)
+		if errs := reqBody.validate(true); len(errs) > 0 {
+			invalidRequest(w, r, errs)
 			return
 		}
 
@@ -30,9 +30,14 @@ func insertCompany(storage storage.Company) http.HandlerFunc {
 		comp.ID = uuid.NewV4().String()
 		comp.CreatedAt = time.Now()
 		model := companyToStorage(comp)
-		if err := storage.Save(model); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err := storage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+		if err := saveOwner(r.Context(), memberStorage, comp.ID, tokens.UserID(r.Context())); err != nil {
+			logger(r).Error("Member storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 
@@ -42,22 +47,30 @@ func insertCompany(storage storage.Company) http.HandlerFunc {
 	}
 }
 
-func listCompanies(storage storage.Company) http.HandlerFunc {
+func listCompanies(storage storage.Company, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		records, err := storage.List()
+		companyIDs, err := callerCompanies(memberStorage, r)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			logger(r).Error("Member storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
-		if len(records) == 0 {
-			fmt.Println("Error:", "No companies found")
-			http.Error(w, "No companies found", http.StatusNotFound)
+		records, err := storage.List(r.Context())
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 		var resp []company
 		for _, record := range records {
-			resp = append(resp, *companyFromStorage(&record))
+			if companyIDs[record.ID.String()] {
+				resp = append(resp, *companyFromStorage(&record))
+			}
+		}
+		if len(resp) == 0 {
+			logger(r).Warn("No companies found")
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "No companies found")
+			return
 		}
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
@@ -65,20 +78,25 @@ func listCompanies(storage storage.Company) http.HandlerFunc {
 	}
 }
 
-func findCompany(storage storage.Company) http.HandlerFunc {
+func findCompany(storage storage.Company, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if !authorized(memberStorage, r, id, roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
-		record, err := storage.Find(id)
+		record, err := storage.Find(r.Context(), id)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 		resp := companyFromStorage(record)
@@ -89,28 +107,37 @@ func findCompany(storage storage.Company) http.HandlerFunc {
 	}
 }
 
-func updateCompany(storage storage.Company) http.HandlerFunc {
+func updateCompany(storage storage.Company, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := new(companyRequest)
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(false); len(errs) > 0 {
+			invalidRequest(w, r, errs)
 			return
 		}
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if !authorized(memberStorage, r, id, roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
 		comp := companyFromTransport(req)
 		comp.ID = id
 		model := companyToStorage(comp)
-		if err := storage.Save(model); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err := storage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 
@@ -121,19 +148,24 @@ func updateCompany(storage storage.Company) http.HandlerFunc {
 	}
 }
 
-func deleteCompany(storage storage.Company) http.HandlerFunc {
+func deleteCompany(storage storage.Company, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if !authorized(memberStorage, r, id, roleOwner) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
-		if err := storage.Delete(id); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := storage.Delete(r.Context(), id); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 
@@ -2,6 +2,7 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,10 +16,9 @@ import (
 
 func TestInsertCompany(t *testing.T) {
 	compReq := companyRequest{
-		UserID: uuid.NewV4().String(),
-		Name:   "Company One",
-		Email:  "company@domain.com",
-		Logo:   "https://proddx.com/company-one/logo.png",
+		Name:  "Company One",
+		Email: "company@domain.com",
+		Logo:  "https://proddx.com/company-one/logo.png",
 	}
 	compReqJSON, err := json.Marshal(compReq)
 	if err != nil {
@@ -31,8 +31,10 @@ func TestInsertCompany(t *testing.T) {
 	reviewStore := new(storage.ReviewMemoryStore)
 
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodPost, "/companies", bytes.NewBuffer(compReqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodPost, "/v1/companies", bytes.NewBuffer(compReqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, uuid.NewV4().String())
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusCreated {
@@ -42,7 +44,7 @@ func TestInsertCompany(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &compRes); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := companyStore.Find(compRes.ID)
+	record, err := companyStore.Find(context.Background(), compRes.ID)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -56,23 +58,24 @@ func TestListCompanies(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	cm := &storage.CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
-	}
-	if err := companyStore.Save(cm); err != nil {
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
+	}
+	if err := companyStore.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodGet, "/companies", nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodGet, "/v1/companies", nil)
+	authorize(t, r, saveMember(t, memberStore, cm.ID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -82,7 +85,7 @@ func TestListCompanies(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &compRes); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	records, err := companyStore.List()
+	records, err := companyStore.List(context.Background())
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -96,24 +99,25 @@ func TestFindCompany(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	cm := &storage.CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
-	}
-	if err := companyStore.Save(cm); err != nil {
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
+	}
+	if err := companyStore.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/companies/%s", id)
+	route := fmt.Sprintf("/v1/companies/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodGet, route, nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	authorize(t, r, saveMember(t, memberStore, cm.ID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -123,7 +127,7 @@ func TestFindCompany(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &compRes); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := companyStore.Find(id)
+	record, err := companyStore.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -137,17 +141,17 @@ func TestUpdateCompany(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	cm := &storage.CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
-	}
-	if err := companyStore.Save(cm); err != nil {
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
+	}
+	if err := companyStore.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
@@ -159,10 +163,12 @@ func TestUpdateCompany(t *testing.T) {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/companies/%s", id)
+	route := fmt.Sprintf("/v1/companies/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodPut, route, bytes.NewBuffer(compReqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, saveMember(t, memberStore, cm.ID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -172,7 +178,7 @@ func TestUpdateCompany(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &compRes); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := companyStore.Find(id)
+	record, err := companyStore.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -186,30 +192,31 @@ func TestDeleteCompany(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	cm := &storage.CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
-	}
-	if err := companyStore.Save(cm); err != nil {
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
+	}
+	if err := companyStore.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/companies/%s", id)
+	route := fmt.Sprintf("/v1/companies/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodDelete, route, nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	authorize(t, r, saveMember(t, memberStore, cm.ID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusNoContent {
 		t.Fatal(fmt.Sprintf("Expected route DELETE %s to be valid", route))
 	}
-	if _, err := companyStore.Find(id); err == nil {
+	if _, err := companyStore.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", "Record failed to delete")
 	}
 }
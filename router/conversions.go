@@ -7,19 +7,21 @@ import (
 
 func userToStorage(u *user) *storage.UserModel {
 	return &storage.UserModel{
-		ID:           uuid.FromStringOrNil(u.ID),
-		Email:        u.Email,
-		UserPassword: u.Password,
-		CreatedAt:    u.CreatedAt,
+		ID:            uuid.FromStringOrNil(u.ID),
+		Email:         u.Email,
+		UserPassword:  u.Password,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     u.CreatedAt,
 	}
 }
 
 func userFromStorage(model *storage.UserModel) *user {
 	return &user{
-		ID:        model.ID.String(),
-		Email:     model.Email,
-		Password:  model.UserPassword,
-		CreatedAt: model.CreatedAt,
+		ID:            model.ID.String(),
+		Email:         model.Email,
+		Password:      model.UserPassword,
+		EmailVerified: model.EmailVerified,
+		CreatedAt:     model.CreatedAt,
 	}
 }
 
@@ -32,19 +34,17 @@ func userFromTransport(req *registrationRequest) *user {
 
 func companyToStorage(c *company) *storage.CompanyModel {
 	return &storage.CompanyModel{
-		ID:            uuid.FromStringOrNil(c.ID),
-		CompanyUserID: c.UserID,
-		CompanyName:   c.Name,
-		Email:         c.Email,
-		Logo:          c.Logo,
-		CreatedAt:     c.CreatedAt,
+		ID:          uuid.FromStringOrNil(c.ID),
+		CompanyName: c.Name,
+		Email:       c.Email,
+		Logo:        c.Logo,
+		CreatedAt:   c.CreatedAt,
 	}
 }
 
 func companyFromStorage(model *storage.CompanyModel) *company {
 	return &company{
 		ID:        model.ID.String(),
-		UserID:    model.CompanyUserID,
 		Name:      model.CompanyName,
 		Email:     model.Email,
 		Logo:      model.Logo,
@@ -54,10 +54,91 @@ func companyFromStorage(model *storage.CompanyModel) *company {
 
 func companyFromTransport(req *companyRequest) *company {
 	return &company{
-		UserID: req.UserID,
-		Name:   req.Name,
-		Email:  req.Email,
-		Logo:   req.Logo,
+		Name:  req.Name,
+		Email: req.Email,
+		Logo:  req.Logo,
+	}
+}
+
+func memberToStorage(m *member) *storage.MemberModel {
+	return &storage.MemberModel{
+		CompanyID: uuid.FromStringOrNil(m.CompanyID),
+		UserID:    uuid.FromStringOrNil(m.UserID),
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func memberFromStorage(model *storage.MemberModel) *member {
+	return &member{
+		CompanyID: model.CompanyID.String(),
+		UserID:    model.UserID.String(),
+		Role:      model.Role,
+		CreatedAt: model.CreatedAt,
+	}
+}
+
+func apiKeyFromStorage(model *storage.APIKeyModel) *apiKey {
+	return &apiKey{
+		ID:         model.ID.String(),
+		CompanyID:  model.CompanyID.String(),
+		Name:       model.KeyName,
+		Prefix:     model.KeyPrefix,
+		Scope:      model.Scope,
+		CreatedAt:  model.CreatedAt,
+		LastUsedAt: model.LastUsedAt,
+	}
+}
+
+func webhookFromStorage(model *storage.WebhookModel) *webhook {
+	return &webhook{
+		ID:        model.ID.String(),
+		CompanyID: model.CompanyID.String(),
+		URL:       model.URL,
+		Events:    model.Events,
+		CreatedAt: model.CreatedAt,
+	}
+}
+
+func webhookDeliveryFromStorage(model *storage.WebhookDeliveryModel) *webhookDelivery {
+	delivery := &webhookDelivery{
+		ID:             model.ID.String(),
+		WebhookID:      model.WebhookID.String(),
+		Event:          model.Event,
+		Status:         model.Status,
+		Attempts:       model.Attempts,
+		ResponseStatus: model.ResponseStatus,
+		LastError:      model.LastError,
+		LastAttemptAt:  model.LastAttemptAt,
+		CreatedAt:      model.CreatedAt,
+	}
+	if model.Status == storage.DeliveryPending {
+		delivery.NextAttemptAt = &model.NextAttemptAt
+	}
+	return delivery
+}
+
+// notificationPreferencesFromStorage leaves out when defaults were updated,
+// since they never were.
+func notificationPreferencesFromStorage(model *storage.NotificationPreferenceModel) *notificationPreferences {
+	prefs := &notificationPreferences{
+		Mode:      model.Mode,
+		Threshold: model.Threshold,
+		Frequency: model.Frequency,
+	}
+	if !model.UpdatedAt.IsZero() {
+		prefs.UpdatedAt = &model.UpdatedAt
+	}
+	return prefs
+}
+
+func notificationChannelFromStorage(model *storage.NotificationChannelModel) *notificationChannel {
+	return &notificationChannel{
+		ID:        model.ID.String(),
+		CompanyID: model.CompanyID.String(),
+		URL:       model.URL,
+		Format:    model.Format,
+		CreatedAt: model.CreatedAt,
 	}
 }
 
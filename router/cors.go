@@ -1,16 +1,191 @@
 package router
 
-import "net/http"
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
-func cors(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Access-Control-Allow-Origin", r.Header.Get("Origin"))
-	w.Header().Add("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
-	w.Header().Add("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+	"api.proddx.com/config"
+	"api.proddx.com/requestid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// corsExposedHeaders are the response headers that scripts on allowed
+// origins can read.
+var corsExposedHeaders = []string{requestid.Header, "Location", "Deprecation", "Sunset", "Link"}
+
+// publicCORS lets any site read public documents and submit reviews, as
+// the review widget is embedded on customers' sites. Credentials are never
+// sent along.
+var publicCORS = newCORSPolicy(config.CORS{
+	AllowedOrigins: []string{"*"},
+	AllowedMethods: []string{http.MethodGet, http.MethodPost},
+	AllowedHeaders: []string{"Content-Type", requestid.Header, "traceparent"},
+})
+
+// corsPolicy decides which cross-origin requests browsers may make.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []string
+	methods     map[string]bool
+	headers     map[string]bool
+	credentials bool
+
+	allowMethods string
+	allowHeaders string
+	maxAge       string
+}
+
+// newCORSPolicy builds the policy c, with the default methods, headers and
+// max-age where c has none.
+func newCORSPolicy(c config.CORS) *corsPolicy {
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = config.DefaultCORS.AllowedMethods
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = config.DefaultCORS.AllowedHeaders
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = config.DefaultCORS.MaxAge
+	}
+	p := &corsPolicy{
+		origins:      map[string]bool{},
+		methods:      map[string]bool{},
+		headers:      map[string]bool{},
+		credentials:  c.AllowCredentials,
+		allowMethods: strings.Join(c.AllowedMethods, ", "),
+		allowHeaders: strings.Join(c.AllowedHeaders, ", "),
+		maxAge:       strconv.Itoa(int(c.MaxAge.Seconds())),
+	}
+	for _, origin := range c.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			// https://*.proddx.com matches https://app.proddx.com but not
+			// https://proddx.com.
+			p.wildcards = append(p.wildcards, strings.Replace(origin, "://*.", "://", 1))
+		default:
+			p.origins[origin] = true
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range c.AllowedHeaders {
+		p.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	return p
+}
+
+// allowsOrigin reports whether scripts on origin may call the API.
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, wildcard := range p.wildcards {
+		scheme, domain := splitOrigin(wildcard)
+		if u.Scheme == scheme && strings.HasSuffix(u.Host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitOrigin(origin string) (scheme, host string) {
+	if i := strings.Index(origin, "://"); i >= 0 {
+		return origin[:i], origin[i+3:]
+	}
+	return "", origin
 }
 
-func corsHandler(next http.Handler) http.Handler {
+// allowOrigin sets the headers that let the origin of r read the response.
+func (p *corsPolicy) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if !p.allowsOrigin(origin) {
+		return false
+	}
+	if p.anyOrigin && !p.credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// handler adds the CORS headers to the responses of next.
+func (p *corsPolicy) handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cors(w, r)
+		if p.allowOrigin(w, r) {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// preflight answers a preflight request for a route with this policy. The
+// CORS headers are left out when the request is not allowed, which makes
+// the browser refuse to send it.
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	if !p.methods[r.Header.Get("Access-Control-Request-Method")] || !p.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+		w.Header().Add("Vary", "Origin")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if p.allowOrigin(w, r) {
+		w.Header().Set("Access-Control-Allow-Methods", p.allowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", p.allowHeaders)
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// preflightHandler answers OPTIONS requests for every path of router. The
+// policy of a preflight request is that of the route it asks to call, found
+// in preflights, which mirrors the routes with a CORS policy.
+func preflightHandler(preflights *httprouter.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.Header.Get("Access-Control-Request-Method")
+		if r.Header.Get("Origin") == "" || method == "" {
+			// Not a preflight request: httprouter has set the Allow header.
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if handle, params, _ := preflights.Lookup(method, r.URL.Path); handle != nil {
+			handle(w, r, params)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api.proddx.com/config"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestCORS(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	policy := config.CORS{
+		AllowedOrigins:   []string{"https://app.proddx.com", "https://*.example.com"},
+		AllowCredentials: true,
+	}
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithCORS(policy))
+
+	productPath := "/v1/products/" + uuid.NewV4().String()
+	for _, tc := range []struct {
+		path, origin, method, headers string
+		allowed                       string
+	}{
+		{productPath, "https://app.proddx.com", http.MethodPut, "Authorization, Content-Type", "https://app.proddx.com"},
+		{productPath, "https://shop.example.com", http.MethodDelete, "", "https://shop.example.com"},
+		{"/products/" + uuid.NewV4().String(), "https://app.proddx.com", http.MethodPut, "", "https://app.proddx.com"},
+		{productPath, "https://example.com", http.MethodPut, "", ""},
+		{productPath, "http://shop.example.com", http.MethodPut, "", ""},
+		{productPath, "https://evil.com", http.MethodPut, "", ""},
+		{productPath, "https://app.proddx.com", http.MethodPut, "X-Custom", ""},
+		{productPath, "https://app.proddx.com", http.MethodPost, "", ""},
+		{"/v1/reviews", "https://customer.shop", http.MethodPost, "Content-Type", "*"},
+		{"/v1/reviews", "https://customer.shop", http.MethodGet, "Authorization", ""},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodOptions, tc.path, nil)
+		r.Header.Set("Origin", tc.origin)
+		r.Header.Set("Access-Control-Request-Method", tc.method)
+		if tc.headers != "" {
+			r.Header.Set("Access-Control-Request-Headers", tc.headers)
+		}
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Error: Expected preflight %s %s to succeed: %d", tc.method, tc.path, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.allowed {
+			t.Errorf("Error: Preflight %s %s from %s allowed %q, expected %q", tc.method, tc.path, tc.origin, got, tc.allowed)
+		}
+		if tc.allowed == "" {
+			continue
+		}
+		credentials := w.Header().Get("Access-Control-Allow-Credentials") == "true"
+		if credentials != (tc.allowed != "*") {
+			t.Errorf("Error: Unexpected credentials for %s %s: %v", tc.method, tc.path, credentials)
+		}
+		if tc.allowed != "*" && (w.Header().Get("Access-Control-Max-Age") != "600" || !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch)) {
+			t.Errorf("Error: Unexpected preflight headers %v", w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodOptions, productPath, nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || !strings.Contains(w.Header().Get("Allow"), http.MethodPut) {
+		t.Errorf("Error: Expected OPTIONS to list the allowed methods: %d %v", w.Code, w.Header())
+	}
+
+	for origin, allowed := range map[string]string{"https://app.proddx.com": "https://app.proddx.com", "https://evil.com": ""} {
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodGet, productPath, nil)
+		r.Header.Set("Origin", origin)
+		router.ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != allowed {
+			t.Errorf("Error: Response to %s allowed %q, expected %q", origin, got, allowed)
+		}
+		if allowed != "" && !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+			t.Errorf("Error: Expected the request ID header to be exposed: %v", w.Header())
+		}
+		if !strings.Contains(strings.Join(w.Header()["Vary"], ","), "Origin") {
+			t.Errorf("Error: Expected responses to vary by origin")
+		}
+	}
+}
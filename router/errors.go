@@ -0,0 +1,38 @@
+package router
+
+import (
+	"net/http"
+
+	"api.proddx.com/problem"
+)
+
+// Error codes specific to proddx workflows, in addition to the generic codes
+// of the problem package.
+const (
+	codeWeakPassword    = "weak_password"
+	codeEmailTaken      = "email_taken"
+	codeEmailUnverified = "email_unverified"
+	codeInvalidCode     = "invalid_code"
+	codeInvalidAPIKey   = "invalid_api_key"
+	codeLoginState      = "invalid_login_state"
+	codeAlreadyMember   = "already_member"
+	codeLastOwner       = "last_owner"
+	codeTooManyAttempts = "too_many_attempts"
+)
+
+func notFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "No route matches "+r.URL.Path)
+	}
+}
+
+func methodNotAllowed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	}
+}
+
+func panicHandler(w http.ResponseWriter, r *http.Request, err interface{}) {
+	logger(r).Error("Panic", "panic", err)
+	problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+}
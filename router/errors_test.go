@@ -0,0 +1,60 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api.proddx.com/problem"
+	"api.proddx.com/requestid"
+	"api.proddx.com/storage"
+)
+
+func TestProblemResponses(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{http.MethodGet, "/unknown", http.StatusNotFound, problem.CodeNotFound},
+		{http.MethodPatch, "/v1/reviews", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed},
+		{http.MethodGet, "/v1/me", http.StatusUnauthorized, problem.CodeInvalidToken},
+		{http.MethodGet, "/v1/products/not-a-uuid", http.StatusBadRequest, problem.CodeInvalidID},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(c.method, c.path, nil)
+		r.Header.Set(requestid.Header, "test-request")
+		router.ServeHTTP(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("Error: Expected %s %s to return %d: %d", c.method, c.path, c.status, w.Code)
+			continue
+		}
+		if w.Header().Get("Content-Type") != problem.ContentType {
+			t.Errorf("Error: Unexpected content type for %s %s: %s", c.method, c.path, w.Header().Get("Content-Type"))
+		}
+		var res problem.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if res.Status != c.status || res.Code != c.code || res.Type == "" || res.Instance != c.path {
+			t.Errorf("Error: Unexpected problem for %s %s: %s", c.method, c.path, w.Body.String())
+		}
+		if res.RequestID != "test-request" || w.Header().Get(requestid.Header) != "test-request" {
+			t.Errorf("Error: Expected the request ID to be returned for %s %s: %s", c.method, c.path, w.Body.String())
+		}
+		if strings.Contains(res.Detail, "uuid:") {
+			t.Errorf("Error: Internal error leaked for %s %s: %s", c.method, c.path, res.Detail)
+		}
+	}
+}
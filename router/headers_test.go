@@ -0,0 +1,34 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api.proddx.com/storage"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	router := New(new(storage.UserMemoryStore), new(storage.CompanyMemoryStore), new(storage.ProductMemoryStore), new(storage.ReviewMemoryStore), WithIssuer(testIssuer), conforms(t))
+
+	for path, csp := range map[string]string{
+		"/v1/me":   contentSecurityPolicy,
+		"/docs":    docsPolicy,
+		"/healthz": contentSecurityPolicy,
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, r)
+
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("Referrer-Policy") != "no-referrer" {
+			t.Errorf("Error: Missing security headers on %s: %v", path, w.Header())
+		}
+		if w.Header().Get("Content-Security-Policy") != csp {
+			t.Errorf("Error: Unexpected Content-Security-Policy on %s: %s", path, w.Header().Get("Content-Security-Policy"))
+		}
+	}
+	if !strings.Contains(docsPolicy, "'sha256-") {
+		t.Errorf("Error: Expected the docs script to be allowed by its hash: %s", docsPolicy)
+	}
+}
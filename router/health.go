@@ -0,0 +1,60 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	healthOK     = "ok"
+	healthFailed = "failed"
+)
+
+// readinessTimeout bounds each readiness check, so that a hung dependency
+// fails the probe instead of blocking it.
+const readinessTimeout = 2 * time.Second
+
+// readinessCheck is a dependency that must be available to serve requests.
+type readinessCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+// healthz reports that the process is up and serving requests.
+func healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, health{Status: healthOK})
+	}
+}
+
+// readyz runs the readiness checks and reports 503 Service Unavailable if
+// any of them fails. Failures are logged rather than returned.
+func readyz(checks []readinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		result := health{Status: healthOK, Checks: map[string]string{}}
+		for _, c := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			err := c.check(ctx)
+			cancel()
+			if err != nil {
+				logger(r).Warn("Readiness check failed", "check", c.name, "error", err)
+				status = http.StatusServiceUnavailable
+				result.Status = healthFailed
+				result.Checks[c.name] = healthFailed
+				continue
+			}
+			result.Checks[c.name] = healthOK
+		}
+		writeHealth(w, status, result)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, h health) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api.proddx.com/storage"
+)
+
+func TestHealth(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	var dbErr error
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t),
+		WithReadinessCheck("database", func(ctx context.Context) error { return dbErr }))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET /healthz to be valid: %d", w.Code)
+	}
+
+	for _, tc := range []struct {
+		err    error
+		status int
+		check  string
+	}{
+		{nil, http.StatusOK, healthOK},
+		{errors.New("connection refused"), http.StatusServiceUnavailable, healthFailed},
+	} {
+		dbErr = tc.err
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodGet, "/readyz", nil)
+		router.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Fatalf("Error: Expected %d from /readyz, got %d", tc.status, w.Code)
+		}
+		var resp health
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if resp.Status != tc.check || resp.Checks["database"] != tc.check {
+			t.Errorf("Error: Unexpected readiness %v", resp)
+		}
+	}
+}
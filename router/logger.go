@@ -1,31 +1,93 @@
-/*
- * Proddx API
- *
- * No description provided (generated by Swagger Codegen https://github.com/swagger-api/swagger-codegen)
- *
- * API version: 0.1.0
- * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
- */
 package router
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/metrics"
+	"api.proddx.com/requestid"
 )
 
+// Logger names the route of the request in its log lines and metrics.
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
+		logging.AddFields(r.Context(), "route", name)
+		if route, ok := r.Context().Value(routeContextKey).(*string); ok {
+			*route = name
+		}
 		inner.ServeHTTP(w, r)
+	})
+}
+
+// unmatchedRoute names requests that did not match a route in metrics.
+const unmatchedRoute = "Unmatched"
+
+// routeContextKey holds a pointer to the route name, which accessLog creates
+// and Logger fills in once the router has matched the request, so that the
+// access log, metrics and spans can be tagged with it.
+const routeContextKey contextKey = "route"
+
+// logger returns the logger of the request, which tags lines with the
+// request ID, route and user.
+func logger(r *http.Request) *logging.Logger {
+	return logging.FromContext(r.Context())
+}
+
+// statusRecorder remembers the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.size += n
+	return n, err
+}
+
+// Flush lets streamed responses through.
+func (s *statusRecorder) Flush() {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// accessLog gives every request a logger tagged with its ID, and logs and
+// measures the request once it has been handled.
+func accessLog(base *logging.Logger, m *metrics.Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := unmatchedRoute
+		ctx := logging.NewContext(r.Context(), base.With("request_id", requestid.FromContext(r.Context())))
+		ctx = context.WithValue(ctx, routeContextKey, &route)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
 
-		log.Printf(
-			"%s %s %s %s",
-			r.Method,
-			r.RequestURI,
-			name,
-			time.Since(start),
-		)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		level := logging.Info
+		if rec.status >= http.StatusInternalServerError {
+			level = logging.Error
+		}
+		duration := time.Since(start)
+		logging.FromContext(ctx).Log(level, "Request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "size", rec.size, "duration", duration)
+		m.ObserveRequest(route, r.Method, rec.status, duration)
 	})
 }
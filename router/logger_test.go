@@ -0,0 +1,57 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/requestid"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logging.New(&buf, logging.JSON, logging.Debug)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithLogger(log))
+
+	userID := uuid.NewV4().String()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/v1/companies/"+uuid.NewV4().String(), nil)
+	r.Header.Set(requestid.Header, "logged-request")
+	authorize(t, r, userID)
+	router.ServeHTTP(w, r)
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Error: Expected a handler line and a request line: %s", buf.String())
+	}
+	for _, line := range lines {
+		if line["request_id"] != "logged-request" || line["route"] != "FindCompany" || line["user_id"] != userID {
+			t.Errorf("Error: Expected the line to be tagged with the request: %v", line)
+		}
+	}
+	access := lines[1]
+	if access["msg"] != "Request" || access["status"] != float64(http.StatusForbidden) || access["size"] != float64(w.Body.Len()) {
+		t.Errorf("Error: Unexpected request line: %v", access)
+	}
+}
@@ -0,0 +1,276 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"api.proddx.com/mail"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	roleOwner   = "owner"
+	roleAdmin   = "admin"
+	roleAnalyst = "analyst"
+	roleViewer  = "viewer"
+)
+
+// roleRanks orders roles so that each role is granted everything the roles
+// below it are: viewers read company data, analysts also manage reviews,
+// admins also manage products, the company profile and members, and owners
+// can additionally delete the company and manage other owners.
+var roleRanks = map[string]int{
+	roleViewer:  1,
+	roleAnalyst: 2,
+	roleAdmin:   3,
+	roleOwner:   4,
+}
+
+const forbidden = "Insufficient permissions for this company"
+
+// hasRole reports whether the user is a member of the company with at least
+// the given role.
+func hasRole(ctx context.Context, storage storage.Member, userID, companyID, role string) bool {
+	record, err := storage.Find(ctx, companyID, userID)
+	if err != nil {
+		return false
+	}
+	return roleRanks[record.Role] >= roleRanks[role]
+}
+
+// memberCompanies returns the IDs of the companies the user belongs to.
+func memberCompanies(ctx context.Context, storage storage.Member, userID string) (map[string]bool, error) {
+	records, err := storage.List(ctx, "", userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(records))
+	for _, record := range records {
+		ids[record.CompanyID.String()] = true
+	}
+	return ids, nil
+}
+
+func saveOwner(ctx context.Context, storage storage.Member, companyID, userID string) error {
+	return storage.Save(ctx, memberToStorage(&member{
+		CompanyID: companyID,
+		UserID:    userID,
+		Role:      roleOwner,
+		CreatedAt: time.Now(),
+	}))
+}
+
+func sendInvitation(issuer *tokens.Issuer, mailer mail.Mailer, dashboardURL string, comp *storage.CompanyModel, inv tokens.Invitation) error {
+	token, err := issuer.NewInvitation(inv)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("https://%s/invitations/accept?token=%s", dashboardURL, url.QueryEscape(token))
+	return mailer.Send(mail.Message{
+		To:      inv.Email,
+		Subject: fmt.Sprintf("You have been invited to %s on Proddx", comp.CompanyName),
+		Text:    fmt.Sprintf("You have been invited to join %s as %s. Accept the invitation by opening the link below:\n\n%s\n\nThe link expires in 7 days.", comp.CompanyName, inv.Role, link),
+	})
+}
+
+func listMembers(memberStorage storage.Member, userStorage storage.User) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if !hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), id, roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+
+		records, err := memberStorage.List(r.Context(), id, "")
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		resp := []member{}
+		for _, record := range records {
+			m := memberFromStorage(&record)
+			if u, err := userStorage.Find(r.Context(), m.UserID); err == nil {
+				m.Email = u.Email
+			}
+			resp = append(resp, *m)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func inviteMember(memberStorage storage.Member, companyStorage storage.Company, issuer *tokens.Issuer, mailer mail.Mailer, dashboardURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(memberRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		userID := tokens.UserID(r.Context())
+		if !hasRole(r.Context(), memberStorage, userID, id, roleAdmin) || (req.Role == roleOwner && !hasRole(r.Context(), memberStorage, userID, id, roleOwner)) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+		comp, err := companyStorage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+
+		inv := tokens.Invitation{CompanyID: id, Email: strings.TrimSpace(req.Email), Role: req.Role}
+		if err = sendInvitation(issuer, mailer, dashboardURL, comp, inv); err != nil {
+			logger(r).Error("Mail error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(invitation{CompanyID: inv.CompanyID, Email: inv.Email, Role: inv.Role})
+	}
+}
+
+func acceptInvitation(memberStorage storage.Member, userStorage storage.User, issuer *tokens.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(invitationRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		inv, err := issuer.ParseInvitation(req.Token)
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidToken, "Token is invalid or expired")
+			return
+		}
+		record, err := userStorage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("User storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !strings.EqualFold(record.Email, inv.Email) || !record.EmailVerified {
+			logger(r).Warn("The invitation was sent to a different or unverified email address")
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "The invitation was sent to a different or unverified email address")
+			return
+		}
+		if _, err = memberStorage.Find(r.Context(), inv.CompanyID, record.ID.String()); err == nil {
+			logger(r).Warn("Already a member of this company")
+			problem.Error(w, r, http.StatusConflict, codeAlreadyMember, "Already a member of this company")
+			return
+		}
+
+		m := &member{
+			CompanyID: inv.CompanyID,
+			UserID:    record.ID.String(),
+			Email:     record.Email,
+			Role:      inv.Role,
+			CreatedAt: time.Now(),
+		}
+		if err = memberStorage.Save(r.Context(), memberToStorage(m)); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(*m)
+	}
+}
+
+func removeMember(storage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		memberID := params.ByName("user_id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if _, err := uuid.FromString(memberID); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+
+		record, err := storage.Find(r.Context(), id, memberID)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		userID := tokens.UserID(r.Context())
+		required := roleAdmin
+		if record.Role == roleOwner {
+			required = roleOwner
+		}
+		if userID != memberID && !hasRole(r.Context(), storage, userID, id, required) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+		if record.Role == roleOwner {
+			owners := 0
+			records, err := storage.List(r.Context(), id, "")
+			if err != nil {
+				logger(r).Error("Storage error", "error", err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+				return
+			}
+			for _, m := range records {
+				if m.Role == roleOwner {
+					owners++
+				}
+			}
+			if owners < 2 {
+				logger(r).Warn("A company must keep at least one owner")
+				problem.Error(w, r, http.StatusConflict, codeLastOwner, "A company must keep at least one owner")
+				return
+			}
+		}
+
+		if err := storage.Delete(r.Context(), id, memberID); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,249 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func saveMember(t *testing.T, ms storage.Member, companyID uuid.UUID, role string) string {
+	mm := &storage.MemberModel{
+		CompanyID: companyID,
+		UserID:    uuid.NewV4(),
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+	if err := ms.Save(context.Background(), mm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	return mm.UserID.String()
+}
+
+func TestListMembers(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+
+	companyID := uuid.NewV4()
+	viewerID := saveMember(t, memberStore, companyID, roleViewer)
+	saveMember(t, memberStore, companyID, roleOwner)
+	saveMember(t, memberStore, uuid.NewV4(), roleOwner)
+
+	route := fmt.Sprintf("/v1/companies/%s/members", companyID)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, viewerID)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	var res []member
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(res) != 2 {
+		t.Errorf("Error: %s - %d", "Wrong number of records", len(res))
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, uuid.NewV4().String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected route GET %s to be forbidden for non-members: %d", route, w.Code)
+	}
+}
+
+func TestInviteMember(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	mailer := new(mailRecorder)
+
+	_, cm := saveAccount(t, userStore, companyStore, "password")
+	adminID := saveMember(t, memberStore, cm.ID, roleAdmin)
+	invitee := &storage.UserModel{
+		ID:            uuid.NewV4(),
+		Email:         "analyst@domain.com",
+		EmailVerified: true,
+		CreatedAt:     time.Now(),
+	}
+	if err := userStore.Save(context.Background(), invitee); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithMailer(mailer))
+
+	route := fmt.Sprintf("/v1/companies/%s/members", cm.ID)
+	reqJSON, _ := json.Marshal(memberRequest{Email: invitee.Email, Role: roleOwner})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected route POST %s to forbid admins from inviting owners: %d", route, w.Code)
+	}
+
+	reqJSON, _ = json.Marshal(memberRequest{Email: invitee.Email, Role: roleAnalyst})
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected route POST %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To != invitee.Email {
+		t.Fatalf("Error: Expected one invitation email to %s: %v", invitee.Email, mailer.messages)
+	}
+
+	token := verificationToken(t, mailer.messages[0])
+	reqJSON, _ = json.Marshal(invitationRequest{Token: token})
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, "/v1/invitations/accept", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code == http.StatusCreated {
+		t.Fatal("Expected route POST /invitations/accept to reject other users")
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, "/v1/invitations/accept", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, invitee.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST /invitations/accept to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	record, err := memberStore.Find(context.Background(), cm.ID.String(), invitee.ID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.Role != roleAnalyst {
+		t.Errorf("Error: %s: %s - %s", "Role inconsistency", record.Role, roleAnalyst)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+
+	companyID := uuid.NewV4()
+	ownerID := saveMember(t, memberStore, companyID, roleOwner)
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	viewerID := saveMember(t, memberStore, companyID, roleViewer)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
+
+	attempts := []struct {
+		actor  string
+		target string
+		status int
+	}{
+		{viewerID, adminID, http.StatusForbidden},
+		{adminID, ownerID, http.StatusForbidden},
+		{ownerID, ownerID, http.StatusConflict},
+		{adminID, viewerID, http.StatusNoContent},
+		{adminID, adminID, http.StatusNoContent},
+	}
+	for _, attempt := range attempts {
+		route := fmt.Sprintf("/v1/companies/%s/members/%s", companyID, attempt.target)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodDelete, route, nil)
+		authorize(t, r, attempt.actor)
+		router.ServeHTTP(w, r)
+
+		if w.Code != attempt.status {
+			t.Errorf("Expected route DELETE %s to return %d: %d - %s", route, attempt.status, w.Code, w.Body.String())
+		}
+	}
+	records, _ := memberStore.List(context.Background(), companyID.String(), "")
+	if len(records) != 1 || records[0].UserID.String() != ownerID {
+		t.Errorf("Error: Expected only the owner to remain: %v", records)
+	}
+}
+
+func TestRoleAuthorization(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+
+	companyID := uuid.NewV4()
+	pm := &storage.ProductModel{
+		ID:          uuid.NewV4(),
+		CompanyID:   companyID,
+		ProductName: "Product One",
+		CreatedAt:   time.Now(),
+	}
+	if err := productStore.Save(context.Background(), pm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	rm := &storage.ReviewModel{
+		ID:        uuid.NewV4(),
+		CompanyID: companyID,
+		ProductID: pm.ID,
+		Comment:   "Lorem ipsum dolor sit amet",
+		Rating:    3,
+		CreatedAt: time.Now(),
+	}
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	viewerID := saveMember(t, memberStore, companyID, roleViewer)
+	analystID := saveMember(t, memberStore, companyID, roleAnalyst)
+	outsiderID := saveMember(t, memberStore, uuid.NewV4(), roleOwner)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
+
+	reviewJSON, _ := json.Marshal(reviewRequest{Comment: "Updated"})
+	productJSON, _ := json.Marshal(productRequest{Name: "Product Two"})
+	attempts := []struct {
+		actor  string
+		method string
+		route  string
+		body   []byte
+		status int
+	}{
+		{viewerID, http.MethodGet, "/v1/reviews/" + rm.ID.String(), nil, http.StatusOK},
+		{outsiderID, http.MethodGet, "/v1/reviews/" + rm.ID.String(), nil, http.StatusForbidden},
+		{outsiderID, http.MethodGet, "/v1/reviews?company_id=" + companyID.String(), nil, http.StatusForbidden},
+		{outsiderID, http.MethodGet, "/v1/products", nil, http.StatusNotFound},
+		{viewerID, http.MethodPut, "/v1/reviews/" + rm.ID.String(), reviewJSON, http.StatusForbidden},
+		{analystID, http.MethodPut, "/v1/reviews/" + rm.ID.String(), reviewJSON, http.StatusOK},
+		{analystID, http.MethodPut, "/v1/products/" + pm.ID.String(), productJSON, http.StatusForbidden},
+	}
+	for _, attempt := range attempts {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(attempt.method, attempt.route, bytes.NewReader(attempt.body))
+		r.Header.Set("Content-Type", "application/json")
+		authorize(t, r, attempt.actor)
+		router.ServeHTTP(w, r)
+
+		if w.Code != attempt.status {
+			t.Errorf("Expected route %s %s to return %d: %d - %s", attempt.method, attempt.route, attempt.status, w.Code, w.Body.String())
+		}
+	}
+}
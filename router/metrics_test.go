@@ -0,0 +1,49 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api.proddx.com/metrics"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestMetrics(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMetrics(metrics.New()))
+
+	companyID := uuid.NewV4()
+	productID := saveProduct(t, productStore, companyID)
+	reqJSON, _ := json.Marshal(reviewRequest{CompanyID: companyID.String(), ProductID: productID, Comment: "Great", Rating: 4})
+	r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest(http.MethodGet, "/unknown", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	w := httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/metrics", nil)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET /metrics to be valid: %d", w.Code)
+	}
+	for _, line := range []string{
+		`proddx_http_requests_total{method="POST",route="InsertReview",status="201"} 1`,
+		`proddx_http_requests_total{method="GET",route="Unmatched",status="404"} 1`,
+		`proddx_http_request_duration_seconds_count{method="POST",route="InsertReview"} 1`,
+		`proddx_reviews_submitted_total{rating="4"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("Error: Expected %s in the metrics", line)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"api.proddx.com/notifications"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+)
+
+// notificationParams returns the company ID of the request followed by the
+// named IDs, or writes an error and returns false when they are malformed or
+// the caller lacks role.
+func notificationParams(w http.ResponseWriter, r *http.Request, memberStorage storage.Member, role string, names ...string) ([]string, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	var values []string
+	for _, name := range append([]string{"id"}, names...) {
+		value := params.ByName(name)
+		if _, err := uuid.FromString(value); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	if !hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), values[0], role) {
+		logger(r).Warn(forbidden)
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+		return nil, false
+	}
+	return values, true
+}
+
+// findNotificationPreferences returns the defaults to companies that have
+// not saved preferences.
+func findNotificationPreferences(prefStorage storage.NotificationPreference, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := notificationParams(w, r, memberStorage, roleViewer)
+		if !ok {
+			return
+		}
+		id := ids[0]
+
+		record, err := prefStorage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if record == nil {
+			record = notifications.Default(uuid.FromStringOrNil(id))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(*notificationPreferencesFromStorage(record))
+	}
+}
+
+// updateNotificationPreferences starts a new digest period when digests are
+// turned on or their frequency changes, so that the first digest does not
+// summarize reviews from before.
+func updateNotificationPreferences(prefStorage storage.NotificationPreference, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(notificationPreferencesRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		ids, ok := notificationParams(w, r, memberStorage, roleAdmin)
+		if !ok {
+			return
+		}
+		id := ids[0]
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+
+		existing, err := prefStorage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		now := time.Now()
+		model := notifications.Default(uuid.FromStringOrNil(id))
+		model.Mode = req.Mode
+		if req.Threshold != 0 {
+			model.Threshold = req.Threshold
+		}
+		if req.Frequency != "" {
+			model.Frequency = req.Frequency
+		}
+		model.DigestedAt = now
+		if existing != nil && existing.Mode == storage.NotifyDigest && existing.Frequency == model.Frequency {
+			model.DigestedAt = existing.DigestedAt
+		}
+		model.UpdatedAt = now
+		if err := prefStorage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(*notificationPreferencesFromStorage(model))
+	}
+}
+
+func listNotificationChannels(channelStorage storage.NotificationChannel, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := notificationParams(w, r, memberStorage, roleAdmin)
+		if !ok {
+			return
+		}
+
+		records, err := channelStorage.List(r.Context(), ids[0])
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		resp := []notificationChannel{}
+		for _, record := range records {
+			resp = append(resp, *notificationChannelFromStorage(&record))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func createNotificationChannel(channelStorage storage.NotificationChannel, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(notificationChannelRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		ids, ok := notificationParams(w, r, memberStorage, roleAdmin)
+		if !ok {
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+
+		model := &storage.NotificationChannelModel{
+			ID:        uuid.NewV4(),
+			CompanyID: uuid.FromStringOrNil(ids[0]),
+			URL:       req.URL,
+			Format:    req.Format,
+			CreatedAt: time.Now(),
+		}
+		if err := channelStorage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(*notificationChannelFromStorage(model))
+	}
+}
+
+func deleteNotificationChannel(channelStorage storage.NotificationChannel, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := notificationParams(w, r, memberStorage, roleAdmin, "channel_id")
+		if !ok {
+			return
+		}
+
+		if err := channelStorage.Delete(r.Context(), ids[0], ids[1]); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,188 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	prefStore := new(storage.NotificationPreferenceMemoryStore)
+
+	companyID := uuid.NewV4()
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	viewerID := saveMember(t, memberStore, companyID, roleViewer)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithNotificationPreferenceStore(prefStore))
+
+	route := fmt.Sprintf("/v1/companies/%s/notifications", companyID)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, viewerID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET %s to be valid: %d", route, w.Code)
+	}
+	var prefs notificationPreferences
+	if err := json.Unmarshal(w.Body.Bytes(), &prefs); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if prefs.Mode != storage.NotifyBelowThreshold || prefs.Threshold != 3 || prefs.UpdatedAt != nil {
+		t.Errorf("Error: Expected the default preferences: %+v", prefs)
+	}
+
+	reqJSON, _ := json.Marshal(notificationPreferencesRequest{Mode: storage.NotifyDigest, Frequency: storage.DigestWeekly})
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPut, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, viewerID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected route PUT %s to be forbidden for viewers: %d", route, w.Code)
+	}
+
+	for _, invalid := range []notificationPreferencesRequest{
+		{},
+		{Mode: "sometimes"},
+		{Mode: storage.NotifyBelowThreshold, Threshold: 1},
+		{Mode: storage.NotifyDigest, Frequency: "hourly"},
+	} {
+		invalidJSON, _ := json.Marshal(invalid)
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodPut, route, bytes.NewReader(invalidJSON))
+		r.Header.Set("Content-Type", "application/json")
+		authorize(t, r, adminID)
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected route PUT %s to reject %+v: %d", route, invalid, w.Code)
+		}
+	}
+
+	before := time.Now()
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPut, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route PUT %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	record, err := prefStore.Find(context.Background(), companyID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.Mode != storage.NotifyDigest || record.Frequency != storage.DigestWeekly || record.DigestedAt.Before(before) {
+		t.Errorf("Error: Expected a weekly digest starting now: %+v", record)
+	}
+
+	// Saving the same digest again keeps its period.
+	digestedAt := record.DigestedAt
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPut, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	record, _ = prefStore.Find(context.Background(), companyID.String())
+	if !record.DigestedAt.Equal(digestedAt) {
+		t.Errorf("Error: Expected the digest period to be kept: %v %v", record.DigestedAt, digestedAt)
+	}
+}
+
+func TestNotificationChannels(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	channelStore := new(storage.NotificationChannelMemoryStore)
+
+	companyID := uuid.NewV4()
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	analystID := saveMember(t, memberStore, companyID, roleAnalyst)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithNotificationChannelStore(channelStore))
+
+	route := fmt.Sprintf("/v1/companies/%s/notifications/channels", companyID)
+	reqJSON, _ := json.Marshal(notificationChannelRequest{URL: "https://hooks.slack.com/services/T000/B000/XXXX", Format: storage.ChannelSlack})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, analystID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected route POST %s to be forbidden for analysts: %d", route, w.Code)
+	}
+
+	for _, invalid := range []notificationChannelRequest{
+		{URL: "http://hooks.slack.com/services/T000/B000/XXXX", Format: storage.ChannelSlack},
+		{URL: "https://hooks.slack.com/services/T000/B000/XXXX", Format: "discord"},
+		{Format: storage.ChannelTeams},
+	} {
+		invalidJSON, _ := json.Marshal(invalid)
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodPost, route, bytes.NewReader(invalidJSON))
+		r.Header.Set("Content-Type", "application/json")
+		authorize(t, r, adminID)
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected route POST %s to reject %+v: %d", route, invalid, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	var created notificationChannel
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	var channels []notificationChannel
+	if err := json.Unmarshal(w.Body.Bytes(), &channels); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(channels) != 1 || channels[0].ID != created.ID || channels[0].Format != storage.ChannelSlack {
+		t.Errorf("Error: Unexpected channels %v", channels)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodDelete, route+"/"+created.ID, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected route DELETE %s/%s to be valid: %d", route, created.ID, w.Code)
+	}
+	if records, _ := channelStore.List(context.Background(), companyID.String()); len(records) != 0 {
+		t.Errorf("Error: Expected the channel to be deleted: %v", records)
+	}
+}
@@ -0,0 +1,161 @@
+package router
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"api.proddx.com/oidc"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// dashboardRedirect sends the browser back to the dashboard with the result
+// of a single sign-on login in the URL fragment, which is not sent to
+// servers or written to access logs.
+func dashboardRedirect(w http.ResponseWriter, r *http.Request, dashboardURL string, params url.Values) {
+	link := fmt.Sprintf("https://%s/auth/callback#%s", dashboardURL, params.Encode())
+	http.Redirect(w, r, link, http.StatusFound)
+}
+
+// oidcCookiePath limits the login state cookie to the routes of the
+// provider, under whichever API version the login was started.
+func oidcCookiePath(r *http.Request) string {
+	return path.Dir(r.URL.Path) + "/"
+}
+
+func startOIDC(providers map[string]*oidc.Provider, issuer *tokens.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+		provider, ok := providers[name]
+		if !ok {
+			logger(r).Warn("Unknown identity provider", "provider", name)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "Unknown identity provider")
+			return
+		}
+
+		state := tokens.OIDCState{Provider: name}
+		var err error
+		for _, value := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+			if *value, err = oidc.RandomString(); err != nil {
+				logger(r).Error("OIDC error", "error", err)
+				problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+				return
+			}
+		}
+		authURL, err := provider.AuthCodeURL(r.Context(), state.State, state.Nonce, state.Verifier)
+		if err != nil {
+			logger(r).Error("OIDC error", "error", err)
+			problem.Error(w, r, http.StatusBadGateway, problem.CodeBadGateway, "Identity provider unavailable")
+			return
+		}
+		cookie, err := issuer.NewOIDCState(state)
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    cookie,
+			Path:     oidcCookiePath(r),
+			MaxAge:   int((10 * time.Minute).Seconds()),
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+func oidcCallback(providers map[string]*oidc.Provider, userStorage storage.User, issuer *tokens.Issuer, dashboardURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+		provider, ok := providers[name]
+		if !ok {
+			logger(r).Warn("Unknown identity provider", "provider", name)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "Unknown identity provider")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCookiePath(r), MaxAge: -1, Secure: true, HttpOnly: true})
+
+		q := r.URL.Query()
+		if q.Get("error") != "" {
+			logger(r).Warn("OIDC error", "error", q.Get("error"), "description", q.Get("error_description"))
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Login was cancelled or denied by the identity provider")
+			return
+		}
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil {
+			logger(r).Warn("Missing login state")
+			problem.Error(w, r, http.StatusBadRequest, codeLoginState, "Missing login state")
+			return
+		}
+		state, err := issuer.ParseOIDCState(cookie.Value)
+		if err != nil || state.Provider != name || subtle.ConstantTimeCompare([]byte(state.State), []byte(q.Get("state"))) != 1 {
+			logger(r).Warn("Invalid login state")
+			problem.Error(w, r, http.StatusBadRequest, codeLoginState, "Invalid login state")
+			return
+		}
+
+		claims, err := provider.Authenticate(r.Context(), q.Get("code"), state.Verifier, state.Nonce)
+		if err != nil {
+			logger(r).Error("OIDC error", "error", err)
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Login could not be verified with the identity provider")
+			return
+		}
+		if claims.Email == "" || !claims.EmailVerified {
+			logger(r).Warn("The identity provider did not return a verified email address")
+			problem.Error(w, r, http.StatusForbidden, codeEmailUnverified, "The identity provider did not return a verified email address")
+			return
+		}
+
+		record, err := userStorage.Find(r.Context(), claims.Email)
+		if err != nil {
+			record = &storage.UserModel{
+				ID:            uuid.NewV4(),
+				Email:         claims.Email,
+				EmailVerified: true,
+				CreatedAt:     time.Now(),
+			}
+			if err = userStorage.Save(r.Context(), record); err != nil {
+				logger(r).Error("User storage error", "error", err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+				return
+			}
+		} else if !record.EmailVerified {
+			// Whoever registered the address first may not own it, and
+			// linking would hand them the account of its real owner.
+			logger(r).Warn("Refusing to link an unverified account", "account", record.ID.String())
+			problem.Error(w, r, http.StatusForbidden, codeEmailUnverified, "Verify the email address of the existing account before signing in with the identity provider")
+			return
+		}
+
+		if record.TOTPEnabled {
+			challenge, err := issuer.NewChallenge(record.ID.String())
+			if err != nil {
+				logger(r).Error("Token error", "error", err)
+				problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+				return
+			}
+			dashboardRedirect(w, r, dashboardURL, url.Values{"challenge_token": {challenge}})
+			return
+		}
+		token, err := issuer.New(record.ID.String())
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		dashboardRedirect(w, r, dashboardURL, url.Values{"token": {token}})
+	}
+}
@@ -0,0 +1,191 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"api.proddx.com/oidc"
+	"api.proddx.com/oidc/oidctest"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+var noRedirects = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func testProviders(server *oidctest.Server) map[string]*oidc.Provider {
+	return map[string]*oidc.Provider{
+		"test": oidc.NewProvider(oidc.Config{
+			Issuer:       server.URL,
+			ClientID:     server.ClientID,
+			ClientSecret: server.ClientSecret,
+			RedirectURL:  "https://api.proddx.com/v1/auth/oidc/test/callback",
+		}),
+	}
+}
+
+// signIn starts a single sign-on login, lets the stub provider authorize it
+// and returns the request the provider redirects the browser back with.
+func signIn(t *testing.T, router http.Handler) *http.Request {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/v1/auth/oidc/test/start", nil)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected route GET /auth/oidc/test/start to redirect: %d - %s", w.Code, w.Body.String())
+	}
+	res, err := noRedirects.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	res.Body.Close()
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func callbackFragment(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected route GET /auth/oidc/test/callback to redirect: %d - %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	return fragment
+}
+
+func TestOIDCLogin(t *testing.T) {
+	server := oidctest.NewServer("proddx", "secret")
+	defer server.Close()
+	server.Email = "sso@domain.com"
+
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithOIDCProviders(testProviders(server)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signIn(t, router))
+	token := callbackFragment(t, w).Get("token")
+
+	w = httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/v1/me", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the single sign-on token to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	var res account
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if res.User.Email != server.Email || !res.User.EmailVerified {
+		t.Errorf("Error: Unexpected account: %v", res.User)
+	}
+}
+
+func TestOIDCLoginLinksAccount(t *testing.T) {
+	server := oidctest.NewServer("proddx", "secret")
+	defer server.Close()
+
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	um := &storage.UserModel{
+		ID:        uuid.NewV4(),
+		Email:     server.Email,
+		CreatedAt: time.Now(),
+	}
+	if err := userStore.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithOIDCProviders(testProviders(server)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signIn(t, router))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected an unverified account not to be linked: %d - %s", w.Code, w.Header().Get("Location"))
+	}
+
+	um.EmailVerified = true
+	if err := userStore.Update(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signIn(t, router))
+	if callbackFragment(t, w).Get("token") == "" {
+		t.Fatalf("Error: Expected a login token: %s", w.Header().Get("Location"))
+	}
+	if record, _ := userStore.Find(context.Background(), server.Email); record.ID != um.ID {
+		t.Errorf("Error: %s", "A second account was created")
+	}
+}
+
+func TestOIDCLoginRejects(t *testing.T) {
+	server := oidctest.NewServer("proddx", "secret")
+	defer server.Close()
+
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithOIDCProviders(testProviders(server)))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/v1/auth/oidc/unknown/start", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown provider to be rejected: %d", w.Code)
+	}
+
+	r = signIn(t, router)
+	q := r.URL.Query()
+	q.Set("state", "forged")
+	r.URL.RawQuery = q.Encode()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a forged state to be rejected: %d", w.Code)
+	}
+
+	r = signIn(t, router)
+	r.Header.Del("Cookie")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a callback without login state to be rejected: %d", w.Code)
+	}
+
+	server.EmailVerified = false
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signIn(t, router))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected an unverified email address to be rejected: %d", w.Code)
+	}
+	if _, err := userStore.Find(context.Background(), server.Email); err == nil {
+		t.Errorf("Error: %s", "An account was created for an unverified email address")
+	}
+}
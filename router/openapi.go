@@ -0,0 +1,157 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"api.proddx.com/api"
+	"api.proddx.com/problem"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+)
+
+// docsScript starts Swagger UI. It is allowed to run by its hash in the
+// Content-Security-Policy of the docs page.
+const docsScript = `window.ui = SwaggerUIBundle({ url: "/openapi.yaml", dom_id: "#swagger-ui" });`
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Proddx API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@4/swagger-ui-bundle.js"></script>
+  <script>` + docsScript + `</script>
+</body>
+</html>
+`
+
+func openAPISpec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.WriteHeader(http.StatusOK)
+		w.Write(api.Spec)
+	}
+}
+
+// docsPolicy lets the docs page load Swagger UI from unpkg and call the API.
+var docsPolicy = func() string {
+	hash := sha256.Sum256([]byte(docsScript))
+	return "default-src 'none'; script-src https://unpkg.com 'sha256-" + base64.StdEncoding.EncodeToString(hash[:]) + "'; " +
+		"style-src https://unpkg.com; img-src https://unpkg.com data:; connect-src 'self'; frame-ancestors 'none'"
+}()
+
+func docs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, docsPage)
+	}
+}
+
+// validateRequests rejects requests that do not conform to the OpenAPI spec
+// before they reach the handlers.
+func validateRequests(validator *api.Validator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := validator.ValidateRequest(r); err != nil {
+			logger(r).Warn("OpenAPI error", "error", err)
+			p := problem.New(r, http.StatusBadRequest, problem.CodeValidation, "Request does not conform to the API specification")
+			p.Errors = specErrors(err)
+			p.Write(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// specErrors turns an error of the OpenAPI validator into field errors,
+// without the schema dumps of its message.
+func specErrors(err error) []fieldError {
+	field, reason := "body", err.Error()
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) {
+		if requestErr.Parameter != nil {
+			field = requestErr.Parameter.Name
+		}
+		reason = requestErr.Reason
+	}
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			field = strings.Join(pointer, ".")
+		}
+		reason = schemaErr.Reason
+	}
+	return []fieldError{{Field: field, Code: codeInvalid, Message: reason}}
+}
+
+// bufferedResponse holds back a response until it has been validated.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if b.streaming {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+// Flush sends what has been buffered and stops buffering, since streamed
+// responses such as server-sent events cannot be validated as a whole.
+func (b *bufferedResponse) Flush() {
+	if !b.streaming {
+		b.streaming = true
+		b.WriteHeader(http.StatusOK)
+		b.ResponseWriter.WriteHeader(b.status)
+		b.ResponseWriter.Write(b.body.Bytes())
+		b.body.Reset()
+	}
+	if f, ok := b.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// validateResponses reports responses that do not conform to the OpenAPI
+// spec. Responses are sent unchanged.
+func validateResponses(validator *api.Validator, report func(*http.Request, error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffered := &bufferedResponse{ResponseWriter: w}
+		next.ServeHTTP(buffered, r)
+		if buffered.streaming {
+			return
+		}
+
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+		body := buffered.body.Bytes()
+		if w.Header().Get("Content-Type") == "" && len(body) > 0 {
+			w.Header().Set("Content-Type", http.DetectContentType(body))
+		}
+		if err := validator.ValidateResponse(r, buffered.status, w.Header(), body); err != nil {
+			report(r, err)
+		}
+		w.WriteHeader(buffered.status)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api.proddx.com/api"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+)
+
+var testValidator = func() *api.Validator {
+	v, err := api.NewValidator()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}()
+
+// conforms fails the test for every response of the router that does not
+// match the OpenAPI spec.
+func conforms(t *testing.T) Option {
+	return WithResponseValidation(testValidator, func(r *http.Request, err error) {
+		t.Errorf("Error: %s %s does not conform to the OpenAPI spec: %s", r.Method, r.URL.Path, err.Error())
+	})
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+
+	for path, contentType := range map[string]string{"/openapi.yaml": "application/yaml", "/docs": "text/html; charset=UTF-8"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType {
+			t.Errorf("Error: Unexpected response for %s: %d - %s", path, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), WithRequestValidation(testValidator), conforms(t))
+
+	cases := []struct {
+		body   string
+		status int
+		field  string
+	}{
+		{`{"email": "user@domain.com", "password": "password"}`, http.StatusUnauthorized, ""},
+		{`{"email": "user@domain.com"}`, http.StatusBadRequest, "password"},
+		{`{"email": 42, "password": "password"}`, http.StatusBadRequest, "email"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/login", bytes.NewBufferString(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("Error: Expected %s to return %d: %d - %s", c.body, c.status, w.Code, w.Body.String())
+		}
+		if c.field == "" {
+			continue
+		}
+		var res struct {
+			Code   string       `json:"code"`
+			Errors []fieldError `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if res.Code != problem.CodeValidation || len(res.Errors) != 1 || res.Errors[0].Field != c.field {
+			t.Errorf("Error: Unexpected problem for %s: %s", c.body, w.Body.String())
+		}
+	}
+}
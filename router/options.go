@@ -0,0 +1,276 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"api.proddx.com/api"
+	"api.proddx.com/config"
+	"api.proddx.com/logging"
+	"api.proddx.com/mail"
+	"api.proddx.com/metrics"
+	"api.proddx.com/oidc"
+	"api.proddx.com/passwords"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"api.proddx.com/webhooks"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type options struct {
+	logger  *logging.Logger
+	metrics *metrics.Metrics
+	tracer  trace.TracerProvider
+	mailer  mail.Mailer
+	issuer  *tokens.Issuer
+	ms      storage.Member
+	chs     storage.Challenge
+	ks      storage.APIKey
+	whs     storage.Webhook
+	whds    storage.WebhookDelivery
+	nps     storage.NotificationPreference
+	ncs     storage.NotificationChannel
+	events  *webhooks.Publisher
+	es      storage.Event
+	stream  streamTiming
+	idps    map[string]*oidc.Provider
+	hasher  *passwords.Hasher
+	policy  *passwords.Policy
+	urls    config.URLs
+	cors    config.CORS
+	sunset  time.Time
+	checks  []readinessCheck
+
+	strictJSON bool
+
+	requests  *api.Validator
+	responses *api.Validator
+	report    func(*http.Request, error)
+}
+
+// Option configures optional collaborators of the router returned by New.
+type Option func(*options)
+
+// WithLogger sets the logger for requests and handler errors. Lines are
+// written to standard error in logfmt when no logger is configured.
+func WithLogger(l *logging.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithMetrics records request and domain metrics and serves them at
+// /metrics. No metrics are recorded when none are configured.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithTracerProvider sets the provider of the spans created for requests.
+// The global provider is used when none is configured.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracer = tp
+	}
+}
+
+// WithMailer sets the mailer used for account emails. Messages are written
+// to the log when no mailer is configured.
+func WithMailer(m mail.Mailer) Option {
+	return func(o *options) {
+		o.mailer = m
+	}
+}
+
+// WithIssuer sets the issuer that signs and verifies tokens. An ephemeral
+// key is generated when no issuer is configured, so tokens are invalidated
+// by a restart.
+func WithIssuer(i *tokens.Issuer) Option {
+	return func(o *options) {
+		o.issuer = i
+	}
+}
+
+// WithMemberStore sets the store for company memberships. Memberships are
+// kept in memory when no store is configured.
+func WithMemberStore(ms storage.Member) Option {
+	return func(o *options) {
+		o.ms = ms
+	}
+}
+
+// WithChallengeStore sets the store that limits attempts at two-factor login
+// challenges and uses each once. Challenges are tracked in memory when no
+// store is configured.
+func WithChallengeStore(cs storage.Challenge) Option {
+	return func(o *options) {
+		o.chs = cs
+	}
+}
+
+// WithAPIKeyStore sets the store for company API keys. Keys are kept in
+// memory when no store is configured.
+func WithAPIKeyStore(ks storage.APIKey) Option {
+	return func(o *options) {
+		o.ks = ks
+	}
+}
+
+// WithWebhookStores sets the stores for webhook endpoints and the queue of
+// their deliveries, which a webhooks.Dispatcher sharing them sends. Both are
+// kept in memory when no stores are configured.
+func WithWebhookStores(ws storage.Webhook, ds storage.WebhookDelivery) Option {
+	return func(o *options) {
+		o.whs = ws
+		o.whds = ds
+	}
+}
+
+// WithNotificationPreferenceStore sets the store of how companies are
+// notified of reviews, which a notifications.Notifier sharing it follows.
+// Preferences are kept in memory when no store is configured.
+func WithNotificationPreferenceStore(ns storage.NotificationPreference) Option {
+	return func(o *options) {
+		o.nps = ns
+	}
+}
+
+// WithNotificationChannelStore sets the store of the chat channels that a
+// notifications.Poster sharing it posts new reviews to. Channels are kept in
+// memory when no store is configured.
+func WithNotificationChannelStore(cs storage.NotificationChannel) Option {
+	return func(o *options) {
+		o.ncs = cs
+	}
+}
+
+// WithEventStore sets the outbox of events that review streams follow,
+// which the review store must write to. Streams see no changes when no store
+// is configured.
+func WithEventStore(es storage.Event) Option {
+	return func(o *options) {
+		o.es = es
+	}
+}
+
+// WithOIDCProviders enables single sign-on with the given identity providers,
+// keyed by the name used in their /auth/oidc/:provider routes.
+func WithOIDCProviders(providers map[string]*oidc.Provider) Option {
+	return func(o *options) {
+		o.idps = providers
+	}
+}
+
+// WithPasswordHasher sets how passwords are hashed. Passwords are hashed
+// with bcrypt at its default cost when no hasher is configured.
+func WithPasswordHasher(h *passwords.Hasher) Option {
+	return func(o *options) {
+		o.hasher = h
+	}
+}
+
+// WithPasswordPolicy sets the policy new passwords must satisfy. Passwords
+// only need the default minimum length when no policy is configured.
+func WithPasswordPolicy(p *passwords.Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// WithURLs sets the hosts that links in emails, redirects and product
+// feedback URLs point to.
+func WithURLs(urls config.URLs) Option {
+	return func(o *options) {
+		o.urls = urls
+	}
+}
+
+// WithCORS sets the policy for calls from scripts on other sites.
+// Cross-origin calls are refused when no policy is configured, except for
+// submitting reviews and reading public documents, which any site may do.
+func WithCORS(policy config.CORS) Option {
+	return func(o *options) {
+		o.cors = policy
+	}
+}
+
+// WithLegacySunset sets the date announced in the Sunset header of the
+// deprecated unprefixed paths.
+func WithLegacySunset(t time.Time) Option {
+	return func(o *options) {
+		o.sunset = t
+	}
+}
+
+// WithReadinessCheck adds a dependency that /readyz checks, such as pinging
+// the database. The server is reported ready while all checks pass.
+func WithReadinessCheck(name string, check func(context.Context) error) Option {
+	return func(o *options) {
+		o.checks = append(o.checks, readinessCheck{name: name, check: check})
+	}
+}
+
+// WithStrictJSON rejects request bodies with fields the handlers do not
+// know, instead of ignoring them.
+func WithStrictJSON() Option {
+	return func(o *options) {
+		o.strictJSON = true
+	}
+}
+
+// WithRequestValidation rejects requests that do not conform to the OpenAPI
+// spec with 400 Bad Request before they reach the handlers.
+func WithRequestValidation(v *api.Validator) Option {
+	return func(o *options) {
+		o.requests = v
+	}
+}
+
+// WithResponseValidation calls report for every response that does not
+// conform to the OpenAPI spec. Responses are buffered until they have been
+// checked, which makes it a tool for tests and staging rather than
+// production.
+func WithResponseValidation(v *api.Validator, report func(*http.Request, error)) Option {
+	return func(o *options) {
+		o.responses = v
+		o.report = report
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		logger: logging.Default(),
+		tracer: otel.GetTracerProvider(),
+		ms:     new(storage.MemberMemoryStore),
+		chs:    new(storage.ChallengeMemoryStore),
+		ks:     new(storage.APIKeyMemoryStore),
+		whs:    new(storage.WebhookMemoryStore),
+		whds:   new(storage.WebhookDeliveryMemoryStore),
+		nps:    new(storage.NotificationPreferenceMemoryStore),
+		ncs:    new(storage.NotificationChannelMemoryStore),
+		idps:   map[string]*oidc.Provider{},
+		hasher: passwords.DefaultHasher(),
+		policy: passwords.NewPolicy(passwords.DefaultMinLength),
+		sunset: defaultLegacySunset,
+		es:     new(storage.EventMemoryStore),
+		stream: defaultStreamTiming,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.events = webhooks.NewPublisher(o.whs, o.whds)
+	if o.mailer == nil {
+		o.mailer = mail.NewLogMailer(o.logger, false)
+	}
+	if o.issuer == nil {
+		issuer, err := tokens.NewEphemeralIssuer()
+		if err != nil {
+			panic(err)
+		}
+		o.issuer = issuer
+	}
+	return o
+}
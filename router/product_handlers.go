@@ -4,38 +4,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
+	"api.proddx.com/problem"
 	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
 	"github.com/julienschmidt/httprouter"
 	uuid "github.com/satori/go.uuid"
 )
 
-func insertProduct(storage storage.Product) http.HandlerFunc {
+func insertProduct(storage storage.Product, userStorage storage.User, memberStorage storage.Member, reviewURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if requestAPIKey(r.Context()) == nil {
+			account, err := userStorage.Find(r.Context(), tokens.UserID(r.Context()))
+			if err != nil {
+				logger(r).Error("User storage error", "error", err)
+				problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Unknown user")
+				return
+			}
+			if !account.EmailVerified {
+				logger(r).Warn("Email address must be verified before creating products")
+				problem.Error(w, r, http.StatusForbidden, codeEmailUnverified, "Email address must be verified before creating products")
+				return
+			}
+		}
+
 		req := new(productRequest)
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
 
-		if req.CompanyID == "" || req.Name == "" {
-			fmt.Println("Error: company_id and name are required")
-			http.Error(w, "company_id, name and feedback_url are required", http.StatusBadRequest)
+		if errs := req.validate(true); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		if !authorized(memberStorage, r, req.CompanyID, roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
 		prod := productFromTransport(req)
 		prod.ID = uuid.NewV4().String()
-		prod.FeedbackURL = fmt.Sprintf("https://%s/%s", os.Getenv("REVIEW_URL"), prod.ID)
+		prod.FeedbackURL = fmt.Sprintf("https://%s/%s", reviewURL, prod.ID)
 		prod.Rating = 0
 		prod.CreatedAt = time.Now()
 		model := productToStorage(prod)
-		if err := storage.Save(model); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err := storage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 
@@ -45,30 +64,43 @@ func insertProduct(storage storage.Product) http.HandlerFunc {
 	}
 }
 
-func listProducts(storage storage.Product) http.HandlerFunc {
+func listProducts(storage storage.Product, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		companyID := r.URL.Query().Get("company_id")
 		if companyID != "" {
 			if _, err := uuid.FromString(companyID); err != nil {
-				fmt.Println("Marshalling error:", err.Error())
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				logger(r).Warn("Marshalling error", "error", err)
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "Query parameter must be a valid UUID")
 				return
 			}
 		}
-		records, err := storage.List(companyID)
+		if companyID != "" && !authorized(memberStorage, r, companyID, roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+		companyIDs, err := callerCompanies(memberStorage, r)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			logger(r).Error("Member storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
-		if len(records) == 0 {
-			fmt.Println("Error:", "No products found")
-			http.Error(w, "No products found", http.StatusNotFound)
+		records, err := storage.List(r.Context(), companyID)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 		var resp []product
 		for _, record := range records {
-			resp = append(resp, *productFromStorage(&record))
+			if companyIDs[record.CompanyID.String()] {
+				resp = append(resp, *productFromStorage(&record))
+			}
+		}
+		if len(resp) == 0 {
+			logger(r).Warn("No products found")
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "No products found")
+			return
 		}
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
@@ -81,15 +113,15 @@ func findProduct(storage storage.Product) http.HandlerFunc {
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
 			return
 		}
 
-		record, err := storage.Find(id)
+		record, err := storage.Find(r.Context(), id)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 		resp := productFromStorage(record)
@@ -100,28 +132,44 @@ func findProduct(storage storage.Product) http.HandlerFunc {
 	}
 }
 
-func updateProduct(storage storage.Product) http.HandlerFunc {
+func updateProduct(storage storage.Product, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := new(productRequest)
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(false); len(errs) > 0 {
+			invalidRequest(w, r, errs)
 			return
 		}
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+
+		existing, err := storage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !authorized(memberStorage, r, existing.CompanyID.String(), roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
 		prod := productFromTransport(req)
 		prod.ID = id
 		model := productToStorage(prod)
-		if err := storage.Save(model); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err := storage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 
@@ -132,19 +180,31 @@ func updateProduct(storage storage.Product) http.HandlerFunc {
 	}
 }
 
-func deleteProduct(storage storage.Product) http.HandlerFunc {
+func deleteProduct(storage storage.Product, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+
+		existing, err := storage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !authorized(memberStorage, r, existing.CompanyID.String(), roleAdmin) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
-		if err := storage.Delete(id); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := storage.Delete(r.Context(), id); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 
@@ -2,6 +2,7 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,10 +10,24 @@ import (
 	"testing"
 	"time"
 
+	"api.proddx.com/config"
 	"api.proddx.com/storage"
 	uuid "github.com/satori/go.uuid"
 )
 
+func saveProduct(t *testing.T, ps storage.Product, companyID uuid.UUID) string {
+	pm := &storage.ProductModel{
+		ID:          uuid.NewV4(),
+		CompanyID:   companyID,
+		ProductName: "Product One",
+		CreatedAt:   time.Now(),
+	}
+	if err := ps.Save(context.Background(), pm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	return pm.ID.String()
+}
+
 func TestInsertProduct(t *testing.T) {
 	req := productRequest{
 		CompanyID:   uuid.NewV4().String(),
@@ -29,9 +44,32 @@ func TestInsertProduct(t *testing.T) {
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
 
+	memberStore := new(storage.MemberMemoryStore)
+
+	um := &storage.UserModel{
+		ID:            uuid.NewV4(),
+		Email:         "user@domain.com",
+		EmailVerified: true,
+		CreatedAt:     time.Now(),
+	}
+	if err := userStore.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	mm := &storage.MemberModel{
+		CompanyID: uuid.FromStringOrNil(req.CompanyID),
+		UserID:    um.ID,
+		Role:      roleAdmin,
+		CreatedAt: time.Now(),
+	}
+	if err := memberStore.Save(context.Background(), mm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodPost, "/products", bytes.NewBuffer(reqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodPost, "/v1/products", bytes.NewBuffer(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithURLs(config.URLs{Review: "review.proddx.com"}))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusCreated {
@@ -41,13 +79,55 @@ func TestInsertProduct(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := productStore.Find(res.ID)
+	record, err := productStore.Find(context.Background(), res.ID)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 	if record.ID.String() != res.ID {
 		t.Errorf("Record ID inconsistency: %s -%s", record.ID.String(), res.ID)
 	}
+	if res.FeedbackURL != "https://review.proddx.com/"+res.ID {
+		t.Errorf("Error: Unexpected feedback URL %s", res.FeedbackURL)
+	}
+}
+
+func TestInsertProductUnverified(t *testing.T) {
+	req := productRequest{
+		CompanyID: uuid.NewV4().String(),
+		Name:      "Product One",
+	}
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+
+	um := &storage.UserModel{
+		ID:        uuid.NewV4(),
+		Email:     "user@domain.com",
+		CreatedAt: time.Now(),
+	}
+	if err := userStore.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/products", bytes.NewBuffer(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected route POST /products to be forbidden for unverified users: %d", w.Code)
+	}
+	if _, err := productStore.List(context.Background(), ""); err == nil {
+		t.Error("Error: Product was created")
+	}
 }
 
 func TestListProducts(t *testing.T) {
@@ -55,6 +135,7 @@ func TestListProducts(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	pm := &storage.ProductModel{
@@ -65,13 +146,14 @@ func TestListProducts(t *testing.T) {
 		Rating:      4,
 		CreatedAt:   time.Now(),
 	}
-	if err := productStore.Save(pm); err != nil {
+	if err := productStore.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodGet, "/products", nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodGet, "/v1/products", nil)
+	authorize(t, r, saveMember(t, memberStore, pm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -81,7 +163,7 @@ func TestListProducts(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	records, err := productStore.List("")
+	records, err := productStore.List(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -105,14 +187,14 @@ func TestFindProduct(t *testing.T) {
 		Rating:      4,
 		CreatedAt:   time.Now(),
 	}
-	if err := productStore.Save(pm); err != nil {
+	if err := productStore.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/products/%s", id)
+	route := fmt.Sprintf("/v1/products/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodGet, route, nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -122,7 +204,7 @@ func TestFindProduct(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := productStore.Find(id)
+	record, err := productStore.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -136,6 +218,7 @@ func TestUpdateProduct(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	pm := &storage.ProductModel{
@@ -146,7 +229,7 @@ func TestUpdateProduct(t *testing.T) {
 		Rating:      4,
 		CreatedAt:   time.Now(),
 	}
-	if err := productStore.Save(pm); err != nil {
+	if err := productStore.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
@@ -158,10 +241,12 @@ func TestUpdateProduct(t *testing.T) {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/products/%s", id)
+	route := fmt.Sprintf("/v1/products/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodPut, route, bytes.NewBuffer(reqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, saveMember(t, memberStore, pm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -171,7 +256,7 @@ func TestUpdateProduct(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := productStore.Find(id)
+	record, err := productStore.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -185,6 +270,7 @@ func TestDeleteProduct(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	pm := &storage.ProductModel{
@@ -195,20 +281,21 @@ func TestDeleteProduct(t *testing.T) {
 		Rating:      4,
 		CreatedAt:   time.Now(),
 	}
-	if err := productStore.Save(pm); err != nil {
+	if err := productStore.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/products/%s", id)
+	route := fmt.Sprintf("/v1/products/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodDelete, route, nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	authorize(t, r, saveMember(t, memberStore, pm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusNoContent {
 		t.Fatal(fmt.Sprintf("Expected route DELETE %s to be valid", route))
 	}
-	if _, err := productStore.Find(id); err == nil {
+	if _, err := productStore.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", "Record failed to delete")
 	}
 }
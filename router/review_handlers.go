@@ -2,39 +2,54 @@ package router
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
+	"api.proddx.com/metrics"
+	"api.proddx.com/problem"
 	"api.proddx.com/storage"
 	"github.com/julienschmidt/httprouter"
 	uuid "github.com/satori/go.uuid"
 )
 
-func insertReview(storage storage.Review) http.HandlerFunc {
+// insertReview takes reviews from anyone, so the review is only attached to
+// the company of the product it names, which must be the company it names
+// too.
+func insertReview(productStorage storage.Product, storage storage.Review, m *metrics.Metrics) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := new(reviewRequest)
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
 
-		if req.CompanyID == "" || req.ProductID == "" || req.Comment == "" || req.Rating == 0 {
-			fmt.Println("Error: company_id, product_id, comment and rating are required")
-			http.Error(w, "company_id, product_id, comment and rating are required", http.StatusBadRequest)
+		if errs := req.validate(true); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		product, err := productStorage.Find(r.Context(), req.ProductID)
+		if err != nil {
+			logger(r).Warn("Product storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested product was not found")
+			return
+		}
+		if product.CompanyID.String() != req.CompanyID {
+			invalidRequest(w, r, []fieldError{{Field: "company_id", Code: codeInvalid, Message: "company_id must be the company of the product"}})
 			return
 		}
 
 		rev := reviewFromTransport(req)
+		rev.CompanyID = product.CompanyID.String()
 		rev.ID = uuid.NewV4().String()
 		rev.CreatedAt = time.Now()
 		model := reviewToStorage(rev)
-		if err := storage.Save(model); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err := storage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
+		m.ReviewSubmitted(rev.Rating)
 
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusCreated)
@@ -42,38 +57,51 @@ func insertReview(storage storage.Review) http.HandlerFunc {
 	}
 }
 
-func listReviews(storage storage.Review) http.HandlerFunc {
+func listReviews(storage storage.Review, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		companyID := r.URL.Query().Get("company_id")
 		if companyID != "" {
 			if _, err := uuid.FromString(companyID); err != nil {
-				fmt.Println("Marshalling error:", err.Error())
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				logger(r).Warn("Marshalling error", "error", err)
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "Query parameter must be a valid UUID")
 				return
 			}
 		}
 		productID := r.URL.Query().Get("product_id")
 		if productID != "" {
 			if _, err := uuid.FromString(productID); err != nil {
-				fmt.Println("Marshalling error:", err.Error())
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				logger(r).Warn("Marshalling error", "error", err)
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "Query parameter must be a valid UUID")
 				return
 			}
 		}
-		records, err := storage.List(companyID, productID)
+		if companyID != "" && !authorized(memberStorage, r, companyID, roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+		companyIDs, err := callerCompanies(memberStorage, r)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			logger(r).Error("Member storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
-		if len(records) == 0 {
-			fmt.Println("Error:", "No reviews found")
-			http.Error(w, "No reviews found", http.StatusNotFound)
+		records, err := storage.List(r.Context(), companyID, productID)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 		var resp []review
 		for _, record := range records {
-			resp = append(resp, *reviewFromStorage(&record))
+			if companyIDs[record.CompanyID.String()] {
+				resp = append(resp, *reviewFromStorage(&record))
+			}
+		}
+		if len(resp) == 0 {
+			logger(r).Warn("No reviews found")
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "No reviews found")
+			return
 		}
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
@@ -81,20 +109,25 @@ func listReviews(storage storage.Review) http.HandlerFunc {
 	}
 }
 
-func findReview(storage storage.Review) http.HandlerFunc {
+func findReview(storage storage.Review, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
 			return
 		}
 
-		record, err := storage.Find(id)
+		record, err := storage.Find(r.Context(), id)
 		if err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !authorized(memberStorage, r, record.CompanyID.String(), roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 		resp := reviewFromStorage(record)
@@ -105,28 +138,44 @@ func findReview(storage storage.Review) http.HandlerFunc {
 	}
 }
 
-func updateReview(storage storage.Review) http.HandlerFunc {
+func updateReview(storage storage.Review, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := new(reviewRequest)
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			fmt.Println("Marshalling error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(false); len(errs) > 0 {
+			invalidRequest(w, r, errs)
 			return
 		}
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+
+		existing, err := storage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !authorized(memberStorage, r, existing.CompanyID.String(), roleAnalyst) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
 		rev := reviewFromTransport(req)
 		rev.ID = id
 		model := reviewToStorage(rev)
-		if err := storage.Save(model); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		if err := storage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
 			return
 		}
 
@@ -137,19 +186,31 @@ func updateReview(storage storage.Review) http.HandlerFunc {
 	}
 }
 
-func deleteReview(storage storage.Review) http.HandlerFunc {
+func deleteReview(storage storage.Review, memberStorage storage.Member) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := httprouter.ParamsFromContext(r.Context())
 		id := params.ByName("id")
 		if _, err := uuid.FromString(id); err != nil {
-			fmt.Println("ID Error:", err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+
+		existing, err := storage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if !authorized(memberStorage, r, existing.CompanyID.String(), roleAnalyst) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
 			return
 		}
 
-		if err := storage.Delete(id); err != nil {
-			fmt.Println("Storage error:", err.Error())
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := storage.Delete(r.Context(), id); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
 			return
 		}
 
@@ -2,6 +2,7 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,9 +15,15 @@ import (
 )
 
 func TestInsertReview(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+
+	companyID := uuid.NewV4()
 	req := reviewRequest{
-		CompanyID: uuid.NewV4().String(),
-		ProductID: uuid.NewV4().String(),
+		CompanyID: companyID.String(),
+		ProductID: saveProduct(t, productStore, companyID),
 		Comment:   "Lorem ipsum dolor sit amet",
 		Rating:    3,
 	}
@@ -25,14 +32,10 @@ func TestInsertReview(t *testing.T) {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	userStore := new(storage.UserMemoryStore)
-	companyStore := new(storage.CompanyMemoryStore)
-	productStore := new(storage.ProductMemoryStore)
-	reviewStore := new(storage.ReviewMemoryStore)
-
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodPost, "/reviews", bytes.NewBuffer(reqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewBuffer(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusCreated {
@@ -42,13 +45,47 @@ func TestInsertReview(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := reviewStore.Find(res.ID)
+	record, err := reviewStore.Find(context.Background(), res.ID)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 	if record.ID.String() != res.ID {
 		t.Errorf("Record ID inconsistency: %s -%s", record.ID.String(), res.ID)
 	}
+	if record.CompanyID != companyID {
+		t.Errorf("Error: Expected the review to belong to company %s, got %s", companyID, record.CompanyID)
+	}
+}
+
+func TestInsertReviewProduct(t *testing.T) {
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	router := New(new(storage.UserMemoryStore), new(storage.CompanyMemoryStore), productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+
+	companyID := uuid.NewV4()
+	productID := saveProduct(t, productStore, companyID)
+	for _, tc := range []struct {
+		name      string
+		companyID string
+		productID string
+		status    int
+	}{
+		{"another company", uuid.NewV4().String(), productID, http.StatusBadRequest},
+		{"unknown product", companyID.String(), uuid.NewV4().String(), http.StatusNotFound},
+	} {
+		reqJSON, _ := json.Marshal(reviewRequest{CompanyID: tc.companyID, ProductID: tc.productID, Comment: "Great", Rating: 5})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewReader(reqJSON))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("Error: Expected a review for %s to return %d, got %d", tc.name, tc.status, w.Code)
+		}
+	}
+	if reviews, _ := reviewStore.List(context.Background(), companyID.String(), productID); len(reviews) != 0 {
+		t.Errorf("Error: Expected no reviews to be saved, got %d", len(reviews))
+	}
 }
 
 func TestListReviews(t *testing.T) {
@@ -56,6 +93,7 @@ func TestListReviews(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	rm := &storage.ReviewModel{
@@ -66,13 +104,14 @@ func TestListReviews(t *testing.T) {
 		Rating:    3,
 		CreatedAt: time.Now(),
 	}
-	if err := reviewStore.Save(rm); err != nil {
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
 	w := httptest.NewRecorder()
-	r, _ := http.NewRequest(http.MethodGet, "/reviews", nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r, _ := http.NewRequest(http.MethodGet, "/v1/reviews", nil)
+	authorize(t, r, saveMember(t, memberStore, rm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -82,7 +121,7 @@ func TestListReviews(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	records, err := reviewStore.List("", "")
+	records, err := reviewStore.List(context.Background(), "", "")
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -96,6 +135,7 @@ func TestFindReview(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	rm := &storage.ReviewModel{
@@ -106,14 +146,15 @@ func TestFindReview(t *testing.T) {
 		Rating:    3,
 		CreatedAt: time.Now(),
 	}
-	if err := reviewStore.Save(rm); err != nil {
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/reviews/%s", id)
+	route := fmt.Sprintf("/v1/reviews/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodGet, route, nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	authorize(t, r, saveMember(t, memberStore, rm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -123,7 +164,7 @@ func TestFindReview(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := reviewStore.Find(id)
+	record, err := reviewStore.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -137,6 +178,7 @@ func TestUpdateReview(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	rm := &storage.ReviewModel{
@@ -147,7 +189,7 @@ func TestUpdateReview(t *testing.T) {
 		Rating:    3,
 		CreatedAt: time.Now(),
 	}
-	if err := reviewStore.Save(rm); err != nil {
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
@@ -159,10 +201,12 @@ func TestUpdateReview(t *testing.T) {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/reviews/%s", id)
+	route := fmt.Sprintf("/v1/reviews/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodPut, route, bytes.NewBuffer(reqJSON))
-	router := New(userStore, companyStore, productStore, reviewStore)
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, saveMember(t, memberStore, rm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusOK {
@@ -172,7 +216,7 @@ func TestUpdateReview(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := reviewStore.Find(id)
+	record, err := reviewStore.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -186,6 +230,7 @@ func TestDeleteReview(t *testing.T) {
 	companyStore := new(storage.CompanyMemoryStore)
 	productStore := new(storage.ProductMemoryStore)
 	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
 
 	id := uuid.NewV4().String()
 	rm := &storage.ReviewModel{
@@ -196,20 +241,21 @@ func TestDeleteReview(t *testing.T) {
 		Rating:    3,
 		CreatedAt: time.Now(),
 	}
-	if err := reviewStore.Save(rm); err != nil {
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
 
-	route := fmt.Sprintf("/reviews/%s", id)
+	route := fmt.Sprintf("/v1/reviews/%s", id)
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(http.MethodDelete, route, nil)
-	router := New(userStore, companyStore, productStore, reviewStore)
+	authorize(t, r, saveMember(t, memberStore, rm.CompanyID, roleOwner))
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore))
 	router.ServeHTTP(w, r)
 
 	if w.Code != http.StatusNoContent {
 		t.Fatal(fmt.Sprintf("Expected route DELETE %s to be valid", route))
 	}
-	if _, err := reviewStore.Find(id); err == nil {
+	if _, err := reviewStore.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", "Record failed to delete")
 	}
 }
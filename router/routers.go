@@ -12,46 +12,43 @@ import (
 	"fmt"
 	"net/http"
 
+	"api.proddx.com/requestid"
 	"api.proddx.com/storage"
-	"api.proddx.com/tokens"
 	"github.com/julienschmidt/httprouter"
 )
 
-func New(us storage.User, cs storage.Company, ps storage.Product, rs storage.Review) *httprouter.Router {
+func New(us storage.User, cs storage.Company, ps storage.Product, rs storage.Review, opts ...Option) http.Handler {
+	o := newOptions(opts)
 	router := httprouter.New()
+	router.NotFound = notFound()
+	router.MethodNotAllowed = methodNotAllowed()
+	router.PanicHandler = panicHandler
+	preflights := httprouter.New()
+	router.GlobalOPTIONS = preflightHandler(preflights)
 
 	router.Handler(http.MethodGet, "/", Logger(Index(), "Index"))
+	router.Handler(http.MethodGet, "/.well-known/jwks.json", Logger(publicCORS.handler(listKeys(o.issuer)), "ListKeys"))
+	router.Handler(http.MethodGet, "/openapi.yaml", Logger(publicCORS.handler(openAPISpec()), "OpenAPISpec"))
+	router.Handler(http.MethodGet, "/docs", Logger(docs(), "Docs"))
+	router.Handler(http.MethodGet, "/healthz", Logger(healthz(), "Healthz"))
+	router.Handler(http.MethodGet, "/readyz", Logger(readyz(o.checks), "Readyz"))
+	if o.metrics != nil {
+		router.Handler(http.MethodGet, "/metrics", Logger(o.metrics.Handler(), "Metrics"))
+	}
 
-	router.HandlerFunc(http.MethodOptions, "/login", cors)
-	router.Handler(http.MethodPost, "/login", Logger(corsHandler(login(us)), "LoginUser"))
-	router.HandlerFunc(http.MethodOptions, "/register", cors)
-	router.Handler(http.MethodPost, "/register", Logger(corsHandler(register(us, cs)), "RegisterUser"))
-
-	router.HandlerFunc(http.MethodOptions, "/companies", cors)
-	router.HandlerFunc(http.MethodOptions, "/companies/:id", cors)
-	router.Handler(http.MethodGet, "/companies", Logger(corsHandler(tokens.Validation(listCompanies(cs))), "ListCompanies"))
-	router.Handler(http.MethodPost, "/companies", Logger(corsHandler(tokens.Validation(insertCompany(cs))), "InsertCompany"))
-	router.Handler(http.MethodGet, "/companies/:id", Logger(corsHandler(tokens.Validation(findCompany(cs))), "FindCompany"))
-	router.Handler(http.MethodPut, "/companies/:id", Logger(corsHandler(tokens.Validation(updateCompany(cs))), "UpdateCompany"))
-	router.Handler(http.MethodDelete, "/companies/:id", Logger(corsHandler(tokens.Validation(deleteCompany(cs))), "DeleteCompany"))
-
-	router.HandlerFunc(http.MethodOptions, "/products", cors)
-	router.HandlerFunc(http.MethodOptions, "/products/:id", cors)
-	router.Handler(http.MethodGet, "/products", Logger(corsHandler(tokens.Validation(listProducts(ps))), "ListProducts"))
-	router.Handler(http.MethodPost, "/products", Logger(corsHandler(tokens.Validation(insertProduct(ps))), "InsertProduct"))
-	router.Handler(http.MethodGet, "/products/:id", Logger(corsHandler(findProduct(ps)), "FindProduct"))
-	router.Handler(http.MethodPut, "/products/:id", Logger(corsHandler(tokens.Validation(updateProduct(ps))), "UpdateProduct"))
-	router.Handler(http.MethodDelete, "/products/:id", Logger(corsHandler(tokens.Validation(deleteProduct(ps))), "DeleteProduct"))
-
-	router.HandlerFunc(http.MethodOptions, "/reviews", cors)
-	router.HandlerFunc(http.MethodOptions, "/reviews/:id", cors)
-	router.Handler(http.MethodGet, "/reviews", Logger(corsHandler(tokens.Validation(listReviews(rs))), "ListReviews"))
-	router.Handler(http.MethodPost, "/reviews", Logger(corsHandler(insertReview(rs)), "InsertReview"))
-	router.Handler(http.MethodGet, "/reviews/:id", Logger(corsHandler(tokens.Validation(findReview(rs))), "FindReview"))
-	router.Handler(http.MethodPut, "/reviews/:id", Logger(corsHandler(tokens.Validation(updateReview(rs))), "UpdateReview"))
-	router.Handler(http.MethodDelete, "/reviews/:id", Logger(corsHandler(tokens.Validation(deleteReview(rs))), "DeleteReview"))
+	v1 := v1Routes(us, cs, ps, rs, o)
+	mount(router, preflights, "/v1", v1, nil)
+	// Clients from before versioning still call the unprefixed paths.
+	mount(router, preflights, "", v1, deprecated("/v1", o.sunset))
 
-	return router
+	var handler http.Handler = router
+	if o.requests != nil {
+		handler = validateRequests(o.requests, handler)
+	}
+	if o.responses != nil {
+		handler = validateResponses(o.responses, o.report, handler)
+	}
+	return requestid.Middleware(securityHeaders(accessLog(o.logger, o.metrics, traced(o.tracer, handler))))
 }
 
 func Index() http.HandlerFunc {
@@ -0,0 +1,354 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/problem"
+	"api.proddx.com/server"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// streamBatch is how many events a stream reads from the outbox at a
+	// time.
+	streamBatch = 100
+	// streamBuffer is how many events a stream may fall behind before it is
+	// ended, to resume from the outbox when the client reconnects.
+	streamBuffer = streamBatch
+	// streamRetry is how long clients wait before reconnecting.
+	streamRetry = time.Second
+)
+
+// The review handlers shadow the storage package, so the event types are
+// named here.
+const (
+	reviewCreated = storage.EventReviewCreated
+	reviewUpdated = storage.EventReviewUpdated
+	reviewDeleted = storage.EventReviewDeleted
+)
+
+// streamTiming bounds streams. A stream ends before the server's write
+// timeout would cut it off, and the client reconnects and resumes it. The
+// hub of a router reads the outbox every poll.
+type streamTiming struct {
+	poll      time.Duration
+	heartbeat time.Duration
+	lifetime  time.Duration
+}
+
+var defaultStreamTiming = streamTiming{
+	poll:      time.Second,
+	heartbeat: 10 * time.Second,
+	lifetime:  server.WriteTimeout - 5*time.Second,
+}
+
+// streamHub follows the outbox for the review streams of a router. A single
+// poller reads the new events of each company with open streams and fans them
+// out, so that the outbox is read once per company and poll rather than once
+// per stream. It runs while streams are open.
+type streamHub struct {
+	events storage.Event
+	logger *logging.Logger
+	poll   time.Duration
+
+	mu        sync.Mutex
+	companies map[string]*streamCompany
+	running   bool
+}
+
+// streamCompany is the last event of a company the hub has read and the
+// streams it is sent to.
+type streamCompany struct {
+	last    int64
+	streams map[chan storage.EventModel]bool
+}
+
+func newStreamHub(events storage.Event, logger *logging.Logger, poll time.Duration) *streamHub {
+	return &streamHub{
+		events:    events,
+		logger:    logger,
+		poll:      poll,
+		companies: map[string]*streamCompany{},
+	}
+}
+
+// subscribe returns a channel of the review events of a company after last,
+// and starts the poller if it is not running. The hub reads on from where it
+// is if the company already has streams, so a stream also replays the
+// events after last itself and skips those it has sent. The channel is
+// closed when the stream falls behind.
+func (h *streamHub) subscribe(companyID string, last int64) chan storage.EventModel {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	company, ok := h.companies[companyID]
+	if !ok {
+		company = &streamCompany{last: last, streams: map[chan storage.EventModel]bool{}}
+		h.companies[companyID] = company
+	}
+	events := make(chan storage.EventModel, streamBuffer)
+	company.streams[events] = true
+	if !h.running {
+		h.running = true
+		go h.run()
+	}
+	return events
+}
+
+func (h *streamHub) unsubscribe(companyID string, events chan storage.EventModel) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	company, ok := h.companies[companyID]
+	if !ok {
+		return
+	}
+	delete(company.streams, events)
+	if len(company.streams) == 0 {
+		delete(h.companies, companyID)
+	}
+}
+
+// run polls the outbox until no streams are left.
+func (h *streamHub) run() {
+	ticker := time.NewTicker(h.poll)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.fetch()
+		h.mu.Lock()
+		if len(h.companies) == 0 {
+			h.running = false
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+	}
+}
+
+// fetch reads the new events of the companies with streams and sends them.
+func (h *streamHub) fetch() {
+	h.mu.Lock()
+	positions := make(map[string]int64, len(h.companies))
+	for companyID, company := range h.companies {
+		positions[companyID] = company.last
+	}
+	h.mu.Unlock()
+
+	for companyID, last := range positions {
+		for {
+			records, err := h.events.List(context.Background(), companyID, last, streamBatch)
+			if err != nil {
+				h.logger.Error("Storage error", "error", err)
+				break
+			}
+			h.send(companyID, records)
+			if len(records) < streamBatch {
+				break
+			}
+			last = records[len(records)-1].Sequence
+		}
+	}
+}
+
+func (h *streamHub) send(companyID string, records []storage.EventModel) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	company, ok := h.companies[companyID]
+	if !ok {
+		return
+	}
+	for _, record := range records {
+		if record.Sequence <= company.last {
+			continue
+		}
+		company.last = record.Sequence
+		if !streamed(record) {
+			continue
+		}
+		for events := range company.streams {
+			select {
+			case events <- record:
+			default:
+				delete(company.streams, events)
+				close(events)
+			}
+		}
+	}
+}
+
+// streamed reports whether an event is sent to review streams.
+func streamed(event storage.EventModel) bool {
+	switch event.EventType {
+	case reviewCreated, reviewUpdated, reviewDeleted:
+		return true
+	}
+	return false
+}
+
+type streamToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// authenticateStream accepts a stream token in the token query parameter,
+// since EventSource cannot send headers, and the credentials authenticate
+// accepts otherwise.
+func authenticateStream(keyStorage storage.APIKey, issuer *tokens.Issuer, next http.Handler) http.Handler {
+	withToken := issuer.StreamValidation(next)
+	withHeaders := authenticate(keyStorage, issuer, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "" {
+			withToken.ServeHTTP(w, r)
+			return
+		}
+		withHeaders.ServeHTTP(w, r)
+	})
+}
+
+// newStreamToken issues a member a short-lived token to open the review
+// stream of a company with.
+func newStreamToken(memberStorage storage.Member, issuer *tokens.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		userID := tokens.UserID(r.Context())
+		if !hasRole(r.Context(), memberStorage, userID, id, roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+
+		token, exp, err := issuer.NewStream(tokens.Stream{UserID: userID, CompanyID: id})
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(streamToken{Token: token, ExpiresAt: exp})
+	}
+}
+
+// streamReviews sends the review changes of a company as server-sent events
+// until the client disconnects or the stream's lifetime ends. It follows the
+// events in the outbox through the hub, so a stream sees the changes handled
+// by every server instance, and event IDs are the outbox sequence numbers,
+// which a stream resumed on another instance continues from.
+func streamReviews(hub *streamHub, memberStorage storage.Member, timing streamTiming) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id := params.ByName("id")
+		if _, err := uuid.FromString(id); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return
+		}
+		if streamed := tokens.StreamCompanyID(r.Context()); streamed != "" && streamed != id {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+		if !authorized(memberStorage, r, id, roleViewer) {
+			logger(r).Warn(forbidden)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			logger(r).Error("Streaming is not supported by the response writer")
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		// New streams start after the latest event, and streams resume from
+		// the last event received unless that is not an event of the
+		// company.
+		last, err := hub.events.Last(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		if lastID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil && lastID > 0 && lastID < last {
+			last = lastID
+		}
+
+		// replay writes the events after last that were written before the
+		// stream subscribed, and reports whether the stream can go on.
+		replay := func() bool {
+			for {
+				records, err := hub.events.List(r.Context(), id, last, streamBatch)
+				if err != nil {
+					if r.Context().Err() == nil {
+						logger(r).Error("Storage error", "error", err)
+					}
+					return false
+				}
+				for _, record := range records {
+					last = record.Sequence
+					if streamed(record) {
+						writeStreamEvent(w, record)
+					}
+				}
+				if len(records) < streamBatch {
+					return true
+				}
+			}
+		}
+
+		heartbeat := time.NewTicker(timing.heartbeat)
+		defer heartbeat.Stop()
+		lifetime := time.NewTimer(timing.lifetime)
+		defer lifetime.Stop()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		events := hub.subscribe(id, last)
+		defer hub.unsubscribe(id, events)
+		if !replay() {
+			return
+		}
+		flusher.Flush()
+		for {
+			select {
+			case record, ok := <-events:
+				if !ok {
+					logger(r).Warn("Stream fell behind")
+					return
+				}
+				if record.Sequence <= last {
+					continue
+				}
+				last = record.Sequence
+				writeStreamEvent(w, record)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case <-lifetime.C:
+				return
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event storage.EventModel) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.EventType, event.Data)
+}
@@ -0,0 +1,268 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+type sseFrame struct {
+	id    string
+	event string
+	data  string
+}
+
+// readFrame reads the next event of a stream, skipping comments.
+func readFrame(t *testing.T, events *bufio.Reader) sseFrame {
+	var frame sseFrame
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && frame.event != "":
+			return frame
+		case strings.HasPrefix(line, "id: "):
+			frame.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			frame.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			frame.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamReviews(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	eventStore := new(storage.EventMemoryStore)
+	reviewStore := &storage.ReviewMemoryStore{Events: eventStore}
+	memberStore := new(storage.MemberMemoryStore)
+
+	companyID := uuid.NewV4()
+	viewerID := saveMember(t, memberStore, companyID, roleViewer)
+	analystID := saveMember(t, memberStore, companyID, roleAnalyst)
+	outsiderID := saveMember(t, memberStore, uuid.NewV4(), roleAdmin)
+	fastStreams := func(o *options) {
+		o.stream = streamTiming{poll: 10 * time.Millisecond, heartbeat: 50 * time.Millisecond, lifetime: 5 * time.Second}
+	}
+	// Two instances of the API share the stores, and a stream on one sees the
+	// changes handled by the other.
+	newServer := func() *httptest.Server {
+		return httptest.NewServer(New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t),
+			WithMemberStore(memberStore), WithEventStore(eventStore), fastStreams))
+	}
+	srv, other := newServer(), newServer()
+	defer srv.Close()
+	defer other.Close()
+
+	route := fmt.Sprintf("%s/v1/companies/%s/reviews/stream", srv.URL, companyID)
+	stream := func(userID string, lastEventID string) *http.Response {
+		r, _ := http.NewRequest(http.MethodGet, route, nil)
+		authorize(t, r, userID)
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+		res, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		return res
+	}
+
+	res := stream(outsiderID, "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected the stream to be forbidden for other companies: %d", res.StatusCode)
+	}
+
+	res = stream(viewerID, "")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected route GET %s to stream events: %d %s", route, res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	events := bufio.NewReader(res.Body)
+
+	productID := saveProduct(t, productStore, companyID)
+	reqJSON, _ := json.Marshal(reviewRequest{CompanyID: companyID.String(), ProductID: productID, Comment: "Great", Rating: 5})
+	created, err := http.Post(other.URL+"/v1/reviews", "application/json", bytes.NewReader(reqJSON))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	created.Body.Close()
+	if created.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the review to be created: %d", created.StatusCode)
+	}
+
+	frame := readFrame(t, events)
+	var rev review
+	if err := json.Unmarshal([]byte(frame.data), &rev); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if frame.event != reviewCreated || rev.Comment != "Great" || frame.id != "1" {
+		t.Fatalf("Error: Expected a review.created event: %v", frame)
+	}
+
+	heartbeat, err := events.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if heartbeat != ": heartbeat\n" {
+		t.Errorf("Error: Expected a heartbeat on an idle stream: %q", heartbeat)
+	}
+	res.Body.Close()
+
+	// Changes made while disconnected are replayed on resume.
+	reqJSON, _ = json.Marshal(reviewRequest{CompanyID: companyID.String(), ProductID: rev.ProductID, Comment: "Still great", Rating: 4})
+	r, _ := http.NewRequest(http.MethodPut, other.URL+"/v1/reviews/"+rev.ID, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, analystID)
+	updated, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	updated.Body.Close()
+	if updated.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the review to be updated: %d", updated.StatusCode)
+	}
+
+	res = stream(viewerID, frame.id)
+	defer res.Body.Close()
+	frame = readFrame(t, bufio.NewReader(res.Body))
+	if frame.event != reviewUpdated || !strings.Contains(frame.data, "Still great") || frame.id != "2" {
+		t.Errorf("Error: Expected the missed review.updated event to be replayed: %v", frame)
+	}
+}
+
+func TestStreamReviewsWithToken(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	eventStore := new(storage.EventMemoryStore)
+	reviewStore := &storage.ReviewMemoryStore{Events: eventStore}
+	memberStore := new(storage.MemberMemoryStore)
+
+	companyID, otherID := uuid.NewV4(), uuid.NewV4()
+	viewerID := saveMember(t, memberStore, companyID, roleViewer)
+	saveMember(t, memberStore, otherID, roleViewer)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithEventStore(eventStore))
+
+	route := fmt.Sprintf("/v1/companies/%s/reviews/stream/token", otherID)
+	r := httptest.NewRequest(http.MethodPost, route, nil)
+	authorize(t, r, viewerID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected route POST %s to be forbidden for non-members: %d", route, w.Code)
+	}
+
+	route = fmt.Sprintf("/v1/companies/%s/reviews/stream/token", companyID)
+	r = httptest.NewRequest(http.MethodPost, route, nil)
+	authorize(t, r, viewerID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST %s to be valid: %d", route, w.Code)
+	}
+	var res streamToken
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if res.Token == "" || res.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("Error: Unexpected stream token: %+v", res)
+	}
+
+	// The token only opens the stream of its company.
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/companies/%s/reviews/stream?token=%s", otherID, res.Token), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Error: Expected the token to be refused for other companies: %d", w.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/companies/%s/reviews/stream?token=invalid", companyID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Error: Expected an invalid token to be refused: %d", w.Code)
+	}
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	stream, err := http.Get(fmt.Sprintf("%s/v1/companies/%s/reviews/stream?token=%s", srv.URL, companyID, res.Token))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	stream.Body.Close()
+	if stream.StatusCode != http.StatusOK {
+		t.Errorf("Error: Expected the token to open the stream: %d", stream.StatusCode)
+	}
+}
+
+// countedEvents counts how often the outbox is read.
+type countedEvents struct {
+	*storage.EventMemoryStore
+	mu    sync.Mutex
+	lists int
+}
+
+func (e *countedEvents) List(ctx context.Context, companyID string, after int64, limit int) ([]storage.EventModel, error) {
+	e.mu.Lock()
+	e.lists++
+	e.mu.Unlock()
+	return e.EventMemoryStore.List(ctx, companyID, after, limit)
+}
+
+func TestStreamHub(t *testing.T) {
+	eventStore := &countedEvents{EventMemoryStore: new(storage.EventMemoryStore)}
+	reviewStore := &storage.ReviewMemoryStore{Events: eventStore.EventMemoryStore}
+	// The poller is left idle so that the test fetches by itself.
+	hub := newStreamHub(eventStore, logging.Default(), time.Hour)
+
+	companyID := uuid.NewV4()
+	first := hub.subscribe(companyID.String(), 0)
+	second := hub.subscribe(companyID.String(), 0)
+	defer hub.unsubscribe(companyID.String(), first)
+	saveReview := func() {
+		rm := &storage.ReviewModel{ID: uuid.NewV4(), CompanyID: companyID, ProductID: uuid.NewV4(), Comment: "Great", Rating: 5, CreatedAt: time.Now()}
+		if err := reviewStore.Save(context.Background(), rm); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	saveReview()
+	hub.fetch()
+
+	if eventStore.lists != 1 {
+		t.Errorf("Error: Expected the streams of a company to share a read of the outbox: %d", eventStore.lists)
+	}
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("Error: Expected the event to be sent to both streams: %d - %d", len(first), len(second))
+	}
+	if record := <-first; record.Sequence != 1 || record.EventType != reviewCreated {
+		t.Errorf("Error: Expected a review.created event: %v", record)
+	}
+
+	// The second stream falls behind and is ended, and the first goes on.
+	for i := 0; i < streamBuffer; i++ {
+		saveReview()
+	}
+	hub.fetch()
+	for range second {
+	}
+	if len(first) != streamBuffer {
+		t.Errorf("Error: Expected the stream that kept up to receive every event: %d", len(first))
+	}
+}
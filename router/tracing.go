@@ -0,0 +1,42 @@
+package router
+
+import (
+	"net/http"
+
+	"api.proddx.com/logging"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "api.proddx.com/router"
+
+// traced handles every request in a server span, continuing the trace of its
+// traceparent header, and tags its log lines with the trace ID. The span is
+// named after the route once the request has been handled.
+func traced(tp trace.TracerProvider, next http.Handler) http.Handler {
+	tracer := tp.Tracer(instrumentationName)
+	propagator := propagation.TraceContext{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...))
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			logging.AddFields(ctx, "trace_id", sc.TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if route, ok := ctx.Value(routeContextKey).(*string); ok && *route != unmatchedRoute {
+			span.SetName(*route)
+		}
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(rec.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(rec.status, trace.SpanKindServer))
+	})
+}
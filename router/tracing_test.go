@@ -0,0 +1,88 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedReviewStore saves reviews in a span, like the database stores do
+// for their queries.
+type tracedReviewStore struct {
+	*storage.ReviewMemoryStore
+	tracer trace.Tracer
+}
+
+func (s tracedReviewStore) Save(ctx context.Context, model *storage.ReviewModel) error {
+	_, span := s.tracer.Start(ctx, "insert reviews")
+	defer span.End()
+	return s.ReviewMemoryStore.Save(ctx, model)
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := tracedReviewStore{new(storage.ReviewMemoryStore), tp.Tracer("storage")}
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithTracerProvider(tp))
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID := "00f067aa0ba902b7"
+	companyID := uuid.NewV4()
+	productID := saveProduct(t, productStore, companyID)
+	reqJSON, _ := json.Marshal(reviewRequest{CompanyID: companyID.String(), ProductID: productID, Comment: "Great", Rating: 5})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	router.ServeHTTP(w, r)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Error: Expected a request span and a storage span, got %d spans", len(spans))
+	}
+	query, request := spans[0], spans[1]
+	if request.Name() != "InsertReview" || request.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Error: Unexpected request span %q of kind %s", request.Name(), request.SpanKind())
+	}
+	if request.SpanContext().TraceID().String() != traceID || request.Parent().SpanID().String() != parentID || !request.Parent().IsRemote() {
+		t.Errorf("Error: Expected the request span to continue the trace of the traceparent header")
+	}
+	status := attribute.Int(string(semconv.HTTPStatusCodeKey), http.StatusCreated)
+	if !hasAttribute(request.Attributes(), status) || !hasAttribute(request.Attributes(), semconv.HTTPMethodKey.String(http.MethodPost)) {
+		t.Errorf("Error: Unexpected request span attributes: %v", request.Attributes())
+	}
+	if query.Name() != "insert reviews" || query.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("Error: Expected the storage span to be a child of the request span")
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/unknown", nil)
+	router.ServeHTTP(w, r)
+	spans = recorder.Ended()
+	if unmatched := spans[len(spans)-1]; unmatched.Name() != "HTTP GET" || unmatched.Parent().IsValid() {
+		t.Errorf("Error: Expected a root span named after the method for an unmatched request, got %q", unmatched.Name())
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"api.proddx.com/totp"
+)
+
+const (
+	totpIssuer        = "Proddx"
+	recoveryCodeCount = 10
+	// maxChallengeAttempts is how many codes may be tried against one login
+	// challenge before the user has to log in again.
+	maxChallengeAttempts = 5
+)
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b)[:10])
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// useRecoveryCode removes code from the model's remaining recovery codes and
+// reports whether it was one of them.
+func useRecoveryCode(model *storage.UserModel, code string) bool {
+	hash := hashRecoveryCode(code)
+	for index, stored := range model.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			model.RecoveryCodes = append(model.RecoveryCodes[:index:index], model.RecoveryCodes[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func setupTwoFactor(storage storage.User) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := storage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if record.TOTPEnabled {
+			logger(r).Warn("Two-factor authentication is already enabled")
+			problem.Error(w, r, http.StatusConflict, problem.CodeConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		if record.TOTPSecret, err = totp.GenerateSecret(); err != nil {
+			logger(r).Error("TOTP error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		if err = storage.Update(r.Context(), record); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		resp := twoFactorSetup{
+			Secret: record.TOTPSecret,
+			URI:    totp.URI(totpIssuer, record.Email, record.TOTPSecret),
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func confirmTwoFactor(storage storage.User) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(twoFactorRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		record, err := storage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if record.TOTPEnabled {
+			logger(r).Warn("Two-factor authentication is already enabled")
+			problem.Error(w, r, http.StatusConflict, problem.CodeConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		if record.TOTPSecret == "" {
+			logger(r).Warn("Two-factor authentication has not been set up")
+			problem.Error(w, r, http.StatusConflict, problem.CodeConflict, "Two-factor authentication has not been set up")
+			return
+		}
+		step, ok := totp.Match(req.Code, record.TOTPSecret, time.Now())
+		if !ok {
+			logger(r).Warn("Invalid code")
+			problem.Error(w, r, http.StatusUnauthorized, codeInvalidCode, "Invalid code")
+			return
+		}
+		if _, err = storage.UseTOTPStep(r.Context(), record.ID.String(), step); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		codes, hashes, err := generateRecoveryCodes()
+		if err != nil {
+			logger(r).Error("Recovery code error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		record.TOTPEnabled = true
+		record.RecoveryCodes = hashes
+		if err = storage.Update(r.Context(), record); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(recoveryCodes{Codes: codes})
+	}
+}
+
+// loginTwoFactor completes a login with a TOTP or recovery code. Each
+// challenge allows maxChallengeAttempts codes and a single login, and each
+// TOTP code is accepted once.
+func loginTwoFactor(storage storage.User, challenges storage.Challenge, issuer *tokens.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(twoFactorLoginRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+		challenge, err := issuer.ParseChallenge(req.ChallengeToken)
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "Token is invalid or expired")
+			return
+		}
+		attempt, err := challenges.Attempt(r.Context(), challenge.ID, challenge.ExpiresAt)
+		if err != nil {
+			logger(r).Error("Challenge storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+		if attempt.Used {
+			logger(r).Warn("Challenge already used")
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "Token is invalid or expired")
+			return
+		}
+		if attempt.Attempts > maxChallengeAttempts {
+			logger(r).Warn("Too many attempts at the challenge")
+			problem.Error(w, r, http.StatusUnauthorized, codeTooManyAttempts, "Too many attempts, log in again")
+			return
+		}
+		record, err := storage.Find(r.Context(), challenge.UserID)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "Token is invalid or expired")
+			return
+		}
+		if !record.TOTPEnabled {
+			logger(r).Warn("Two-factor authentication is not enabled")
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Two-factor authentication is not enabled")
+			return
+		}
+
+		if req.Code != "" {
+			step, ok := totp.Match(req.Code, record.TOTPSecret, time.Now())
+			if ok {
+				ok, err = storage.UseTOTPStep(r.Context(), record.ID.String(), step)
+				if err != nil {
+					logger(r).Error("Storage error", "error", err)
+					problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+					return
+				}
+			}
+			if !ok {
+				logger(r).Warn("Invalid code")
+				problem.Error(w, r, http.StatusUnauthorized, codeInvalidCode, "Invalid code")
+				return
+			}
+		} else {
+			if !useRecoveryCode(record, req.RecoveryCode) {
+				logger(r).Warn("Invalid recovery code")
+				problem.Error(w, r, http.StatusUnauthorized, codeInvalidCode, "Invalid recovery code")
+				return
+			}
+			if err = storage.Update(r.Context(), record); err != nil {
+				logger(r).Error("Storage error", "error", err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+				return
+			}
+		}
+		if ok, err := challenges.Use(r.Context(), challenge.ID); err != nil || !ok {
+			logger(r).Warn("Challenge already used", "error", err)
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "Token is invalid or expired")
+			return
+		}
+
+		token, err := issuer.New(record.ID.String())
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"token": token})
+	}
+}
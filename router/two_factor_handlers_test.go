@@ -0,0 +1,152 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api.proddx.com/storage"
+	"api.proddx.com/totp"
+)
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	um, _ := saveAccount(t, userStore, companyStore, "password")
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/me/2fa/setup", nil)
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST /me/2fa/setup to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	var setup twoFactorSetup
+	if err := json.Unmarshal(w.Body.Bytes(), &setup); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if setup.Secret == "" || setup.URI == "" {
+		t.Fatalf("Error: Incomplete setup response: %v", setup)
+	}
+
+	code, err := totp.Code(setup.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	reqJSON, _ := json.Marshal(twoFactorRequest{Code: code})
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, "/v1/me/2fa/confirm", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, um.ID.String())
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route POST /me/2fa/confirm to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	var res recoveryCodes
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(res.Codes) != recoveryCodeCount {
+		t.Errorf("Error: %s - %d", "Wrong number of recovery codes", len(res.Codes))
+	}
+	record, err := userStore.Find(context.Background(), um.ID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !record.TOTPEnabled {
+		t.Error("Error: Two-factor authentication was not enabled")
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	um, _ := saveAccount(t, userStore, companyStore, "password")
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	um.TOTPSecret = secret
+	um.TOTPEnabled = true
+	um.RecoveryCodes = hashes
+	if err = userStore.Update(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+
+	login := func() string {
+		reqJSON, _ := json.Marshal(loginRequest{Email: um.Email, Password: "password"})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/login", bytes.NewReader(reqJSON))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected route POST /login to require a second factor: %d - %s", w.Code, w.Body.String())
+		}
+		var challenge map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &challenge); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if challenge["token"] != "" || challenge["challenge_token"] == "" {
+			t.Fatalf("Error: Expected only a challenge token: %v", challenge)
+		}
+		return challenge["challenge_token"]
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	first, second, third := login(), login(), login()
+	type attempt struct {
+		req    twoFactorLoginRequest
+		status int
+	}
+	attempts := []attempt{
+		{twoFactorLoginRequest{ChallengeToken: first, Code: wrong}, http.StatusUnauthorized},
+		{twoFactorLoginRequest{ChallengeToken: first, Code: code}, http.StatusCreated},
+		// Challenges are single-use.
+		{twoFactorLoginRequest{ChallengeToken: first, RecoveryCode: codes[0]}, http.StatusUnauthorized},
+		// Codes are accepted once.
+		{twoFactorLoginRequest{ChallengeToken: second, Code: code}, http.StatusUnauthorized},
+		{twoFactorLoginRequest{ChallengeToken: second, RecoveryCode: codes[0]}, http.StatusCreated},
+		{twoFactorLoginRequest{ChallengeToken: third, RecoveryCode: codes[0]}, http.StatusUnauthorized},
+	}
+	for i := 1; i < maxChallengeAttempts; i++ {
+		attempts = append(attempts, attempt{twoFactorLoginRequest{ChallengeToken: third, Code: wrong}, http.StatusUnauthorized})
+	}
+	// A valid code is refused once the attempts at a challenge are spent.
+	attempts = append(attempts, attempt{twoFactorLoginRequest{ChallengeToken: third, RecoveryCode: codes[1]}, http.StatusUnauthorized})
+	for _, attempt := range attempts {
+		reqJSON, _ := json.Marshal(attempt.req)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/v1/login/2fa", bytes.NewReader(reqJSON))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		if w.Code != attempt.status {
+			t.Errorf("Expected route POST /login/2fa to return %d: %d - %s", attempt.status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -13,11 +13,69 @@ type registrationRequest struct {
 	Password string `json:"password"`
 }
 
+type passwordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type emailChangeRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type twoFactorRequest struct {
+	Code string `json:"code"`
+}
+
+type twoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+type twoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type recoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
 type companyRequest struct {
-	UserID string `json:"user_id,omitempty"`
-	Name   string `json:"name,omitempty"`
-	Email  string `json:"email,omitempty"`
-	Logo   string `json:"logo,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Logo  string `json:"logo,omitempty"`
+}
+
+type memberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type invitationRequest struct {
+	Token string `json:"token"`
+}
+
+type apiKeyRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type notificationPreferencesRequest struct {
+	Mode      string `json:"mode"`
+	Threshold uint   `json:"threshold,omitempty"`
+	Frequency string `json:"frequency,omitempty"`
+}
+
+type notificationChannelRequest struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
 }
 
 type productRequest struct {
@@ -34,21 +92,88 @@ type reviewRequest struct {
 }
 
 type user struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	Password      string    `json:"password,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+}
+
+type account struct {
+	User    user     `json:"user"`
+	Company *company `json:"company,omitempty"`
 }
 
 type company struct {
 	ID        string    `json:"id,omitempty"`
-	UserID    string    `json:"user_id,omitempty"`
 	Name      string    `json:"name,omitempty"`
 	Email     string    `json:"email,omitempty"`
 	Logo      string    `json:"logo,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 }
 
+type member struct {
+	CompanyID string    `json:"company_id"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+type invitation struct {
+	CompanyID string `json:"company_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+}
+
+type apiKey struct {
+	ID         string     `json:"id"`
+	CompanyID  string     `json:"company_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scope      string     `json:"scope"`
+	Key        string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type webhook struct {
+	ID        string    `json:"id"`
+	CompanyID string    `json:"company_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+type webhookDelivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhook_id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at,omitempty"`
+}
+
+type notificationPreferences struct {
+	Mode      string     `json:"mode"`
+	Threshold uint       `json:"threshold"`
+	Frequency string     `json:"frequency"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type notificationChannel struct {
+	ID        string    `json:"id"`
+	CompanyID string    `json:"company_id"`
+	URL       string    `json:"url"`
+	Format    string    `json:"format"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
 type product struct {
 	ID          string    `json:"id,omitempty"`
 	CompanyID   string    `json:"company_id,omitempty"`
@@ -66,3 +191,8 @@ type review struct {
 	Rating    uint      `json:"rating,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 }
+
+type health struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"net/http"
+
+	"api.proddx.com/storage"
+)
+
+// v1Routes returns the routes of version 1 of the API, relative to /v1.
+func v1Routes(us storage.User, cs storage.Company, ps storage.Product, rs storage.Review, o *options) []route {
+	cors := newCORSPolicy(o.cors)
+	body := jsonBodies(o.strictJSON)
+	hub := newStreamHub(o.es, o.logger, o.stream.poll)
+	return []route{
+		{"LoginUser", http.MethodPost, "/login", body(defaultBodyLimit, login(us, o.issuer, o.hasher)), cors},
+		{"LoginTwoFactor", http.MethodPost, "/login/2fa", body(defaultBodyLimit, loginTwoFactor(us, o.chs, o.issuer)), cors},
+		{"RegisterUser", http.MethodPost, "/register", body(defaultBodyLimit, register(us, cs, o.ms, o.issuer, o.mailer, o.hasher, o.policy, o.urls.API)), cors},
+
+		{"StartOIDC", http.MethodGet, "/auth/oidc/:provider/start", startOIDC(o.idps, o.issuer), nil},
+		{"OIDCCallback", http.MethodGet, "/auth/oidc/:provider/callback", oidcCallback(o.idps, us, o.issuer, o.urls.Dashboard), nil},
+
+		{"VerifyEmail", http.MethodGet, "/verify-email", verifyEmail(us, o.issuer), nil},
+		{"ResendVerification", http.MethodPost, "/verify-email/resend", o.issuer.Validation(resendVerification(us, o.issuer, o.mailer, o.urls.API)), cors},
+
+		{"FindAccount", http.MethodGet, "/me", o.issuer.Validation(findAccount(us, cs, o.ms)), cors},
+		{"ChangePassword", http.MethodPut, "/me/password", o.issuer.Validation(body(defaultBodyLimit, changePassword(us, o.hasher, o.policy))), cors},
+		{"ChangeEmail", http.MethodPut, "/me/email", o.issuer.Validation(body(defaultBodyLimit, changeEmail(us, o.issuer, o.mailer, o.hasher, o.urls.API))), cors},
+		{"DeleteAccount", http.MethodDelete, "/me", o.issuer.Validation(deleteAccount(us, o.ms)), cors},
+
+		{"SetupTwoFactor", http.MethodPost, "/me/2fa/setup", o.issuer.Validation(setupTwoFactor(us)), cors},
+		{"ConfirmTwoFactor", http.MethodPost, "/me/2fa/confirm", o.issuer.Validation(body(defaultBodyLimit, confirmTwoFactor(us))), cors},
+
+		{"ListCompanies", http.MethodGet, "/companies", authenticate(o.ks, o.issuer, listCompanies(cs, o.ms)), cors},
+		{"InsertCompany", http.MethodPost, "/companies", o.issuer.Validation(body(defaultBodyLimit, insertCompany(cs, o.ms))), cors},
+		{"FindCompany", http.MethodGet, "/companies/:id", authenticate(o.ks, o.issuer, findCompany(cs, o.ms)), cors},
+		{"UpdateCompany", http.MethodPut, "/companies/:id", authenticate(o.ks, o.issuer, body(defaultBodyLimit, updateCompany(cs, o.ms))), cors},
+		{"DeleteCompany", http.MethodDelete, "/companies/:id", authenticate(o.ks, o.issuer, deleteCompany(cs, o.ms)), cors},
+
+		{"ListMembers", http.MethodGet, "/companies/:id/members", o.issuer.Validation(listMembers(o.ms, us)), cors},
+		{"InviteMember", http.MethodPost, "/companies/:id/members", o.issuer.Validation(body(defaultBodyLimit, inviteMember(o.ms, cs, o.issuer, o.mailer, o.urls.Dashboard))), cors},
+		{"RemoveMember", http.MethodDelete, "/companies/:id/members/:user_id", o.issuer.Validation(removeMember(o.ms)), cors},
+		{"AcceptInvitation", http.MethodPost, "/invitations/accept", o.issuer.Validation(body(defaultBodyLimit, acceptInvitation(o.ms, us, o.issuer))), cors},
+
+		{"ListAPIKeys", http.MethodGet, "/companies/:id/api-keys", o.issuer.Validation(listAPIKeys(o.ks, o.ms)), cors},
+		{"CreateAPIKey", http.MethodPost, "/companies/:id/api-keys", o.issuer.Validation(body(defaultBodyLimit, createAPIKey(o.ks, o.ms))), cors},
+		{"RevokeAPIKey", http.MethodDelete, "/companies/:id/api-keys/:key_id", o.issuer.Validation(revokeAPIKey(o.ks, o.ms)), cors},
+
+		{"ListWebhooks", http.MethodGet, "/companies/:id/webhooks", o.issuer.Validation(listWebhooks(o.whs, o.ms)), cors},
+		{"CreateWebhook", http.MethodPost, "/companies/:id/webhooks", o.issuer.Validation(body(defaultBodyLimit, createWebhook(o.whs, o.ms))), cors},
+		{"DeleteWebhook", http.MethodDelete, "/companies/:id/webhooks/:webhook_id", o.issuer.Validation(deleteWebhook(o.whs, o.ms)), cors},
+		{"ListWebhookDeliveries", http.MethodGet, "/companies/:id/webhooks/:webhook_id/deliveries", o.issuer.Validation(listWebhookDeliveries(o.whs, o.whds, o.ms)), cors},
+		{"RedeliverWebhook", http.MethodPost, "/companies/:id/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", o.issuer.Validation(redeliverWebhook(o.whs, o.whds, o.ms, o.events)), cors},
+
+		{"StreamReviews", http.MethodGet, "/companies/:id/reviews/stream", authenticateStream(o.ks, o.issuer, streamReviews(hub, o.ms, o.stream)), cors},
+		{"NewStreamToken", http.MethodPost, "/companies/:id/reviews/stream/token", o.issuer.Validation(newStreamToken(o.ms, o.issuer)), cors},
+
+		{"FindNotificationPreferences", http.MethodGet, "/companies/:id/notifications", o.issuer.Validation(findNotificationPreferences(o.nps, o.ms)), cors},
+		{"UpdateNotificationPreferences", http.MethodPut, "/companies/:id/notifications", o.issuer.Validation(body(defaultBodyLimit, updateNotificationPreferences(o.nps, o.ms))), cors},
+		{"ListNotificationChannels", http.MethodGet, "/companies/:id/notifications/channels", o.issuer.Validation(listNotificationChannels(o.ncs, o.ms)), cors},
+		{"CreateNotificationChannel", http.MethodPost, "/companies/:id/notifications/channels", o.issuer.Validation(body(defaultBodyLimit, createNotificationChannel(o.ncs, o.ms))), cors},
+		{"DeleteNotificationChannel", http.MethodDelete, "/companies/:id/notifications/channels/:channel_id", o.issuer.Validation(deleteNotificationChannel(o.ncs, o.ms)), cors},
+
+		{"ListProducts", http.MethodGet, "/products", authenticate(o.ks, o.issuer, listProducts(ps, o.ms)), cors},
+		{"InsertProduct", http.MethodPost, "/products", authenticate(o.ks, o.issuer, body(defaultBodyLimit, insertProduct(ps, us, o.ms, o.urls.Review))), cors},
+		{"FindProduct", http.MethodGet, "/products/:id", findProduct(ps), cors},
+		{"UpdateProduct", http.MethodPut, "/products/:id", authenticate(o.ks, o.issuer, body(defaultBodyLimit, updateProduct(ps, o.ms))), cors},
+		{"DeleteProduct", http.MethodDelete, "/products/:id", authenticate(o.ks, o.issuer, deleteProduct(ps, o.ms)), cors},
+
+		{"ListReviews", http.MethodGet, "/reviews", authenticate(o.ks, o.issuer, listReviews(rs, o.ms)), cors},
+		{"InsertReview", http.MethodPost, "/reviews", body(reviewBodyLimit, insertReview(ps, rs, o.metrics)), publicCORS},
+		{"FindReview", http.MethodGet, "/reviews/:id", authenticate(o.ks, o.issuer, findReview(rs, o.ms)), cors},
+		{"UpdateReview", http.MethodPut, "/reviews/:id", authenticate(o.ks, o.issuer, body(defaultBodyLimit, updateReview(rs, o.ms))), cors},
+		{"DeleteReview", http.MethodDelete, "/reviews/:id", authenticate(o.ks, o.issuer, deleteReview(rs, o.ms)), cors},
+	}
+}
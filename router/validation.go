@@ -0,0 +1,289 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"api.proddx.com/notifications"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/webhooks"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Field error codes returned in validation responses.
+const (
+	codeRequired = "required"
+	codeEmail    = "invalid_email"
+	codeUUID     = "invalid_uuid"
+	codeURL      = "invalid_url"
+	codeTooLong  = "too_long"
+	codeRange    = "out_of_range"
+	codeInvalid  = "invalid"
+)
+
+const (
+	maxNameLength    = 100
+	maxEmailLength   = 100
+	maxCommentLength = 2000
+	maxURLLength     = 2048
+	minRating        = 1
+	maxRating        = 5
+)
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validator collects the field errors of a request. Each check only reports
+// the first error of a field, so that a missing value is not also reported
+// as malformed.
+type validator struct {
+	errors []fieldError
+}
+
+func (v *validator) add(field, code, message string) {
+	for _, e := range v.errors {
+		if e.Field == field {
+			return
+		}
+	}
+	v.errors = append(v.errors, fieldError{Field: field, Code: code, Message: message})
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, codeRequired, field+" is required")
+	}
+}
+
+func (v *validator) maxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, codeTooLong, fmt.Sprintf("%s must be at most %d characters long", field, max))
+	}
+}
+
+func (v *validator) email(field, value string) {
+	if value == "" {
+		return
+	}
+	if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+		v.add(field, codeEmail, field+" must be a valid email address")
+	}
+	v.maxLength(field, value, maxEmailLength)
+}
+
+func (v *validator) uuid(field, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := uuid.FromString(value); err != nil {
+		v.add(field, codeUUID, field+" must be a valid UUID")
+	}
+}
+
+func (v *validator) url(field, value string) {
+	if value == "" {
+		return
+	}
+	if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, codeURL, field+" must be a valid http or https URL")
+	}
+	v.maxLength(field, value, maxURLLength)
+}
+
+func (v *validator) rating(field string, value uint) {
+	if value < minRating || value > maxRating {
+		v.add(field, codeRange, fmt.Sprintf("%s must be between %d and %d", field, minRating, maxRating))
+	}
+}
+
+func (req *loginRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("email", req.Email)
+	v.required("password", req.Password)
+	return v.errors
+}
+
+func (req *registrationRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("name", req.Name)
+	v.required("email", req.Email)
+	v.required("password", req.Password)
+	v.maxLength("name", req.Name, maxNameLength)
+	v.email("email", req.Email)
+	return v.errors
+}
+
+func (req *passwordChangeRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("current_password", req.CurrentPassword)
+	v.required("new_password", req.NewPassword)
+	return v.errors
+}
+
+// validate checks the new address with the rules of registration, since it
+// replaces the address the account was registered with.
+func (req *emailChangeRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("email", req.Email)
+	v.required("password", req.Password)
+	v.email("email", req.Email)
+	return v.errors
+}
+
+func (req *twoFactorRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("code", req.Code)
+	return v.errors
+}
+
+// validate requires either a code or a recovery code, which are reported
+// missing as code.
+func (req *twoFactorLoginRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("challenge_token", req.ChallengeToken)
+	if strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "" {
+		v.add("code", codeRequired, "code or recovery_code is required")
+	}
+	return v.errors
+}
+
+func (req *memberRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("email", req.Email)
+	v.required("role", req.Role)
+	v.email("email", req.Email)
+	if req.Role != "" && roleRanks[req.Role] == 0 {
+		v.add("role", codeInvalid, "role must be owner, admin, analyst or viewer")
+	}
+	return v.errors
+}
+
+func (req *apiKeyRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("name", req.Name)
+	v.required("scope", req.Scope)
+	v.maxLength("name", req.Name, maxNameLength)
+	if req.Scope != "" && scopeRoles[req.Scope] == "" {
+		v.add("scope", codeInvalid, "scope must be read-only or read-write")
+	}
+	return v.errors
+}
+
+// validate checks a company for creation, or an update when create is false
+// and every field is optional.
+func (req *companyRequest) validate(create bool) []fieldError {
+	v := new(validator)
+	if create {
+		v.required("name", req.Name)
+		v.required("email", req.Email)
+	}
+	v.maxLength("name", req.Name, maxNameLength)
+	v.email("email", req.Email)
+	v.url("logo", req.Logo)
+	return v.errors
+}
+
+func (req *productRequest) validate(create bool) []fieldError {
+	v := new(validator)
+	if create {
+		v.required("company_id", req.CompanyID)
+		v.required("name", req.Name)
+	}
+	v.uuid("company_id", req.CompanyID)
+	v.maxLength("name", req.Name, maxNameLength)
+	v.url("feedback_url", req.FeedbackURL)
+	return v.errors
+}
+
+func (req *reviewRequest) validate(create bool) []fieldError {
+	v := new(validator)
+	if create {
+		v.required("company_id", req.CompanyID)
+		v.required("product_id", req.ProductID)
+		v.required("comment", req.Comment)
+	}
+	v.uuid("company_id", req.CompanyID)
+	v.uuid("product_id", req.ProductID)
+	v.maxLength("comment", req.Comment, maxCommentLength)
+	if create && req.Rating == 0 {
+		v.add("rating", codeRequired, "rating is required")
+	}
+	if req.Rating != 0 {
+		v.rating("rating", req.Rating)
+	}
+	return v.errors
+}
+
+// validate requires https, since deliveries carry company data and are
+// signed with a secret only the endpoint should learn from them.
+func (req *webhookRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("url", req.URL)
+	v.url("url", req.URL)
+	if u, err := url.Parse(req.URL); err == nil && u.Scheme == "http" {
+		v.add("url", codeURL, "url must be an https URL")
+	}
+	if len(req.Events) == 0 {
+		v.add("events", codeRequired, "events is required")
+	}
+	for _, event := range req.Events {
+		if !webhooks.Known(event) {
+			v.add("events", codeInvalid, fmt.Sprintf("events must be some of %s", strings.Join(webhooks.Events, ", ")))
+		}
+	}
+	return v.errors
+}
+
+// validate requires a threshold that some rating is below, so that
+// below_threshold never silences every review.
+func (req *notificationPreferencesRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("mode", req.Mode)
+	switch req.Mode {
+	case "", storage.NotifyEvery, storage.NotifyBelowThreshold, storage.NotifyDigest, storage.NotifyOff:
+	default:
+		v.add("mode", codeInvalid, "mode must be every, below_threshold, digest or off")
+	}
+	if req.Threshold != 0 && (req.Threshold <= minRating || req.Threshold > maxRating) {
+		v.add("threshold", codeRange, fmt.Sprintf("threshold must be between %d and %d", minRating+1, maxRating))
+	}
+	switch req.Frequency {
+	case "", storage.DigestDaily, storage.DigestWeekly:
+	default:
+		v.add("frequency", codeInvalid, "frequency must be daily or weekly")
+	}
+	return v.errors
+}
+
+// validate requires https, since incoming-webhook URLs are credentials of
+// the channel.
+func (req *notificationChannelRequest) validate() []fieldError {
+	v := new(validator)
+	v.required("url", req.URL)
+	v.url("url", req.URL)
+	if u, err := url.Parse(req.URL); err == nil && u.Scheme == "http" {
+		v.add("url", codeURL, "url must be an https URL")
+	}
+	v.required("format", req.Format)
+	if req.Format != "" && !notifications.KnownFormat(req.Format) {
+		v.add("format", codeInvalid, fmt.Sprintf("format must be one of %s", strings.Join(notifications.Formats, ", ")))
+	}
+	return v.errors
+}
+
+// invalidRequest writes the field errors of a request that failed
+// validation.
+func invalidRequest(w http.ResponseWriter, r *http.Request, errs []fieldError) {
+	logger(r).Warn("Validation error", "errors", errs)
+	p := problem.New(r, http.StatusBadRequest, problem.CodeValidation, "Request validation failed")
+	p.Errors = errs
+	p.Write(w)
+}
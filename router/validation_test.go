@@ -0,0 +1,147 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+)
+
+func fieldCodes(errs []fieldError) map[string]string {
+	codes := map[string]string{}
+	for _, e := range errs {
+		codes[e.Field] = e.Code
+	}
+	return codes
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		errs     []fieldError
+		expected map[string]string
+	}{
+		{
+			"empty login",
+			(&loginRequest{}).validate(),
+			map[string]string{"email": codeRequired, "password": codeRequired},
+		},
+		{
+			"registration",
+			(&registrationRequest{Name: strings.Repeat("n", maxNameLength+1), Email: "not an email", Password: "password"}).validate(),
+			map[string]string{"name": codeTooLong, "email": codeEmail},
+		},
+		{
+			"email change",
+			(&emailChangeRequest{Email: "new@domain.com" + strings.Repeat("m", maxEmailLength)}).validate(),
+			map[string]string{"email": codeTooLong, "password": codeRequired},
+		},
+		{
+			"password change",
+			(&passwordChangeRequest{CurrentPassword: "password"}).validate(),
+			map[string]string{"new_password": codeRequired},
+		},
+		{
+			"two-factor confirmation",
+			(&twoFactorRequest{}).validate(),
+			map[string]string{"code": codeRequired},
+		},
+		{
+			"two-factor login",
+			(&twoFactorLoginRequest{RecoveryCode: "abcd-efgh"}).validate(),
+			map[string]string{"challenge_token": codeRequired},
+		},
+		{
+			"two-factor login without a code",
+			(&twoFactorLoginRequest{ChallengeToken: "token"}).validate(),
+			map[string]string{"code": codeRequired},
+		},
+		{
+			"member invitation",
+			(&memberRequest{Email: "not an email", Role: "guest"}).validate(),
+			map[string]string{"email": codeEmail, "role": codeInvalid},
+		},
+		{
+			"API key",
+			(&apiKeyRequest{Name: strings.Repeat("n", maxNameLength+1), Scope: "admin"}).validate(),
+			map[string]string{"name": codeTooLong, "scope": codeInvalid},
+		},
+		{
+			"company creation",
+			(&companyRequest{Email: "company@domain.com", Logo: "ftp://domain.com/logo.png"}).validate(true),
+			map[string]string{"name": codeRequired, "logo": codeURL},
+		},
+		{
+			"company update",
+			(&companyRequest{Logo: "https://proddx.com/logo.png"}).validate(false),
+			map[string]string{},
+		},
+		{
+			"product creation",
+			(&productRequest{CompanyID: "1234", Name: "Product One", FeedbackURL: "proddx.com"}).validate(true),
+			map[string]string{"company_id": codeUUID, "feedback_url": codeURL},
+		},
+		{
+			"review creation",
+			(&reviewRequest{Comment: strings.Repeat("c", maxCommentLength+1)}).validate(true),
+			map[string]string{"company_id": codeRequired, "product_id": codeRequired, "comment": codeTooLong, "rating": codeRequired},
+		},
+		{
+			"review update",
+			(&reviewRequest{Rating: maxRating + 1}).validate(false),
+			map[string]string{"rating": codeRange},
+		},
+	}
+	for _, c := range cases {
+		codes := fieldCodes(c.errs)
+		if len(codes) != len(c.expected) {
+			t.Errorf("Error: %s: Expected %v: %v", c.name, c.expected, c.errs)
+			continue
+		}
+		for field, code := range c.expected {
+			if codes[field] != code {
+				t.Errorf("Error: %s: Expected %s to be %s: %v", c.name, field, code, c.errs)
+			}
+		}
+	}
+}
+
+func TestInsertReviewInvalid(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+
+	reqJSON, _ := json.Marshal(reviewRequest{CompanyID: "1234", ProductID: "5678", Comment: "Great", Rating: 6})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected route POST /reviews to reject the review: %d - %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != problem.ContentType {
+		t.Errorf("Error: Unexpected content type %s", w.Header().Get("Content-Type"))
+	}
+	var res struct {
+		Code   string       `json:"code"`
+		Errors []fieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if res.Code != problem.CodeValidation {
+		t.Errorf("Error: Unexpected problem code %s", res.Code)
+	}
+	codes := fieldCodes(res.Errors)
+	if codes["company_id"] != codeUUID || codes["product_id"] != codeUUID || codes["rating"] != codeRange || len(codes) != 3 {
+		t.Errorf("Error: Unexpected field errors: %v", res.Errors)
+	}
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"api.proddx.com/mail"
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+)
+
+func sendVerification(issuer *tokens.Issuer, mailer mail.Mailer, apiURL string, model *storage.UserModel) error {
+	token, err := issuer.NewVerification(model.ID.String(), model.Email)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("https://%s/v1/verify-email?token=%s", apiURL, url.QueryEscape(token))
+	return mailer.Send(mail.Message{
+		To:      model.Email,
+		Subject: "Verify your Proddx email address",
+		Text:    fmt.Sprintf("Confirm your email address by opening the link below:\n\n%s\n\nThe link expires in 24 hours.", link),
+	})
+}
+
+func verifyEmail(storage storage.User, issuer *tokens.Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, email, err := issuer.ParseVerification(r.URL.Query().Get("token"))
+		if err != nil {
+			logger(r).Error("Token error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidToken, "Token is invalid or expired")
+			return
+		}
+		record, err := storage.Find(r.Context(), id)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if record.Email != email {
+			logger(r).Warn("Verification token does not match the current email")
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidToken, "Verification token does not match the current email")
+			return
+		}
+		if !record.EmailVerified {
+			record.EmailVerified = true
+			if err := storage.Update(r.Context(), record); err != nil {
+				logger(r).Error("Storage error", "error", err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+				return
+			}
+		}
+
+		resp := userFromStorage(record)
+		resp.Password = ""
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(*resp)
+	}
+}
+
+func resendVerification(storage storage.User, issuer *tokens.Issuer, mailer mail.Mailer, apiURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := storage.Find(r.Context(), tokens.UserID(r.Context()))
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		if record.EmailVerified {
+			logger(r).Warn("Email is already verified")
+			problem.Error(w, r, http.StatusConflict, problem.CodeConflict, "Email is already verified")
+			return
+		}
+		if err := sendVerification(issuer, mailer, apiURL, record); err != nil {
+			logger(r).Error("Mail error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
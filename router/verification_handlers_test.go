@@ -0,0 +1,135 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"api.proddx.com/mail"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+type mailRecorder struct {
+	messages []mail.Message
+}
+
+func (mr *mailRecorder) Send(msg mail.Message) error {
+	mr.messages = append(mr.messages, msg)
+	return nil
+}
+
+func verificationToken(t *testing.T, msg mail.Message) string {
+	for _, field := range strings.Fields(msg.Text) {
+		if link, err := url.Parse(field); err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("Error: No verification link in message: %s", msg.Text)
+	return ""
+}
+
+func TestVerifyEmail(t *testing.T) {
+	req := registrationRequest{
+		Name:     "Company One",
+		Email:    "company@domain.com",
+		Password: "password",
+	}
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	mailer := new(mailRecorder)
+	router := New(userStore, companyStore, productStore, reviewStore, WithMailer(mailer), WithIssuer(testIssuer), conforms(t))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/register", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST /register to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	record, err := userStore.Find(context.Background(), req.Email)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.EmailVerified {
+		t.Fatal("Error: New user should not be verified")
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To != req.Email {
+		t.Fatalf("Error: Expected one verification email to %s: %v", req.Email, mailer.messages)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/v1/verify-email?token="+url.QueryEscape(verificationToken(t, mailer.messages[0])), nil)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET /verify-email to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	record, err = userStore.Find(context.Background(), req.Email)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !record.EmailVerified {
+		t.Error("Error: User was not verified")
+	}
+}
+
+func TestVerifyEmailInvalidToken(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/v1/verify-email?token=invalid", nil)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected route GET /verify-email to reject invalid tokens: %d", w.Code)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	mailer := new(mailRecorder)
+
+	um := &storage.UserModel{
+		ID:        uuid.NewV4(),
+		Email:     "user@domain.com",
+		CreatedAt: time.Now(),
+	}
+	if err := userStore.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/verify-email/resend", nil)
+	authorize(t, r, um.ID.String())
+	router := New(userStore, companyStore, productStore, reviewStore, WithMailer(mailer), WithIssuer(testIssuer), conforms(t))
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected route POST /verify-email/resend to be valid: %d - %s", w.Code, w.Body.String())
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To != um.Email {
+		t.Errorf("Error: Expected one verification email to %s: %v", um.Email, mailer.messages)
+	}
+}
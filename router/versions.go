@@ -0,0 +1,62 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Each version of the API is a table of routes mounted under its own prefix,
+// such as /v1. A new version gets its own table, built from the handlers of
+// the previous one where they did not change, and is mounted next to it, so
+// clients can move over one version at a time.
+
+// defaultLegacySunset is when the unprefixed paths stop working unless
+// another date is configured.
+var defaultLegacySunset = time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// route is a handler of the API, the name it is logged with and the CORS
+// policy of browsers calling it from other sites. Routes without a policy,
+// such as the targets of redirects, are not meant to be called by scripts.
+type route struct {
+	Name    string
+	Method  string
+	Pattern string
+	Handler http.Handler
+	CORS    *corsPolicy
+}
+
+// mount registers routes under prefix, and their CORS policies with
+// preflights. wrap, if not nil, wraps every handler.
+func mount(router, preflights *httprouter.Router, prefix string, routes []route, wrap func(http.Handler) http.Handler) {
+	for _, rt := range routes {
+		handler := rt.Handler
+		if wrap != nil {
+			handler = wrap(handler)
+		}
+		if rt.CORS != nil {
+			handler = rt.CORS.handler(handler)
+			policy := rt.CORS
+			preflights.Handle(rt.Method, prefix+rt.Pattern, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				policy.preflight(w, r)
+			})
+		}
+		router.Handler(rt.Method, prefix+rt.Pattern, Logger(handler, rt.Name))
+	}
+}
+
+// deprecated marks the responses of unprefixed legacy paths with the
+// Deprecation and Sunset headers, and links to the same path under the
+// prefix of the version that replaces them.
+func deprecated(successor string, sunset time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, r.URL.Path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
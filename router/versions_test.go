@@ -0,0 +1,60 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestLegacyPaths(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+
+	rm := &storage.ReviewModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		ProductID: uuid.NewV4(),
+		Comment:   "Lorem ipsum dolor sit amet",
+		Rating:    3,
+		CreatedAt: time.Now(),
+	}
+	if err := reviewStore.Save(context.Background(), rm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	userID := saveMember(t, memberStore, rm.CompanyID, roleViewer)
+
+	sunset := time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithLegacySunset(sunset))
+
+	for _, path := range []string{"/v1/reviews/" + rm.ID.String(), "/reviews/" + rm.ID.String()} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		authorize(t, r, userID)
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected route GET %s to be valid: %d - %s", path, w.Code, w.Body.String())
+		}
+		legacy := path[:4] != "/v1/"
+		if (w.Header().Get("Deprecation") != "") != legacy {
+			t.Errorf("Error: Unexpected Deprecation header for %s: %q", path, w.Header().Get("Deprecation"))
+		}
+		if !legacy {
+			continue
+		}
+		if w.Header().Get("Sunset") != "Fri, 01 Mar 2030 00:00:00 GMT" {
+			t.Errorf("Error: Unexpected Sunset header: %s", w.Header().Get("Sunset"))
+		}
+		if w.Header().Get("Link") != `</v1`+path+`>; rel="successor-version"` {
+			t.Errorf("Error: Unexpected Link header: %s", w.Header().Get("Link"))
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"api.proddx.com/problem"
+	"api.proddx.com/storage"
+	"api.proddx.com/tokens"
+	"api.proddx.com/webhooks"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+)
+
+// webhookParams returns the company and webhook IDs of the request, or
+// writes an error and returns false when they are malformed or the caller
+// is not an admin of the company.
+func webhookParams(w http.ResponseWriter, r *http.Request, memberStorage storage.Member, names ...string) ([]string, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	var values []string
+	for _, name := range append([]string{"id"}, names...) {
+		value := params.ByName(name)
+		if _, err := uuid.FromString(value); err != nil {
+			logger(r).Warn("ID Error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidID, "ID must be a valid UUID")
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	if !hasRole(r.Context(), memberStorage, tokens.UserID(r.Context()), values[0], roleAdmin) {
+		logger(r).Warn(forbidden)
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, forbidden)
+		return nil, false
+	}
+	return values, true
+}
+
+func listWebhooks(webhookStorage storage.Webhook, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := webhookParams(w, r, memberStorage)
+		if !ok {
+			return
+		}
+
+		records, err := webhookStorage.List(r.Context(), ids[0])
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		resp := []webhook{}
+		for _, record := range records {
+			resp = append(resp, *webhookFromStorage(&record))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// createWebhook registers an endpoint and returns its signing secret, which
+// is not shown again.
+func createWebhook(webhookStorage storage.Webhook, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(webhookRequest)
+		if err := decodeJSON(r, req); err != nil {
+			logger(r).Warn("Marshalling error", "error", err)
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+		ids, ok := webhookParams(w, r, memberStorage)
+		if !ok {
+			return
+		}
+		if errs := req.validate(); len(errs) > 0 {
+			invalidRequest(w, r, errs)
+			return
+		}
+
+		secret, err := webhooks.GenerateSecret()
+		if err != nil {
+			logger(r).Warn("Webhook secret error", "error", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+			return
+		}
+		model := &storage.WebhookModel{
+			ID:        uuid.NewV4(),
+			CompanyID: uuid.FromStringOrNil(ids[0]),
+			URL:       req.URL,
+			Secret:    secret,
+			Events:    req.Events,
+			CreatedAt: time.Now(),
+		}
+		if err = webhookStorage.Save(r.Context(), model); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		resp := webhookFromStorage(model)
+		resp.Secret = secret
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(*resp)
+	}
+}
+
+func deleteWebhook(webhookStorage storage.Webhook, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := webhookParams(w, r, memberStorage, "webhook_id")
+		if !ok {
+			return
+		}
+
+		if err := webhookStorage.Delete(r.Context(), ids[0], ids[1]); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listWebhookDeliveries returns the latest deliveries to an endpoint, most
+// recent first.
+func listWebhookDeliveries(webhookStorage storage.Webhook, deliveryStorage storage.WebhookDelivery, memberStorage storage.Member) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := webhookParams(w, r, memberStorage, "webhook_id")
+		if !ok {
+			return
+		}
+
+		if _, err := webhookStorage.Find(r.Context(), ids[0], ids[1]); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		records, err := deliveryStorage.List(r.Context(), ids[1])
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		resp := []webhookDelivery{}
+		for _, record := range records {
+			resp = append(resp, *webhookDeliveryFromStorage(&record))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// redeliverWebhook queues the event of an earlier delivery again, such as
+// after an endpoint has been fixed.
+func redeliverWebhook(webhookStorage storage.Webhook, deliveryStorage storage.WebhookDelivery, memberStorage storage.Member, events *webhooks.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, ok := webhookParams(w, r, memberStorage, "webhook_id", "delivery_id")
+		if !ok {
+			return
+		}
+
+		if _, err := webhookStorage.Find(r.Context(), ids[0], ids[1]); err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		delivery, err := deliveryStorage.Find(r.Context(), ids[1], ids[2])
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "The requested resource was not found")
+			return
+		}
+		model, err := events.Redeliver(r.Context(), delivery)
+		if err != nil {
+			logger(r).Error("Storage error", "error", err)
+			problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeUnprocessable, "The request could not be saved")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(*webhookDeliveryFromStorage(model))
+	}
+}
@@ -0,0 +1,175 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/outbox"
+	"api.proddx.com/storage"
+	"api.proddx.com/webhooks"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	reviewStore := new(storage.ReviewMemoryStore)
+	memberStore := new(storage.MemberMemoryStore)
+	webhookStore := new(storage.WebhookMemoryStore)
+	deliveryStore := new(storage.WebhookDeliveryMemoryStore)
+
+	companyID := uuid.NewV4()
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	analystID := saveMember(t, memberStore, companyID, roleAnalyst)
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithWebhookStores(webhookStore, deliveryStore))
+
+	route := fmt.Sprintf("/v1/companies/%s/webhooks", companyID)
+	reqJSON, _ := json.Marshal(webhookRequest{URL: "https://crm.domain.com/hooks/proddx", Events: []string{webhooks.ReviewCreated}})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, analystID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected route POST %s to be forbidden for analysts: %d", route, w.Code)
+	}
+
+	for _, invalid := range []webhookRequest{
+		{URL: "http://crm.domain.com/hooks/proddx", Events: []string{webhooks.ReviewCreated}},
+		{URL: "https://crm.domain.com/hooks/proddx", Events: []string{"review.archived"}},
+		{URL: "https://crm.domain.com/hooks/proddx"},
+	} {
+		invalidJSON, _ := json.Marshal(invalid)
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodPost, route, bytes.NewReader(invalidJSON))
+		r.Header.Set("Content-Type", "application/json")
+		authorize(t, r, adminID)
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected route POST %s to reject %v: %d", route, invalid, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, route, bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	var created webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !strings.HasPrefix(created.Secret, "whsec_") {
+		t.Errorf("Error: Expected the signing secret to be returned: %v", created)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET %s to be valid: %d", route, w.Code)
+	}
+	if strings.Contains(w.Body.String(), created.Secret) {
+		t.Errorf("Error: Expected the signing secret not to be listed: %s", w.Body.String())
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	userStore := new(storage.UserMemoryStore)
+	companyStore := new(storage.CompanyMemoryStore)
+	productStore := new(storage.ProductMemoryStore)
+	eventStore := new(storage.EventMemoryStore)
+	reviewStore := &storage.ReviewMemoryStore{Events: eventStore}
+	memberStore := new(storage.MemberMemoryStore)
+	webhookStore := new(storage.WebhookMemoryStore)
+	deliveryStore := new(storage.WebhookDeliveryMemoryStore)
+
+	companyID := uuid.NewV4()
+	adminID := saveMember(t, memberStore, companyID, roleAdmin)
+	hook := &storage.WebhookModel{
+		ID:        uuid.NewV4(),
+		CompanyID: companyID,
+		URL:       "https://crm.domain.com/hooks/proddx",
+		Secret:    "whsec_secret",
+		Events:    []string{webhooks.ReviewCreated, webhooks.ReviewDeleted},
+		CreatedAt: time.Now(),
+	}
+	if err := webhookStore.Save(context.Background(), hook); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	router := New(userStore, companyStore, productStore, reviewStore, WithIssuer(testIssuer), conforms(t), WithMemberStore(memberStore), WithWebhookStores(webhookStore, deliveryStore))
+
+	productID := saveProduct(t, productStore, companyID)
+	reqJSON, _ := json.Marshal(reviewRequest{CompanyID: companyID.String(), ProductID: productID, Comment: "Lorem ipsum dolor sit amet", Rating: 4})
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/v1/reviews", bytes.NewReader(reqJSON))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected route POST /v1/reviews to be valid: %d", w.Code)
+	}
+	dispatcher := outbox.NewDispatcher(eventStore, logging.Default(), map[string]outbox.Sink{
+		"webhook": webhooks.NewPublisher(webhookStore, deliveryStore),
+	})
+	if _, err := dispatcher.Dispatch(context.Background()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	route := fmt.Sprintf("/v1/companies/%s/webhooks/%s/deliveries", companyID, hook.ID)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, route, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected route GET %s to be valid: %d", route, w.Code)
+	}
+	var deliveries []webhookDelivery
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveries); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != webhooks.ReviewCreated || deliveries[0].Status != storage.DeliveryPending {
+		t.Fatalf("Error: Expected a pending delivery of the new review: %v", deliveries)
+	}
+
+	route = fmt.Sprintf("%s/%s/redeliver", route, deliveries[0].ID)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, route, nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected route POST %s to be valid: %d - %s", route, w.Code, w.Body.String())
+	}
+	records, _ := deliveryStore.List(context.Background(), hook.ID.String())
+	if len(records) != 2 || string(records[0].Payload) != string(records[1].Payload) {
+		t.Errorf("Error: Expected the event to be queued again: %v", records)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/v1/companies/%s/webhooks/%s/deliveries/%s/redeliver", uuid.NewV4(), hook.ID, deliveries[0].ID), nil)
+	authorize(t, r, adminID)
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected redelivery to another company's webhook to be forbidden: %d", w.Code)
+	}
+}
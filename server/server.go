@@ -0,0 +1,99 @@
+// Package server runs the HTTP server of the API.
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Timeouts of the server returned by New. WriteTimeout bounds the time to
+// handle a request and write its response.
+const (
+	ReadHeaderTimeout = 5 * time.Second
+	ReadTimeout       = 15 * time.Second
+	WriteTimeout      = 30 * time.Second
+	IdleTimeout       = 2 * time.Minute
+)
+
+// DefaultGracePeriod is how long in-flight requests get to complete on
+// shutdown when no grace period is configured.
+const DefaultGracePeriod = 30 * time.Second
+
+// New returns a server for handler on addr with read, write and idle
+// timeouts, so that slow or idle clients cannot hold connections open.
+func New(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		ReadTimeout:       ReadTimeout,
+		WriteTimeout:      WriteTimeout,
+		IdleTimeout:       IdleTimeout,
+	}
+}
+
+// ListenAndServe listens on the address of srv and serves it like Serve.
+func ListenAndServe(ctx context.Context, srv *http.Server, grace time.Duration) error {
+	l, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, srv, l, grace)
+}
+
+// ListenAndServeAll serves every server like ListenAndServe, and stops them
+// all once ctx is done or one of them fails. It returns the first error.
+func ListenAndServeAll(ctx context.Context, grace time.Duration, servers ...*http.Server) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			err := ListenAndServe(ctx, srv, grace)
+			cancel()
+			errs <- err
+		}(srv)
+	}
+	var first error
+	for range servers {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Serve serves connections from l until ctx is done, over TLS when srv has
+// a TLSConfig. It then stops accepting connections and waits up to grace for
+// in-flight requests to complete before closing the remaining connections.
+// It returns nil once all requests have completed, or the error that
+// stopped the server.
+func Serve(ctx context.Context, srv *http.Server, l net.Listener, grace time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errs <- srv.ServeTLS(l, "", "")
+			return
+		}
+		errs <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return err
+	}
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsRequests(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	started := make(chan struct{})
+	srv := New(l.Addr().String(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	}))
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, srv, l, time.Second)
+	}()
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			t.Errorf("Error: %s", err.Error())
+		}
+		responses <- resp
+	}()
+	<-started
+	stop()
+
+	if err := <-served; err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	resp := <-responses
+	if resp == nil {
+		t.Fatalf("Error: Expected the in-flight request to complete")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "done" {
+		t.Errorf("Error: Unexpected response %d %q", resp.StatusCode, body)
+	}
+	if _, err := http.Get("http://" + l.Addr().String()); err == nil {
+		t.Errorf("Error: Expected new connections to be refused after shutdown")
+	}
+}
+
+func TestServeGracePeriod(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := New(l.Addr().String(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, srv, l, 50*time.Millisecond)
+	}()
+
+	go http.Get("http://" + l.Addr().String())
+	<-started
+	stop()
+	if err := <-served; err != context.DeadlineExceeded {
+		t.Errorf("Error: Expected the grace period to expire, got %v", err)
+	}
+}
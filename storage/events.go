@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Types of the events written with changes to reviews and products.
+const (
+	EventReviewCreated  = "review.created"
+	EventReviewUpdated  = "review.updated"
+	EventReviewDeleted  = "review.deleted"
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+)
+
+// reviewData and productData are the data of events, in the form the API
+// returns reviews and products.
+type reviewData struct {
+	ID        string    `json:"id"`
+	CompanyID string    `json:"company_id"`
+	ProductID string    `json:"product_id"`
+	Comment   string    `json:"comment,omitempty"`
+	Rating    uint      `json:"rating,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type productData struct {
+	ID          string    `json:"id"`
+	CompanyID   string    `json:"company_id"`
+	Name        string    `json:"name,omitempty"`
+	FeedbackURL string    `json:"feedback_url,omitempty"`
+	Rating      uint      `json:"rating,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func reviewEventData(model *ReviewModel) reviewData {
+	return reviewData{
+		ID:        model.ID.String(),
+		CompanyID: model.CompanyID.String(),
+		ProductID: model.ProductID.String(),
+		Comment:   model.Comment,
+		Rating:    model.Rating,
+		CreatedAt: model.CreatedAt,
+	}
+}
+
+func productEventData(model *ProductModel) productData {
+	return productData{
+		ID:          model.ID.String(),
+		CompanyID:   model.CompanyID.String(),
+		Name:        model.ProductName,
+		FeedbackURL: model.FeedbackURL,
+		Rating:      model.Rating,
+		CreatedAt:   model.CreatedAt,
+	}
+}
+
+func newEvent(eventType string, companyID uuid.UUID, data interface{}) (*EventModel, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &EventModel{
+		ID:        uuid.NewV4(),
+		CompanyID: companyID,
+		EventType: eventType,
+		Data:      b,
+		CreatedAt: time.Now(),
+	}, nil
+}
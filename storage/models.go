@@ -7,21 +7,44 @@ import (
 )
 
 type UserModel struct {
-	ID           uuid.UUID
-	Email        string
-	UserPassword string
-	CreatedAt    time.Time
-}
-
-type CompanyModel struct {
 	ID            uuid.UUID
-	CompanyUserID string
-	CompanyName   string
 	Email         string
-	Logo          string
+	UserPassword  string
+	EmailVerified bool
+	TOTPSecret    string
+	TOTPEnabled   bool
+	// TOTPLastStep is the time step of the last accepted TOTP code.
+	TOTPLastStep  int64
+	RecoveryCodes []string
 	CreatedAt     time.Time
 }
 
+type CompanyModel struct {
+	ID          uuid.UUID
+	CompanyName string
+	Email       string
+	Logo        string
+	CreatedAt   time.Time
+}
+
+type MemberModel struct {
+	CompanyID uuid.UUID
+	UserID    uuid.UUID
+	Role      string
+	CreatedAt time.Time
+}
+
+type APIKeyModel struct {
+	ID         uuid.UUID
+	CompanyID  uuid.UUID
+	KeyName    string
+	KeyPrefix  string
+	KeyHash    string
+	Scope      string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
 type ProductModel struct {
 	ID          uuid.UUID
 	CompanyID   uuid.UUID
@@ -39,3 +62,125 @@ type ReviewModel struct {
 	Rating    uint
 	CreatedAt time.Time
 }
+
+type WebhookModel struct {
+	ID        uuid.UUID
+	CompanyID uuid.UUID
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookDeliveryModel struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	CompanyID      uuid.UUID
+	Event          string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	ResponseStatus int
+	LastError      string
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	CreatedAt      time.Time
+}
+
+type EventModel struct {
+	// Sequence orders the events of a company as they were written.
+	Sequence  int64
+	ID        uuid.UUID
+	CompanyID uuid.UUID
+	EventType string
+	Data      []byte
+	CreatedAt time.Time
+}
+
+// EventDeliveryModel is the delivery of an event to one outbox sink. Its
+// Status is one of the Delivery constants: deliveries that keep failing end
+// up failed, which leaves them for inspection and lets the later events of
+// the company through.
+type EventDeliveryModel struct {
+	Sequence      int64
+	Sink          string
+	CompanyID     uuid.UUID
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	// Event is the event to deliver, which Claim fills in.
+	Event EventModel
+}
+
+// How a company is notified of new reviews.
+const (
+	NotifyEvery          = "every"
+	NotifyBelowThreshold = "below_threshold"
+	NotifyDigest         = "digest"
+	NotifyOff            = "off"
+)
+
+// How often digests are sent.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// digestPeriods are the periods digests summarize.
+var digestPeriods = map[string]time.Duration{
+	DigestDaily:  24 * time.Hour,
+	DigestWeekly: 7 * 24 * time.Hour,
+}
+
+type NotificationPreferenceModel struct {
+	CompanyID uuid.UUID
+	Mode      string
+	// Threshold is the rating that reviews must be rated below to be
+	// notified of in NotifyBelowThreshold mode.
+	Threshold uint
+	Frequency string
+	// DigestedAt is the end of the period of the last digest.
+	DigestedAt time.Time
+	UpdatedAt  time.Time
+}
+
+// Formats of the messages posted to chat channels.
+const (
+	ChannelSlack = "slack"
+	ChannelTeams = "teams"
+	ChannelJSON  = "json"
+)
+
+// NotificationChannelModel is a chat channel that new reviews are posted to
+// through an incoming-webhook URL.
+type NotificationChannelModel struct {
+	ID        uuid.UUID
+	CompanyID uuid.UUID
+	URL       string
+	Format    string
+	CreatedAt time.Time
+}
+
+// SentNotificationModel is a notification about an event that has been sent
+// to one recipient, an email address or the ID of a chat channel. It is
+// removed along with its event.
+type SentNotificationModel struct {
+	EventID   uuid.UUID
+	Recipient string
+	SentAt    time.Time
+}
+
+// ChallengeModel is a two-factor login challenge that has been attempted.
+type ChallengeModel struct {
+	ID        string
+	Attempts  int
+	Used      bool
+	ExpiresAt time.Time
+}
@@ -1,28 +1,154 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMembersRemain is returned by DeleteAccount when a company to delete
+// has members other than the user.
+var ErrMembersRemain = errors.New("Company has other members")
+
 type User interface {
-	Save(*UserModel) error
-	Find(string) (*UserModel, error)
-	Delete(string) error
+	Save(context.Context, *UserModel) error
+	// Update saves every field but TOTPLastStep, which only UseTOTPStep
+	// moves.
+	Update(context.Context, *UserModel) error
+	Find(context.Context, string) (*UserModel, error)
+	Delete(context.Context, string) error
+	// DeleteAccount deletes a user and their memberships together with the
+	// companies in companyIDs, which only the user may belong to, and their
+	// products, reviews, API keys and other data, in one transaction.
+	DeleteAccount(ctx context.Context, id string, companyIDs []string) error
+	// UseTOTPStep records step as the last accepted TOTP step of the user
+	// and reports whether it is later than the previous one, so that each
+	// code is accepted once.
+	UseTOTPStep(ctx context.Context, id string, step int64) (bool, error)
 }
 
 type Company interface {
-	Save(*CompanyModel) error
-	List() ([]CompanyModel, error)
-	Find(string) (*CompanyModel, error)
-	Delete(string) error
+	Save(context.Context, *CompanyModel) error
+	List(context.Context) ([]CompanyModel, error)
+	Find(context.Context, string) (*CompanyModel, error)
+	Delete(context.Context, string) error
+}
+
+type Member interface {
+	Save(context.Context, *MemberModel) error
+	List(ctx context.Context, companyID string, userID string) ([]MemberModel, error)
+	Find(ctx context.Context, companyID string, userID string) (*MemberModel, error)
+	Delete(ctx context.Context, companyID string, userID string) error
+}
+
+type APIKey interface {
+	Save(context.Context, *APIKeyModel) error
+	List(ctx context.Context, companyID string) ([]APIKeyModel, error)
+	FindByHash(ctx context.Context, hash string) (*APIKeyModel, error)
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+	Delete(ctx context.Context, companyID string, id string) error
 }
 
 type Product interface {
-	Save(*ProductModel) error
-	List(companyID string) ([]ProductModel, error)
-	Find(string) (*ProductModel, error)
-	Delete(string) error
+	Save(context.Context, *ProductModel) error
+	List(ctx context.Context, companyID string) ([]ProductModel, error)
+	Find(context.Context, string) (*ProductModel, error)
+	Delete(context.Context, string) error
 }
 
 type Review interface {
-	Save(*ReviewModel) error
-	List(companyID string, productID string) ([]ReviewModel, error)
-	Find(string) (*ReviewModel, error)
-	Delete(string) error
+	Save(context.Context, *ReviewModel) error
+	List(ctx context.Context, companyID string, productID string) ([]ReviewModel, error)
+	Find(context.Context, string) (*ReviewModel, error)
+	Delete(context.Context, string) error
+}
+
+type Webhook interface {
+	Save(context.Context, *WebhookModel) error
+	List(ctx context.Context, companyID string) ([]WebhookModel, error)
+	Find(ctx context.Context, companyID string, id string) (*WebhookModel, error)
+	Delete(ctx context.Context, companyID string, id string) error
+}
+
+// WebhookDelivery is the queue of webhook deliveries and their log.
+type WebhookDelivery interface {
+	Save(context.Context, *WebhookDeliveryModel) error
+	List(ctx context.Context, webhookID string) ([]WebhookDeliveryModel, error)
+	Find(ctx context.Context, webhookID string, id string) (*WebhookDeliveryModel, error)
+	// Claim returns up to limit pending deliveries that are due at now and
+	// postpones them by lease, so that other dispatchers skip them while
+	// they are being sent.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDeliveryModel, error)
+}
+
+// NotificationPreference holds how each company is notified of reviews.
+type NotificationPreference interface {
+	Save(context.Context, *NotificationPreferenceModel) error
+	// Find returns nil without an error for companies that have not saved
+	// preferences.
+	Find(ctx context.Context, companyID string) (*NotificationPreferenceModel, error)
+	// ClaimDigests returns the preferences whose digests are due at now,
+	// with DigestedAt at the start of the period to summarize, and moves
+	// their DigestedAt to now so that other schedulers skip them.
+	ClaimDigests(ctx context.Context, now time.Time) ([]NotificationPreferenceModel, error)
+}
+
+type NotificationChannel interface {
+	Save(context.Context, *NotificationChannelModel) error
+	List(ctx context.Context, companyID string) ([]NotificationChannelModel, error)
+	Find(ctx context.Context, companyID string, id string) (*NotificationChannelModel, error)
+	Delete(ctx context.Context, companyID string, id string) error
+}
+
+// SentNotification records the notifications sent about events, so that an
+// event published again after some of its notifications failed is not sent
+// twice to the same recipient.
+type SentNotification interface {
+	Save(context.Context, *SentNotificationModel) error
+	// Exists reports whether the notification of an event was sent to
+	// recipient.
+	Exists(ctx context.Context, eventID string, recipient string) (bool, error)
+}
+
+// Challenge tracks attempts at two-factor login challenges, so that each
+// challenge allows a few attempts and a single login.
+type Challenge interface {
+	// Attempt counts an attempt at the challenge with id, which expires at
+	// expiresAt, and returns the challenge with the attempt counted.
+	Attempt(ctx context.Context, id string, expiresAt time.Time) (*ChallengeModel, error)
+	// Use marks the challenge used and reports whether it was unused.
+	Use(ctx context.Context, id string) (bool, error)
+}
+
+// Event is the outbox of events that the Save and Delete methods of the
+// Product and Review stores write along with their changes, and the queue of
+// their deliveries to each outbox sink.
+type Event interface {
+	// Enqueue queues a delivery to each of sinks of up to limit events that
+	// have not been queued yet, oldest first, due from the time the event was
+	// written, and returns how many events it queued. Only one call queues at
+	// a time, so that deliveries are queued in order; concurrent calls queue
+	// none.
+	Enqueue(ctx context.Context, sinks []string, limit int) (int, error)
+	// Claim returns up to limit pending deliveries that are due at now, with
+	// their events, and postpones them by lease so that other dispatchers
+	// skip them while they are being published. Only the earliest pending
+	// delivery of a company to a sink can be claimed, so that every sink
+	// receives the events of a company in order, while a delivery waiting
+	// for a retry holds up no other company or sink.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]EventDeliveryModel, error)
+	// SaveDelivery saves the outcome of an attempt at a delivery.
+	SaveDelivery(ctx context.Context, model *EventDeliveryModel) error
+	// Prune removes the events written before before whose deliveries have
+	// all succeeded or failed, with their deliveries, and returns how many
+	// events it removed.
+	Prune(ctx context.Context, before time.Time) (int, error)
+	// List returns up to limit events of a company that follow the event
+	// numbered after, oldest first. The events of a company are committed in
+	// the order of their sequence numbers, so none is missed by following
+	// them from the last one seen.
+	List(ctx context.Context, companyID string, after int64, limit int) ([]EventModel, error)
+	// Last returns the sequence number of the latest event of a company, or
+	// 0 when it has none.
+	Last(ctx context.Context, companyID string) (int64, error)
 }
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -13,43 +15,86 @@ type UserDatabase struct {
 	Pool *pgxpool.Pool
 }
 
-func (ub UserDatabase) Save(model *UserModel) error {
-	_, err := ub.Pool.Exec(context.Background(), "insert into users(id, email, user_password, created_at) values($1, $2, $3, $4)",
-		model.ID, model.Email, model.UserPassword, model.CreatedAt)
+func (ub UserDatabase) Save(ctx context.Context, model *UserModel) error {
+	_, err := traced(ub.Pool).Exec(ctx, "insert into users(id, email, user_password, email_verified, totp_secret, totp_enabled, recovery_codes, created_at) values($1, $2, $3, $4, $5, $6, $7, $8)",
+		model.ID, model.Email, model.UserPassword, model.EmailVerified, model.TOTPSecret, model.TOTPEnabled, model.RecoveryCodes, model.CreatedAt)
 	return err
 }
 
-func (ub UserDatabase) Find(id string) (*UserModel, error) {
-	row := ub.Pool.QueryRow(context.Background(), "select * from users where id=$1 or email=$2", uuid.FromStringOrNil(id), id)
+func (ub UserDatabase) Update(ctx context.Context, model *UserModel) error {
+	tag, err := traced(ub.Pool).Exec(ctx, "update users set email=$2, user_password=$3, email_verified=$4, totp_secret=$5, totp_enabled=$6, recovery_codes=$7 where id=$1",
+		model.ID, model.Email, model.UserPassword, model.EmailVerified, model.TOTPSecret, model.TOTPEnabled, model.RecoveryCodes)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (ub UserDatabase) Find(ctx context.Context, id string) (*UserModel, error) {
+	row := traced(ub.Pool).QueryRow(ctx, "select id, email, user_password, email_verified, totp_secret, totp_enabled, totp_last_step, recovery_codes, created_at from users where id=$1 or email=$2", uuid.FromStringOrNil(id), id)
 	var model UserModel
-	err := row.Scan(&model.ID, &model.Email, &model.UserPassword, &model.CreatedAt)
+	err := row.Scan(&model.ID, &model.Email, &model.UserPassword, &model.EmailVerified, &model.TOTPSecret, &model.TOTPEnabled, &model.TOTPLastStep, &model.RecoveryCodes, &model.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &model, nil
 }
 
-func (ub UserDatabase) Delete(id string) error {
-	_, err := ub.Pool.Exec(context.Background(), "delete from users where id=$1", uuid.FromStringOrNil(id))
+func (ub UserDatabase) Delete(ctx context.Context, id string) error {
+	_, err := traced(ub.Pool).Exec(ctx, "delete from users where id=$1", uuid.FromStringOrNil(id))
 	return err
 }
 
+// DeleteAccount relies on the cascades of companies and users. Companies
+// that gained members since the caller looked are not deleted, and the whole
+// deletion is rolled back with ErrMembersRemain.
+func (ub UserDatabase) DeleteAccount(ctx context.Context, id string, companyIDs []string) error {
+	return ub.Pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		tag, err := traced(tx).Exec(ctx, "delete from companies c where c.id=any($2::uuid[]) and not exists (select 1 from company_members m where m.company_id=c.id and m.user_id<>$1)",
+			uuid.FromStringOrNil(id), companyIDs)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() != int64(len(companyIDs)) {
+			return ErrMembersRemain
+		}
+		if tag, err = traced(tx).Exec(ctx, "delete from users where id=$1", uuid.FromStringOrNil(id)); err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+}
+
+func (ub UserDatabase) UseTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	tag, err := traced(ub.Pool).Exec(ctx, "update users set totp_last_step=$2 where id=$1 and totp_last_step < $2", uuid.FromStringOrNil(id), step)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
 type CompanyDatabase struct {
 	Pool *pgxpool.Pool
 }
 
-func (cb CompanyDatabase) Save(model *CompanyModel) error {
+func (cb CompanyDatabase) Save(ctx context.Context, model *CompanyModel) error {
 	var initRow CompanyModel
-	row := cb.Pool.QueryRow(context.Background(), "select created_at from companies where id=$1", model.ID)
+	row := traced(cb.Pool).QueryRow(ctx, "select created_at from companies where id=$1", model.ID)
 	err := row.Scan(&initRow.CreatedAt)
 	if err == pgx.ErrNoRows {
-		_, err := cb.Pool.Exec(context.Background(), "insert into companies(id, company_user_id, company_name, email, logo, created_at) values($1, $2, $3, $4, $5, $6)",
-			model.ID, model.CompanyUserID, model.CompanyName, model.Email, model.Logo, model.CreatedAt)
+		_, err := traced(cb.Pool).Exec(ctx, "insert into companies(id, company_name, email, logo, created_at) values($1, $2, $3, $4, $5)",
+			model.ID, model.CompanyName, model.Email, model.Logo, model.CreatedAt)
 		return err
 	} else if err != nil {
 		return err
 	}
-	_, err = cb.Pool.Exec(context.Background(), "update companies set company_name=$2, email=$3, logo=$4 where id=$1", model.ID, model.CompanyName, model.Email, model.Logo)
+	_, err = traced(cb.Pool).Exec(ctx, "update companies set company_name=$2, email=$3, logo=$4 where id=$1", model.ID, model.CompanyName, model.Email, model.Logo)
 	if err != nil {
 		return err
 	}
@@ -59,15 +104,16 @@ func (cb CompanyDatabase) Save(model *CompanyModel) error {
 	return nil
 }
 
-func (cb CompanyDatabase) List() ([]CompanyModel, error) {
-	rows, err := cb.Pool.Query(context.Background(), "select * from companies")
+func (cb CompanyDatabase) List(ctx context.Context) ([]CompanyModel, error) {
+	rows, err := traced(cb.Pool).Query(ctx, "select id, company_name, email, logo, created_at from companies")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var models []CompanyModel
 	for rows.Next() {
 		var model CompanyModel
-		err = rows.Scan(&model.ID, &model.CompanyUserID, &model.CompanyName, &model.Email, &model.Logo, &model.CreatedAt)
+		err = rows.Scan(&model.ID, &model.CompanyName, &model.Email, &model.Logo, &model.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -76,60 +122,179 @@ func (cb CompanyDatabase) List() ([]CompanyModel, error) {
 	return models, nil
 }
 
-func (cb CompanyDatabase) Find(id string) (*CompanyModel, error) {
-	row := cb.Pool.QueryRow(context.Background(), "select * from companies where id=$1 or company_user_id=$2", uuid.FromStringOrNil(id), id)
+func (cb CompanyDatabase) Find(ctx context.Context, id string) (*CompanyModel, error) {
+	row := traced(cb.Pool).QueryRow(ctx, "select id, company_name, email, logo, created_at from companies where id=$1", uuid.FromStringOrNil(id))
 	var model CompanyModel
-	err := row.Scan(&model.ID, &model.CompanyUserID, &model.CompanyName, &model.Email, &model.Logo, &model.CreatedAt)
+	err := row.Scan(&model.ID, &model.CompanyName, &model.Email, &model.Logo, &model.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &model, nil
 }
 
-func (cb CompanyDatabase) Delete(id string) error {
-	_, err := cb.Pool.Exec(context.Background(), "delete from companies where id=$1", uuid.FromStringOrNil(id))
+func (cb CompanyDatabase) Delete(ctx context.Context, id string) error {
+	_, err := traced(cb.Pool).Exec(ctx, "delete from companies where id=$1", uuid.FromStringOrNil(id))
 	return err
 }
 
-type ProductDatabase struct {
+type MemberDatabase struct {
 	Pool *pgxpool.Pool
 }
 
-func (cb ProductDatabase) Save(model *ProductModel) error {
-	var initRow ProductModel
-	row := cb.Pool.QueryRow(context.Background(), "select rating, created_at from products where id=$1", model.ID)
-	err := row.Scan(&initRow.Rating, &initRow.CreatedAt)
-	if err == pgx.ErrNoRows {
-		_, err := cb.Pool.Exec(context.Background(), "insert into products(id, company_id, product_name, feedback_url, rating, created_at) values($1, $2, $3, $4, $5, $6)",
-			model.ID, model.CompanyID, model.ProductName, model.FeedbackURL, model.Rating, model.CreatedAt)
-		return err
-	} else if err != nil {
-		return err
+func (mb MemberDatabase) Save(ctx context.Context, model *MemberModel) error {
+	_, err := traced(mb.Pool).Exec(ctx, "insert into company_members(company_id, user_id, member_role, created_at) values($1, $2, $3, $4) on conflict (company_id, user_id) do update set member_role=excluded.member_role",
+		model.CompanyID, model.UserID, model.Role, model.CreatedAt)
+	return err
+}
+
+func (mb MemberDatabase) List(ctx context.Context, companyID string, userID string) ([]MemberModel, error) {
+	stmt := "select company_id, user_id, member_role, created_at from company_members where company_id is not null"
+	var args []interface{}
+	if companyID != "" {
+		stmt = stmt + " and company_id=$" + strconv.Itoa(len(args)+1)
+		args = append(args, companyID)
 	}
-	_, err = cb.Pool.Exec(context.Background(), "update products set product_name=$2, feedback_url=$3, rating=$4 where id=$1", model.ID, model.ProductName, model.FeedbackURL, model.Rating)
+	if userID != "" {
+		stmt = stmt + " and user_id=$" + strconv.Itoa(len(args)+1)
+		args = append(args, userID)
+	}
+	rows, err := traced(mb.Pool).Query(ctx, stmt+" order by created_at", args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if model.Rating == 0 {
-		model.Rating = initRow.Rating
+	defer rows.Close()
+	var models []MemberModel
+	for rows.Next() {
+		var model MemberModel
+		err = rows.Scan(&model.CompanyID, &model.UserID, &model.Role, &model.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
 	}
-	if &model.CreatedAt == nil {
-		model.CreatedAt = initRow.CreatedAt
+	return models, nil
+}
+
+func (mb MemberDatabase) Find(ctx context.Context, companyID string, userID string) (*MemberModel, error) {
+	row := traced(mb.Pool).QueryRow(ctx, "select company_id, user_id, member_role, created_at from company_members where company_id=$1 and user_id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(userID))
+	var model MemberModel
+	err := row.Scan(&model.CompanyID, &model.UserID, &model.Role, &model.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (mb MemberDatabase) Delete(ctx context.Context, companyID string, userID string) error {
+	_, err := traced(mb.Pool).Exec(ctx, "delete from company_members where company_id=$1 and user_id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(userID))
+	return err
+}
+
+type APIKeyDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+func (kb APIKeyDatabase) Save(ctx context.Context, model *APIKeyModel) error {
+	_, err := traced(kb.Pool).Exec(ctx, "insert into api_keys(id, company_id, key_name, key_prefix, key_hash, scope, created_at) values($1, $2, $3, $4, $5, $6, $7)",
+		model.ID, model.CompanyID, model.KeyName, model.KeyPrefix, model.KeyHash, model.Scope, model.CreatedAt)
+	return err
+}
+
+func (kb APIKeyDatabase) List(ctx context.Context, companyID string) ([]APIKeyModel, error) {
+	rows, err := traced(kb.Pool).Query(ctx, "select id, company_id, key_name, key_prefix, key_hash, scope, created_at, last_used_at from api_keys where company_id=$1 order by created_at",
+		uuid.FromStringOrNil(companyID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []APIKeyModel
+	for rows.Next() {
+		var model APIKeyModel
+		err = rows.Scan(&model.ID, &model.CompanyID, &model.KeyName, &model.KeyPrefix, &model.KeyHash, &model.Scope, &model.CreatedAt, &model.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, rows.Err()
+}
+
+func (kb APIKeyDatabase) FindByHash(ctx context.Context, hash string) (*APIKeyModel, error) {
+	row := traced(kb.Pool).QueryRow(ctx, "select id, company_id, key_name, key_prefix, key_hash, scope, created_at, last_used_at from api_keys where key_hash=$1", hash)
+	var model APIKeyModel
+	err := row.Scan(&model.ID, &model.CompanyID, &model.KeyName, &model.KeyPrefix, &model.KeyHash, &model.Scope, &model.CreatedAt, &model.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (kb APIKeyDatabase) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := traced(kb.Pool).Exec(ctx, "update api_keys set last_used_at=$2 where id=$1", uuid.FromStringOrNil(id), usedAt)
+	return err
+}
+
+func (kb APIKeyDatabase) Delete(ctx context.Context, companyID string, id string) error {
+	tag, err := traced(kb.Pool).Exec(ctx, "delete from api_keys where company_id=$1 and id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(id))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
 	}
 	return nil
 }
 
-func (cb ProductDatabase) List(companyID string) ([]ProductModel, error) {
+type ProductDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+func (cb ProductDatabase) Save(ctx context.Context, model *ProductModel) error {
+	return cb.Pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var initRow ProductModel
+		row := traced(tx).QueryRow(ctx, "select rating, created_at from products where id=$1", model.ID)
+		err := row.Scan(&initRow.Rating, &initRow.CreatedAt)
+		if err == pgx.ErrNoRows {
+			_, err := traced(tx).Exec(ctx, "insert into products(id, company_id, product_name, feedback_url, rating, created_at) values($1, $2, $3, $4, $5, $6)",
+				model.ID, model.CompanyID, model.ProductName, model.FeedbackURL, model.Rating, model.CreatedAt)
+			if err != nil {
+				return err
+			}
+			return insertEvent(ctx, tx, EventProductCreated, model.CompanyID, productEventData(model))
+		} else if err != nil {
+			return err
+		}
+		var saved ProductModel
+		row = traced(tx).QueryRow(ctx, "update products set product_name=$2, feedback_url=$3, rating=$4 where id=$1 returning *", model.ID, model.ProductName, model.FeedbackURL, model.Rating)
+		err = row.Scan(&saved.ID, &saved.CompanyID, &saved.ProductName, &saved.FeedbackURL, &saved.Rating, &saved.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if model.Rating == 0 {
+			model.Rating = initRow.Rating
+		}
+		if &model.CreatedAt == nil {
+			model.CreatedAt = initRow.CreatedAt
+		}
+		return insertEvent(ctx, tx, EventProductUpdated, saved.CompanyID, productEventData(&saved))
+	})
+}
+
+func (cb ProductDatabase) List(ctx context.Context, companyID string) ([]ProductModel, error) {
 	stmt := "select * from products"
 	var args []interface{}
 	if companyID != "" {
 		stmt = stmt + " where company_id=$1"
 		args = append(args, companyID)
 	}
-	rows, err := cb.Pool.Query(context.Background(), stmt, args...)
+	rows, err := traced(cb.Pool).Query(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var models []ProductModel
 	for rows.Next() {
 		var model ProductModel
@@ -142,8 +307,8 @@ func (cb ProductDatabase) List(companyID string) ([]ProductModel, error) {
 	return models, nil
 }
 
-func (cb ProductDatabase) Find(id string) (*ProductModel, error) {
-	row := cb.Pool.QueryRow(context.Background(), "select * from products where id=$1", uuid.FromStringOrNil(id))
+func (cb ProductDatabase) Find(ctx context.Context, id string) (*ProductModel, error) {
+	row := traced(cb.Pool).QueryRow(ctx, "select * from products where id=$1", uuid.FromStringOrNil(id))
 	var model ProductModel
 	err := row.Scan(&model.ID, &model.CompanyID, &model.ProductName, &model.FeedbackURL, &model.Rating, &model.CreatedAt)
 	if err != nil {
@@ -152,37 +317,53 @@ func (cb ProductDatabase) Find(id string) (*ProductModel, error) {
 	return &model, nil
 }
 
-func (cb ProductDatabase) Delete(id string) error {
-	_, err := cb.Pool.Exec(context.Background(), "delete from products where id=$1", uuid.FromStringOrNil(id))
-	return err
+func (cb ProductDatabase) Delete(ctx context.Context, id string) error {
+	return cb.Pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		row := traced(tx).QueryRow(ctx, "delete from products where id=$1 returning *", uuid.FromStringOrNil(id))
+		var model ProductModel
+		err := row.Scan(&model.ID, &model.CompanyID, &model.ProductName, &model.FeedbackURL, &model.Rating, &model.CreatedAt)
+		if err == pgx.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, EventProductDeleted, model.CompanyID, productEventData(&model))
+	})
 }
 
 type ReviewDatabase struct {
 	Pool *pgxpool.Pool
 }
 
-func (cb ReviewDatabase) Save(model *ReviewModel) error {
-	var initRow ReviewModel
-	row := cb.Pool.QueryRow(context.Background(), "select created_at from reviews where id=$1", model.ID)
-	err := row.Scan(&initRow.CreatedAt)
-	if err == pgx.ErrNoRows {
-		_, err := cb.Pool.Exec(context.Background(), "insert into reviews(id, company_id, product_id, comment, rating, created_at) values($1, $2, $3, $4, $5, $6)",
-			model.ID, model.CompanyID, model.ProductID, model.Comment, model.Rating, model.CreatedAt)
-		return err
-	} else if err != nil {
-		return err
-	}
-	_, err = cb.Pool.Exec(context.Background(), "update reviews set comment=$2, rating=$3 where id=$1", model.ID, model.Comment, model.Rating)
-	if err != nil {
-		return err
-	}
-	if &model.CreatedAt == nil {
-		model.CreatedAt = initRow.CreatedAt
-	}
-	return nil
+func (cb ReviewDatabase) Save(ctx context.Context, model *ReviewModel) error {
+	return cb.Pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var initRow ReviewModel
+		row := traced(tx).QueryRow(ctx, "select created_at from reviews where id=$1", model.ID)
+		err := row.Scan(&initRow.CreatedAt)
+		if err == pgx.ErrNoRows {
+			_, err := traced(tx).Exec(ctx, "insert into reviews(id, company_id, product_id, comment, rating, created_at) values($1, $2, $3, $4, $5, $6)",
+				model.ID, model.CompanyID, model.ProductID, model.Comment, model.Rating, model.CreatedAt)
+			if err != nil {
+				return err
+			}
+			return insertEvent(ctx, tx, EventReviewCreated, model.CompanyID, reviewEventData(model))
+		} else if err != nil {
+			return err
+		}
+		var saved ReviewModel
+		row = traced(tx).QueryRow(ctx, "update reviews set comment=$2, rating=$3 where id=$1 returning *", model.ID, model.Comment, model.Rating)
+		err = row.Scan(&saved.ID, &saved.CompanyID, &saved.ProductID, &saved.Comment, &saved.Rating, &saved.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if &model.CreatedAt == nil {
+			model.CreatedAt = initRow.CreatedAt
+		}
+		return insertEvent(ctx, tx, EventReviewUpdated, saved.CompanyID, reviewEventData(&saved))
+	})
 }
 
-func (cb ReviewDatabase) List(companyID string, productID string) ([]ReviewModel, error) {
+func (cb ReviewDatabase) List(ctx context.Context, companyID string, productID string) ([]ReviewModel, error) {
 	stmt := "select * from reviews where id is not null"
 	var args []interface{}
 	if companyID != "" {
@@ -193,10 +374,11 @@ func (cb ReviewDatabase) List(companyID string, productID string) ([]ReviewModel
 		stmt = stmt + " and product_id=$" + strconv.Itoa(len(args)+1)
 		args = append(args, productID)
 	}
-	rows, err := cb.Pool.Query(context.Background(), stmt, args...)
+	rows, err := traced(cb.Pool).Query(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var models []ReviewModel
 	for rows.Next() {
 		var model ReviewModel
@@ -209,8 +391,8 @@ func (cb ReviewDatabase) List(companyID string, productID string) ([]ReviewModel
 	return models, nil
 }
 
-func (cb ReviewDatabase) Find(id string) (*ReviewModel, error) {
-	row := cb.Pool.QueryRow(context.Background(), "select * from reviews where id=$1", uuid.FromStringOrNil(id))
+func (cb ReviewDatabase) Find(ctx context.Context, id string) (*ReviewModel, error) {
+	row := traced(cb.Pool).QueryRow(ctx, "select * from reviews where id=$1", uuid.FromStringOrNil(id))
 	var model ReviewModel
 	err := row.Scan(&model.ID, &model.CompanyID, &model.ProductID, &model.Comment, &model.Rating, &model.CreatedAt)
 	if err != nil {
@@ -219,7 +401,403 @@ func (cb ReviewDatabase) Find(id string) (*ReviewModel, error) {
 	return &model, nil
 }
 
-func (cb ReviewDatabase) Delete(id string) error {
-	_, err := cb.Pool.Exec(context.Background(), "delete from reviews where id=$1", uuid.FromStringOrNil(id))
+func (cb ReviewDatabase) Delete(ctx context.Context, id string) error {
+	return cb.Pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		row := traced(tx).QueryRow(ctx, "delete from reviews where id=$1 returning *", uuid.FromStringOrNil(id))
+		var model ReviewModel
+		err := row.Scan(&model.ID, &model.CompanyID, &model.ProductID, &model.Comment, &model.Rating, &model.CreatedAt)
+		if err == pgx.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, EventReviewDeleted, model.CompanyID, reviewEventData(&model))
+	})
+}
+
+type WebhookDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+func (wb WebhookDatabase) Save(ctx context.Context, model *WebhookModel) error {
+	_, err := traced(wb.Pool).Exec(ctx, "insert into webhooks(id, company_id, url, secret, events, created_at) values($1, $2, $3, $4, $5, $6)",
+		model.ID, model.CompanyID, model.URL, model.Secret, model.Events, model.CreatedAt)
 	return err
 }
+
+func (wb WebhookDatabase) List(ctx context.Context, companyID string) ([]WebhookModel, error) {
+	rows, err := traced(wb.Pool).Query(ctx, "select id, company_id, url, secret, events, created_at from webhooks where company_id=$1 order by created_at",
+		uuid.FromStringOrNil(companyID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []WebhookModel
+	for rows.Next() {
+		var model WebhookModel
+		err = rows.Scan(&model.ID, &model.CompanyID, &model.URL, &model.Secret, &model.Events, &model.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+func (wb WebhookDatabase) Find(ctx context.Context, companyID string, id string) (*WebhookModel, error) {
+	row := traced(wb.Pool).QueryRow(ctx, "select id, company_id, url, secret, events, created_at from webhooks where company_id=$1 and id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(id))
+	var model WebhookModel
+	err := row.Scan(&model.ID, &model.CompanyID, &model.URL, &model.Secret, &model.Events, &model.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (wb WebhookDatabase) Delete(ctx context.Context, companyID string, id string) error {
+	tag, err := traced(wb.Pool).Exec(ctx, "delete from webhooks where company_id=$1 and id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(id))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+type WebhookDeliveryDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+const webhookDeliveryColumns = "id, webhook_id, company_id, event, payload, status, attempts, response_status, last_error, next_attempt_at, last_attempt_at, created_at"
+
+func scanWebhookDelivery(row pgx.Row) (*WebhookDeliveryModel, error) {
+	var model WebhookDeliveryModel
+	err := row.Scan(&model.ID, &model.WebhookID, &model.CompanyID, &model.Event, &model.Payload, &model.Status, &model.Attempts,
+		&model.ResponseStatus, &model.LastError, &model.NextAttemptAt, &model.LastAttemptAt, &model.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (db WebhookDeliveryDatabase) Save(ctx context.Context, model *WebhookDeliveryModel) error {
+	_, err := traced(db.Pool).Exec(ctx, "insert into webhook_deliveries("+webhookDeliveryColumns+") values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) "+
+		"on conflict (id) do update set status=$6, attempts=$7, response_status=$8, last_error=$9, next_attempt_at=$10, last_attempt_at=$11",
+		model.ID, model.WebhookID, model.CompanyID, model.Event, model.Payload, model.Status, model.Attempts,
+		model.ResponseStatus, model.LastError, model.NextAttemptAt, model.LastAttemptAt, model.CreatedAt)
+	return err
+}
+
+func (db WebhookDeliveryDatabase) List(ctx context.Context, webhookID string) ([]WebhookDeliveryModel, error) {
+	rows, err := traced(db.Pool).Query(ctx, "select "+webhookDeliveryColumns+" from webhook_deliveries where webhook_id=$1 order by created_at desc limit 100",
+		uuid.FromStringOrNil(webhookID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []WebhookDeliveryModel
+	for rows.Next() {
+		model, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, *model)
+	}
+	return models, nil
+}
+
+func (db WebhookDeliveryDatabase) Find(ctx context.Context, webhookID string, id string) (*WebhookDeliveryModel, error) {
+	row := traced(db.Pool).QueryRow(ctx, "select "+webhookDeliveryColumns+" from webhook_deliveries where webhook_id=$1 and id=$2",
+		uuid.FromStringOrNil(webhookID), uuid.FromStringOrNil(id))
+	return scanWebhookDelivery(row)
+}
+
+// Claim locks the due rows with skip locked, so that concurrent server
+// processes claim different deliveries.
+func (db WebhookDeliveryDatabase) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDeliveryModel, error) {
+	rows, err := traced(db.Pool).Query(ctx, "update webhook_deliveries set next_attempt_at=$2 where id in ("+
+		"select id from webhook_deliveries where status=$3 and next_attempt_at<=$1 order by next_attempt_at limit $4 for update skip locked) "+
+		"returning "+webhookDeliveryColumns,
+		now, now.Add(lease), DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []WebhookDeliveryModel
+	for rows.Next() {
+		model, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, *model)
+	}
+	return models, nil
+}
+
+// Advisory lock classes of the outbox. Each company has a lock of
+// eventCompanyLock keyed by a hash of its ID.
+const (
+	eventCompanyLock = 1
+	eventQueueLock   = 2
+)
+
+// insertEvent writes an event in the transaction of the change it describes.
+// The company's lock is held until the transaction ends, so the events of a
+// company are committed in the order of their sequence numbers and Enqueue
+// never queues a later event before an earlier one.
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType string, companyID uuid.UUID, data interface{}) error {
+	event, err := newEvent(eventType, companyID, data)
+	if err != nil {
+		return err
+	}
+	if _, err = traced(tx).Exec(ctx, "select pg_advisory_xact_lock($1, hashtext($2))", eventCompanyLock, companyID.String()); err != nil {
+		return err
+	}
+	_, err = traced(tx).Exec(ctx, "insert into events(id, company_id, event_type, data, created_at) values($1, $2, $3, $4, $5)",
+		event.ID, event.CompanyID, event.EventType, event.Data, event.CreatedAt)
+	return err
+}
+
+type EventDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+// Enqueue holds the queue lock only while it queues, and never while events
+// are published.
+func (eb EventDatabase) Enqueue(ctx context.Context, sinks []string, limit int) (int, error) {
+	var n int
+	err := eb.Pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var locked bool
+		if err := traced(tx).QueryRow(ctx, "select pg_try_advisory_xact_lock($1, 0)", eventQueueLock).Scan(&locked); err != nil || !locked {
+			return err
+		}
+		return traced(tx).QueryRow(ctx, "with batch as (select sequence, company_id, created_at from events where not queued order by sequence limit $1), "+
+			"queued as (update events set queued=true where sequence in (select sequence from batch)), "+
+			"deliveries as (insert into event_deliveries(sequence, sink, company_id, status, attempts, last_error, next_attempt_at) "+
+			"select b.sequence, s.sink, b.company_id, $2, 0, '', b.created_at from batch b cross join unnest($3::text[]) as s(sink)) "+
+			"select count(*) from batch",
+			limit, DeliveryPending, sinks).Scan(&n)
+	})
+	return n, err
+}
+
+// Claim locks the due rows with skip locked, so that concurrent server
+// processes claim different deliveries.
+func (eb EventDatabase) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]EventDeliveryModel, error) {
+	rows, err := traced(eb.Pool).Query(ctx, "with heads as ("+
+		"select distinct on (sink, company_id) sequence, sink from event_deliveries where status=$3 order by sink, company_id, sequence), "+
+		"claimed as (update event_deliveries set next_attempt_at=$2 where (sequence, sink) in ("+
+		"select d.sequence, d.sink from event_deliveries d join heads h on h.sequence=d.sequence and h.sink=d.sink "+
+		"where d.status=$3 and d.next_attempt_at<=$1 order by d.sequence limit $4 for update of d skip locked) "+
+		"returning sequence, sink, company_id, status, attempts, last_error, next_attempt_at) "+
+		"select c.sequence, c.sink, c.company_id, c.status, c.attempts, c.last_error, c.next_attempt_at, e.sequence, e.id, e.company_id, e.event_type, e.data, e.created_at "+
+		"from claimed c join events e on e.sequence=c.sequence order by c.sequence",
+		now, now.Add(lease), DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []EventDeliveryModel
+	for rows.Next() {
+		var model EventDeliveryModel
+		err = rows.Scan(&model.Sequence, &model.Sink, &model.CompanyID, &model.Status, &model.Attempts, &model.LastError, &model.NextAttemptAt,
+			&model.Event.Sequence, &model.Event.ID, &model.Event.CompanyID, &model.Event.EventType, &model.Event.Data, &model.Event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, rows.Err()
+}
+
+func (eb EventDatabase) SaveDelivery(ctx context.Context, model *EventDeliveryModel) error {
+	tag, err := traced(eb.Pool).Exec(ctx, "update event_deliveries set status=$3, attempts=$4, last_error=$5, next_attempt_at=$6 where sequence=$1 and sink=$2",
+		model.Sequence, model.Sink, model.Status, model.Attempts, model.LastError, model.NextAttemptAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Prune keeps the events that have not been queued yet or still have a
+// pending delivery, whatever their age.
+func (eb EventDatabase) Prune(ctx context.Context, before time.Time) (int, error) {
+	tag, err := traced(eb.Pool).Exec(ctx, "delete from events where queued and created_at<$1 and not exists (select 1 from event_deliveries d where d.sequence=events.sequence and d.status=$2)",
+		before, DeliveryPending)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (eb EventDatabase) List(ctx context.Context, companyID string, after int64, limit int) ([]EventModel, error) {
+	rows, err := traced(eb.Pool).Query(ctx, "select sequence, id, company_id, event_type, data, created_at from events where company_id=$1 and sequence>$2 order by sequence limit $3",
+		uuid.FromStringOrNil(companyID), after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []EventModel
+	for rows.Next() {
+		var model EventModel
+		if err = rows.Scan(&model.Sequence, &model.ID, &model.CompanyID, &model.EventType, &model.Data, &model.CreatedAt); err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, rows.Err()
+}
+
+func (eb EventDatabase) Last(ctx context.Context, companyID string) (int64, error) {
+	var sequence int64
+	err := traced(eb.Pool).QueryRow(ctx, "select coalesce(max(sequence), 0) from events where company_id=$1", uuid.FromStringOrNil(companyID)).Scan(&sequence)
+	return sequence, err
+}
+
+type NotificationPreferenceDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+func (db NotificationPreferenceDatabase) Save(ctx context.Context, model *NotificationPreferenceModel) error {
+	_, err := traced(db.Pool).Exec(ctx, "insert into notification_preferences(company_id, mode, threshold, frequency, digested_at, updated_at) values($1, $2, $3, $4, $5, $6) "+
+		"on conflict (company_id) do update set mode=excluded.mode, threshold=excluded.threshold, frequency=excluded.frequency, digested_at=excluded.digested_at, updated_at=excluded.updated_at",
+		model.CompanyID, model.Mode, model.Threshold, model.Frequency, model.DigestedAt, model.UpdatedAt)
+	return err
+}
+
+func (db NotificationPreferenceDatabase) Find(ctx context.Context, companyID string) (*NotificationPreferenceModel, error) {
+	row := traced(db.Pool).QueryRow(ctx, "select company_id, mode, threshold, frequency, digested_at, updated_at from notification_preferences where company_id=$1",
+		uuid.FromStringOrNil(companyID))
+	var model NotificationPreferenceModel
+	err := row.Scan(&model.CompanyID, &model.Mode, &model.Threshold, &model.Frequency, &model.DigestedAt, &model.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (db NotificationPreferenceDatabase) ClaimDigests(ctx context.Context, now time.Time) ([]NotificationPreferenceModel, error) {
+	rows, err := traced(db.Pool).Query(ctx, "with due as ("+
+		"select company_id, digested_at from notification_preferences where mode=$2 and ((frequency=$3 and digested_at<=$4) or (frequency=$5 and digested_at<=$6)) for update skip locked) "+
+		"update notification_preferences p set digested_at=$1 from due where p.company_id=due.company_id "+
+		"returning p.company_id, p.mode, p.threshold, p.frequency, due.digested_at, p.updated_at",
+		now, NotifyDigest, DigestDaily, now.Add(-digestPeriods[DigestDaily]), DigestWeekly, now.Add(-digestPeriods[DigestWeekly]))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []NotificationPreferenceModel
+	for rows.Next() {
+		var model NotificationPreferenceModel
+		err = rows.Scan(&model.CompanyID, &model.Mode, &model.Threshold, &model.Frequency, &model.DigestedAt, &model.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+type NotificationChannelDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+func (db NotificationChannelDatabase) Save(ctx context.Context, model *NotificationChannelModel) error {
+	_, err := traced(db.Pool).Exec(ctx, "insert into notification_channels(id, company_id, url, format, created_at) values($1, $2, $3, $4, $5)",
+		model.ID, model.CompanyID, model.URL, model.Format, model.CreatedAt)
+	return err
+}
+
+func (db NotificationChannelDatabase) List(ctx context.Context, companyID string) ([]NotificationChannelModel, error) {
+	rows, err := traced(db.Pool).Query(ctx, "select id, company_id, url, format, created_at from notification_channels where company_id=$1 order by created_at",
+		uuid.FromStringOrNil(companyID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []NotificationChannelModel
+	for rows.Next() {
+		var model NotificationChannelModel
+		err = rows.Scan(&model.ID, &model.CompanyID, &model.URL, &model.Format, &model.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+func (db NotificationChannelDatabase) Find(ctx context.Context, companyID string, id string) (*NotificationChannelModel, error) {
+	row := traced(db.Pool).QueryRow(ctx, "select id, company_id, url, format, created_at from notification_channels where company_id=$1 and id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(id))
+	var model NotificationChannelModel
+	err := row.Scan(&model.ID, &model.CompanyID, &model.URL, &model.Format, &model.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (db NotificationChannelDatabase) Delete(ctx context.Context, companyID string, id string) error {
+	tag, err := traced(db.Pool).Exec(ctx, "delete from notification_channels where company_id=$1 and id=$2",
+		uuid.FromStringOrNil(companyID), uuid.FromStringOrNil(id))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+type SentNotificationDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+func (db SentNotificationDatabase) Save(ctx context.Context, model *SentNotificationModel) error {
+	_, err := traced(db.Pool).Exec(ctx, "insert into sent_notifications(event_id, recipient, sent_at) values($1, $2, $3) on conflict do nothing",
+		model.EventID, model.Recipient, model.SentAt)
+	return err
+}
+
+func (db SentNotificationDatabase) Exists(ctx context.Context, eventID string, recipient string) (bool, error) {
+	var exists bool
+	err := traced(db.Pool).QueryRow(ctx, "select exists(select 1 from sent_notifications where event_id=$1 and recipient=$2)",
+		uuid.FromStringOrNil(eventID), recipient).Scan(&exists)
+	return exists, err
+}
+
+type ChallengeDatabase struct {
+	Pool *pgxpool.Pool
+}
+
+// Attempt also forgets expired challenges, which can no longer be attempted.
+func (db ChallengeDatabase) Attempt(ctx context.Context, id string, expiresAt time.Time) (*ChallengeModel, error) {
+	if _, err := traced(db.Pool).Exec(ctx, "delete from two_factor_challenges where expires_at < $1", time.Now()); err != nil {
+		return nil, err
+	}
+	row := traced(db.Pool).QueryRow(ctx, "insert into two_factor_challenges(id, attempts, used, expires_at) values($1, 1, false, $2) "+
+		"on conflict (id) do update set attempts = two_factor_challenges.attempts + 1 returning id, attempts, used, expires_at", id, expiresAt)
+	var model ChallengeModel
+	if err := row.Scan(&model.ID, &model.Attempts, &model.Used, &model.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (db ChallengeDatabase) Use(ctx context.Context, id string) (bool, error) {
+	tag, err := traced(db.Pool).Exec(ctx, "update two_factor_challenges set used=true where id=$1 and not used", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -1,12 +1,23 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
 
+	uuid "github.com/satori/go.uuid"
+)
+
+// UserMemoryStore deletes accounts from Companies and Members, when they are
+// set, as the database cascades.
 type UserMemoryStore struct {
-	users []UserModel
+	users     []UserModel
+	Companies *CompanyMemoryStore
+	Members   *MemberMemoryStore
 }
 
-func (ums *UserMemoryStore) Save(model *UserModel) error {
+func (ums *UserMemoryStore) Save(ctx context.Context, model *UserModel) error {
 	for _, record := range ums.users {
 		if record.ID == model.ID {
 			return errors.New("User already exists")
@@ -16,7 +27,18 @@ func (ums *UserMemoryStore) Save(model *UserModel) error {
 	return nil
 }
 
-func (ums UserMemoryStore) Find(id string) (*UserModel, error) {
+func (ums *UserMemoryStore) Update(ctx context.Context, model *UserModel) error {
+	for index, record := range ums.users {
+		if record.ID == model.ID {
+			ums.users[index] = *model
+			ums.users[index].TOTPLastStep = record.TOTPLastStep
+			return nil
+		}
+	}
+	return errors.New("User not found")
+}
+
+func (ums UserMemoryStore) Find(ctx context.Context, id string) (*UserModel, error) {
 	for _, record := range ums.users {
 		if record.ID.String() == id || record.Email == id {
 			return &record, nil
@@ -25,7 +47,7 @@ func (ums UserMemoryStore) Find(id string) (*UserModel, error) {
 	return nil, errors.New("User not found")
 }
 
-func (ums *UserMemoryStore) Delete(id string) error {
+func (ums *UserMemoryStore) Delete(ctx context.Context, id string) error {
 	for index, record := range ums.users {
 		if record.ID.String() == id {
 			ums.users[index] = ums.users[len(ums.users)-1]
@@ -36,11 +58,54 @@ func (ums *UserMemoryStore) Delete(id string) error {
 	return errors.New("User not found")
 }
 
+func (ums *UserMemoryStore) DeleteAccount(ctx context.Context, id string, companyIDs []string) error {
+	if ums.Members != nil {
+		for _, companyID := range companyIDs {
+			for _, record := range ums.Members.members {
+				if record.CompanyID.String() == companyID && record.UserID.String() != id {
+					return ErrMembersRemain
+				}
+			}
+		}
+	}
+	if _, err := ums.Find(ctx, id); err != nil {
+		return err
+	}
+	if ums.Companies != nil {
+		for _, companyID := range companyIDs {
+			ums.Companies.Delete(ctx, companyID)
+		}
+	}
+	if ums.Members != nil {
+		members := ums.Members.members[:0]
+		for _, record := range ums.Members.members {
+			if record.UserID.String() != id {
+				members = append(members, record)
+			}
+		}
+		ums.Members.members = members
+	}
+	return ums.Delete(ctx, id)
+}
+
+func (ums *UserMemoryStore) UseTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	for index, record := range ums.users {
+		if record.ID.String() == id {
+			if step <= record.TOTPLastStep {
+				return false, nil
+			}
+			ums.users[index].TOTPLastStep = step
+			return true, nil
+		}
+	}
+	return false, errors.New("User not found")
+}
+
 type CompanyMemoryStore struct {
 	companies []CompanyModel
 }
 
-func (cms *CompanyMemoryStore) Save(model *CompanyModel) error {
+func (cms *CompanyMemoryStore) Save(ctx context.Context, model *CompanyModel) error {
 	for index, record := range cms.companies {
 		if record.ID == model.ID {
 			if &model.CompanyName != nil {
@@ -60,14 +125,14 @@ func (cms *CompanyMemoryStore) Save(model *CompanyModel) error {
 	return nil
 }
 
-func (cms CompanyMemoryStore) List() ([]CompanyModel, error) {
+func (cms CompanyMemoryStore) List(ctx context.Context) ([]CompanyModel, error) {
 	if len(cms.companies) == 0 {
 		return cms.companies, errors.New("No companies found")
 	}
 	return cms.companies, nil
 }
 
-func (cms CompanyMemoryStore) Find(id string) (*CompanyModel, error) {
+func (cms CompanyMemoryStore) Find(ctx context.Context, id string) (*CompanyModel, error) {
 	for _, record := range cms.companies {
 		if record.ID.String() == id {
 			return &record, nil
@@ -76,7 +141,7 @@ func (cms CompanyMemoryStore) Find(id string) (*CompanyModel, error) {
 	return nil, errors.New("Company not found")
 }
 
-func (cms *CompanyMemoryStore) Delete(id string) error {
+func (cms *CompanyMemoryStore) Delete(ctx context.Context, id string) error {
 	for index, record := range cms.companies {
 		if record.ID.String() == id {
 			cms.companies[index] = cms.companies[len(cms.companies)-1]
@@ -87,11 +152,111 @@ func (cms *CompanyMemoryStore) Delete(id string) error {
 	return errors.New("Company not found")
 }
 
+type MemberMemoryStore struct {
+	members []MemberModel
+}
+
+func (mms *MemberMemoryStore) Save(ctx context.Context, model *MemberModel) error {
+	for index, record := range mms.members {
+		if record.CompanyID == model.CompanyID && record.UserID == model.UserID {
+			mms.members[index].Role = model.Role
+			return nil
+		}
+	}
+	mms.members = append(mms.members, *model)
+	return nil
+}
+
+func (mms MemberMemoryStore) List(ctx context.Context, companyID string, userID string) ([]MemberModel, error) {
+	var records []MemberModel
+	for _, record := range mms.members {
+		if companyID != "" && record.CompanyID.String() != companyID {
+			continue
+		}
+		if userID != "" && record.UserID.String() != userID {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (mms MemberMemoryStore) Find(ctx context.Context, companyID string, userID string) (*MemberModel, error) {
+	for _, record := range mms.members {
+		if record.CompanyID.String() == companyID && record.UserID.String() == userID {
+			return &record, nil
+		}
+	}
+	return nil, errors.New("Member not found")
+}
+
+func (mms *MemberMemoryStore) Delete(ctx context.Context, companyID string, userID string) error {
+	for index, record := range mms.members {
+		if record.CompanyID.String() == companyID && record.UserID.String() == userID {
+			mms.members[index] = mms.members[len(mms.members)-1]
+			mms.members = mms.members[:len(mms.members)-1]
+			return nil
+		}
+	}
+	return errors.New("Member not found")
+}
+
+type APIKeyMemoryStore struct {
+	keys []APIKeyModel
+}
+
+func (kms *APIKeyMemoryStore) Save(ctx context.Context, model *APIKeyModel) error {
+	kms.keys = append(kms.keys, *model)
+	return nil
+}
+
+func (kms APIKeyMemoryStore) List(ctx context.Context, companyID string) ([]APIKeyModel, error) {
+	var records []APIKeyModel
+	for _, record := range kms.keys {
+		if record.CompanyID.String() == companyID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (kms APIKeyMemoryStore) FindByHash(ctx context.Context, hash string) (*APIKeyModel, error) {
+	for _, record := range kms.keys {
+		if record.KeyHash == hash {
+			return &record, nil
+		}
+	}
+	return nil, errors.New("API key not found")
+}
+
+func (kms *APIKeyMemoryStore) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	for index, record := range kms.keys {
+		if record.ID.String() == id {
+			kms.keys[index].LastUsedAt = &usedAt
+			return nil
+		}
+	}
+	return errors.New("API key not found")
+}
+
+func (kms *APIKeyMemoryStore) Delete(ctx context.Context, companyID string, id string) error {
+	for index, record := range kms.keys {
+		if record.CompanyID.String() == companyID && record.ID.String() == id {
+			kms.keys[index] = kms.keys[len(kms.keys)-1]
+			kms.keys = kms.keys[:len(kms.keys)-1]
+			return nil
+		}
+	}
+	return errors.New("API key not found")
+}
+
 type ProductMemoryStore struct {
 	products []ProductModel
+	// Events receives the events of changes, unless it is nil.
+	Events *EventMemoryStore
 }
 
-func (pms *ProductMemoryStore) Save(model *ProductModel) error {
+func (pms *ProductMemoryStore) Save(ctx context.Context, model *ProductModel) error {
 	for index, record := range pms.products {
 		if record.ID == model.ID {
 			if &model.ProductName != nil {
@@ -101,21 +266,27 @@ func (pms *ProductMemoryStore) Save(model *ProductModel) error {
 				pms.products[index].Rating = model.Rating
 			}
 			model = &pms.products[index]
-			return nil
+			return pms.Events.add(EventProductUpdated, model.CompanyID, productEventData(model))
 		}
 	}
 	pms.products = append(pms.products, *model)
-	return nil
+	return pms.Events.add(EventProductCreated, model.CompanyID, productEventData(model))
 }
 
-func (pms ProductMemoryStore) List(companyID string) ([]ProductModel, error) {
-	if len(pms.products) == 0 {
-		return pms.products, errors.New("No products found")
+func (pms ProductMemoryStore) List(ctx context.Context, companyID string) ([]ProductModel, error) {
+	var records []ProductModel
+	for _, record := range pms.products {
+		if companyID == "" || record.CompanyID.String() == companyID {
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		return records, errors.New("No products found")
 	}
-	return pms.products, nil
+	return records, nil
 }
 
-func (pms ProductMemoryStore) Find(id string) (*ProductModel, error) {
+func (pms ProductMemoryStore) Find(ctx context.Context, id string) (*ProductModel, error) {
 	for _, record := range pms.products {
 		if record.ID.String() == id {
 			return &record, nil
@@ -124,12 +295,12 @@ func (pms ProductMemoryStore) Find(id string) (*ProductModel, error) {
 	return nil, errors.New("Product not found")
 }
 
-func (pms *ProductMemoryStore) Delete(id string) error {
+func (pms *ProductMemoryStore) Delete(ctx context.Context, id string) error {
 	for index, record := range pms.products {
 		if record.ID.String() == id {
 			pms.products[index] = pms.products[len(pms.products)-1]
 			pms.products = pms.products[:len(pms.products)-1]
-			return nil
+			return pms.Events.add(EventProductDeleted, record.CompanyID, productEventData(&record))
 		}
 	}
 	return errors.New("Product not found")
@@ -137,9 +308,11 @@ func (pms *ProductMemoryStore) Delete(id string) error {
 
 type ReviewMemoryStore struct {
 	reviews []ReviewModel
+	// Events receives the events of changes, unless it is nil.
+	Events *EventMemoryStore
 }
 
-func (rms *ReviewMemoryStore) Save(model *ReviewModel) error {
+func (rms *ReviewMemoryStore) Save(ctx context.Context, model *ReviewModel) error {
 	for index, record := range rms.reviews {
 		if record.ID == model.ID {
 			if &model.Comment != nil {
@@ -149,21 +322,31 @@ func (rms *ReviewMemoryStore) Save(model *ReviewModel) error {
 				rms.reviews[index].Rating = model.Rating
 			}
 			model = &rms.reviews[index]
-			return nil
+			return rms.Events.add(EventReviewUpdated, model.CompanyID, reviewEventData(model))
 		}
 	}
 	rms.reviews = append(rms.reviews, *model)
-	return nil
+	return rms.Events.add(EventReviewCreated, model.CompanyID, reviewEventData(model))
 }
 
-func (rms ReviewMemoryStore) List(companyID string, productID string) ([]ReviewModel, error) {
-	if len(rms.reviews) == 0 {
-		return rms.reviews, errors.New("No reviews found")
+func (rms ReviewMemoryStore) List(ctx context.Context, companyID string, productID string) ([]ReviewModel, error) {
+	var records []ReviewModel
+	for _, record := range rms.reviews {
+		if companyID != "" && record.CompanyID.String() != companyID {
+			continue
+		}
+		if productID != "" && record.ProductID.String() != productID {
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return records, errors.New("No reviews found")
 	}
-	return rms.reviews, nil
+	return records, nil
 }
 
-func (rms ReviewMemoryStore) Find(id string) (*ReviewModel, error) {
+func (rms ReviewMemoryStore) Find(ctx context.Context, id string) (*ReviewModel, error) {
 	for _, record := range rms.reviews {
 		if record.ID.String() == id {
 			return &record, nil
@@ -172,13 +355,428 @@ func (rms ReviewMemoryStore) Find(id string) (*ReviewModel, error) {
 	return nil, errors.New("Review not found")
 }
 
-func (rms *ReviewMemoryStore) Delete(id string) error {
+func (rms *ReviewMemoryStore) Delete(ctx context.Context, id string) error {
 	for index, record := range rms.reviews {
 		if record.ID.String() == id {
 			rms.reviews[index] = rms.reviews[len(rms.reviews)-1]
 			rms.reviews = rms.reviews[:len(rms.reviews)-1]
-			return nil
+			return rms.Events.add(EventReviewDeleted, record.CompanyID, reviewEventData(&record))
 		}
 	}
 	return errors.New("Review not found")
 }
+
+// WebhookMemoryStore is safe for concurrent use, since deliveries are sent
+// in the background.
+type WebhookMemoryStore struct {
+	mu       sync.Mutex
+	webhooks []WebhookModel
+}
+
+func (wms *WebhookMemoryStore) Save(ctx context.Context, model *WebhookModel) error {
+	wms.mu.Lock()
+	defer wms.mu.Unlock()
+	wms.webhooks = append(wms.webhooks, *model)
+	return nil
+}
+
+func (wms *WebhookMemoryStore) List(ctx context.Context, companyID string) ([]WebhookModel, error) {
+	wms.mu.Lock()
+	defer wms.mu.Unlock()
+	var records []WebhookModel
+	for _, record := range wms.webhooks {
+		if record.CompanyID.String() == companyID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (wms *WebhookMemoryStore) Find(ctx context.Context, companyID string, id string) (*WebhookModel, error) {
+	wms.mu.Lock()
+	defer wms.mu.Unlock()
+	for _, record := range wms.webhooks {
+		if record.CompanyID.String() == companyID && record.ID.String() == id {
+			return &record, nil
+		}
+	}
+	return nil, errors.New("Webhook not found")
+}
+
+func (wms *WebhookMemoryStore) Delete(ctx context.Context, companyID string, id string) error {
+	wms.mu.Lock()
+	defer wms.mu.Unlock()
+	for index, record := range wms.webhooks {
+		if record.CompanyID.String() == companyID && record.ID.String() == id {
+			wms.webhooks = append(wms.webhooks[:index], wms.webhooks[index+1:]...)
+			return nil
+		}
+	}
+	return errors.New("Webhook not found")
+}
+
+// WebhookDeliveryMemoryStore is safe for concurrent use, since deliveries
+// are sent in the background.
+type WebhookDeliveryMemoryStore struct {
+	mu         sync.Mutex
+	deliveries []WebhookDeliveryModel
+}
+
+func (dms *WebhookDeliveryMemoryStore) Save(ctx context.Context, model *WebhookDeliveryModel) error {
+	dms.mu.Lock()
+	defer dms.mu.Unlock()
+	for index, record := range dms.deliveries {
+		if record.ID == model.ID {
+			dms.deliveries[index] = *model
+			return nil
+		}
+	}
+	dms.deliveries = append(dms.deliveries, *model)
+	return nil
+}
+
+func (dms *WebhookDeliveryMemoryStore) List(ctx context.Context, webhookID string) ([]WebhookDeliveryModel, error) {
+	dms.mu.Lock()
+	defer dms.mu.Unlock()
+	var records []WebhookDeliveryModel
+	for index := len(dms.deliveries) - 1; index >= 0; index-- {
+		if dms.deliveries[index].WebhookID.String() == webhookID {
+			records = append(records, dms.deliveries[index])
+		}
+	}
+	return records, nil
+}
+
+func (dms *WebhookDeliveryMemoryStore) Find(ctx context.Context, webhookID string, id string) (*WebhookDeliveryModel, error) {
+	dms.mu.Lock()
+	defer dms.mu.Unlock()
+	for _, record := range dms.deliveries {
+		if record.WebhookID.String() == webhookID && record.ID.String() == id {
+			return &record, nil
+		}
+	}
+	return nil, errors.New("Webhook delivery not found")
+}
+
+func (dms *WebhookDeliveryMemoryStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDeliveryModel, error) {
+	dms.mu.Lock()
+	defer dms.mu.Unlock()
+	var records []WebhookDeliveryModel
+	for index, record := range dms.deliveries {
+		if len(records) == limit {
+			break
+		}
+		if record.Status == DeliveryPending && !record.NextAttemptAt.After(now) {
+			dms.deliveries[index].NextAttemptAt = now.Add(lease)
+			records = append(records, dms.deliveries[index])
+		}
+	}
+	return records, nil
+}
+
+// EventMemoryStore is safe for concurrent use, since events are relayed in
+// the background.
+type EventMemoryStore struct {
+	mu         sync.Mutex
+	sequence   int64
+	queued     int64
+	events     []EventModel
+	deliveries []EventDeliveryModel
+}
+
+func (ems *EventMemoryStore) add(eventType string, companyID uuid.UUID, data interface{}) error {
+	if ems == nil {
+		return nil
+	}
+	event, err := newEvent(eventType, companyID, data)
+	if err != nil {
+		return err
+	}
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	ems.sequence++
+	event.Sequence = ems.sequence
+	ems.events = append(ems.events, *event)
+	return nil
+}
+
+func (ems *EventMemoryStore) Enqueue(ctx context.Context, sinks []string, limit int) (int, error) {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	n := 0
+	for _, record := range ems.events {
+		if record.Sequence <= ems.queued {
+			continue
+		}
+		if n == limit {
+			break
+		}
+		for _, sink := range sinks {
+			ems.deliveries = append(ems.deliveries, EventDeliveryModel{
+				Sequence:      record.Sequence,
+				Sink:          sink,
+				CompanyID:     record.CompanyID,
+				Status:        DeliveryPending,
+				NextAttemptAt: record.CreatedAt,
+			})
+		}
+		ems.queued = record.Sequence
+		n++
+	}
+	return n, nil
+}
+
+func (ems *EventMemoryStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]EventDeliveryModel, error) {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	var records []EventDeliveryModel
+	heads := map[string]bool{}
+	for index, record := range ems.deliveries {
+		if len(records) == limit {
+			break
+		}
+		key := record.Sink + "/" + record.CompanyID.String()
+		if record.Status != DeliveryPending || heads[key] {
+			continue
+		}
+		heads[key] = true
+		if record.NextAttemptAt.After(now) {
+			continue
+		}
+		ems.deliveries[index].NextAttemptAt = now.Add(lease)
+		claimed := ems.deliveries[index]
+		for _, event := range ems.events {
+			if event.Sequence == claimed.Sequence {
+				claimed.Event = event
+			}
+		}
+		records = append(records, claimed)
+	}
+	return records, nil
+}
+
+func (ems *EventMemoryStore) SaveDelivery(ctx context.Context, model *EventDeliveryModel) error {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	for index, record := range ems.deliveries {
+		if record.Sequence == model.Sequence && record.Sink == model.Sink {
+			saved := *model
+			saved.Event = EventModel{}
+			ems.deliveries[index] = saved
+			return nil
+		}
+	}
+	return errors.New("Event delivery not found")
+}
+
+func (ems *EventMemoryStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	pending := map[int64]bool{}
+	for _, record := range ems.deliveries {
+		if record.Status == DeliveryPending {
+			pending[record.Sequence] = true
+		}
+	}
+	removed := map[int64]bool{}
+	events := ems.events[:0]
+	for _, record := range ems.events {
+		if record.Sequence <= ems.queued && record.CreatedAt.Before(before) && !pending[record.Sequence] {
+			removed[record.Sequence] = true
+			continue
+		}
+		events = append(events, record)
+	}
+	ems.events = events
+	deliveries := ems.deliveries[:0]
+	for _, record := range ems.deliveries {
+		if !removed[record.Sequence] {
+			deliveries = append(deliveries, record)
+		}
+	}
+	ems.deliveries = deliveries
+	return len(removed), nil
+}
+
+func (ems *EventMemoryStore) List(ctx context.Context, companyID string, after int64, limit int) ([]EventModel, error) {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	var records []EventModel
+	for _, record := range ems.events {
+		if len(records) == limit {
+			break
+		}
+		if record.CompanyID.String() == companyID && record.Sequence > after {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (ems *EventMemoryStore) Last(ctx context.Context, companyID string) (int64, error) {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+	var sequence int64
+	for _, record := range ems.events {
+		if record.CompanyID.String() == companyID {
+			sequence = record.Sequence
+		}
+	}
+	return sequence, nil
+}
+
+// NotificationPreferenceMemoryStore is safe for concurrent use, since
+// digests are scheduled in the background.
+type NotificationPreferenceMemoryStore struct {
+	mu          sync.Mutex
+	preferences []NotificationPreferenceModel
+}
+
+func (nms *NotificationPreferenceMemoryStore) Save(ctx context.Context, model *NotificationPreferenceModel) error {
+	nms.mu.Lock()
+	defer nms.mu.Unlock()
+	for index, record := range nms.preferences {
+		if record.CompanyID == model.CompanyID {
+			nms.preferences[index] = *model
+			return nil
+		}
+	}
+	nms.preferences = append(nms.preferences, *model)
+	return nil
+}
+
+func (nms *NotificationPreferenceMemoryStore) Find(ctx context.Context, companyID string) (*NotificationPreferenceModel, error) {
+	nms.mu.Lock()
+	defer nms.mu.Unlock()
+	for _, record := range nms.preferences {
+		if record.CompanyID.String() == companyID {
+			return &record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (nms *NotificationPreferenceMemoryStore) ClaimDigests(ctx context.Context, now time.Time) ([]NotificationPreferenceModel, error) {
+	nms.mu.Lock()
+	defer nms.mu.Unlock()
+	var records []NotificationPreferenceModel
+	for index, record := range nms.preferences {
+		if record.Mode == NotifyDigest && !record.DigestedAt.After(now.Add(-digestPeriods[record.Frequency])) {
+			records = append(records, record)
+			nms.preferences[index].DigestedAt = now
+		}
+	}
+	return records, nil
+}
+
+// NotificationChannelMemoryStore is safe for concurrent use, since channels
+// are posted to in the background.
+type NotificationChannelMemoryStore struct {
+	mu       sync.Mutex
+	channels []NotificationChannelModel
+}
+
+func (cms *NotificationChannelMemoryStore) Save(ctx context.Context, model *NotificationChannelModel) error {
+	cms.mu.Lock()
+	defer cms.mu.Unlock()
+	cms.channels = append(cms.channels, *model)
+	return nil
+}
+
+func (cms *NotificationChannelMemoryStore) List(ctx context.Context, companyID string) ([]NotificationChannelModel, error) {
+	cms.mu.Lock()
+	defer cms.mu.Unlock()
+	var records []NotificationChannelModel
+	for _, record := range cms.channels {
+		if record.CompanyID.String() == companyID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (cms *NotificationChannelMemoryStore) Find(ctx context.Context, companyID string, id string) (*NotificationChannelModel, error) {
+	cms.mu.Lock()
+	defer cms.mu.Unlock()
+	for _, record := range cms.channels {
+		if record.CompanyID.String() == companyID && record.ID.String() == id {
+			return &record, nil
+		}
+	}
+	return nil, errors.New("Notification channel not found")
+}
+
+func (cms *NotificationChannelMemoryStore) Delete(ctx context.Context, companyID string, id string) error {
+	cms.mu.Lock()
+	defer cms.mu.Unlock()
+	for index, record := range cms.channels {
+		if record.CompanyID.String() == companyID && record.ID.String() == id {
+			cms.channels = append(cms.channels[:index], cms.channels[index+1:]...)
+			return nil
+		}
+	}
+	return errors.New("Notification channel not found")
+}
+
+// SentNotificationMemoryStore is safe for concurrent use, since events are
+// published in the background.
+type SentNotificationMemoryStore struct {
+	mu   sync.Mutex
+	sent []SentNotificationModel
+}
+
+func (sms *SentNotificationMemoryStore) Save(ctx context.Context, model *SentNotificationModel) error {
+	sms.mu.Lock()
+	defer sms.mu.Unlock()
+	sms.sent = append(sms.sent, *model)
+	return nil
+}
+
+func (sms *SentNotificationMemoryStore) Exists(ctx context.Context, eventID string, recipient string) (bool, error) {
+	sms.mu.Lock()
+	defer sms.mu.Unlock()
+	for _, record := range sms.sent {
+		if record.EventID.String() == eventID && record.Recipient == recipient {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type ChallengeMemoryStore struct {
+	mu         sync.Mutex
+	challenges map[string]*ChallengeModel
+}
+
+func (cms *ChallengeMemoryStore) Attempt(ctx context.Context, id string, expiresAt time.Time) (*ChallengeModel, error) {
+	cms.mu.Lock()
+	defer cms.mu.Unlock()
+	if cms.challenges == nil {
+		cms.challenges = map[string]*ChallengeModel{}
+	}
+	now := time.Now()
+	for key, record := range cms.challenges {
+		if record.ExpiresAt.Before(now) {
+			delete(cms.challenges, key)
+		}
+	}
+	record, ok := cms.challenges[id]
+	if !ok {
+		record = &ChallengeModel{ID: id, ExpiresAt: expiresAt}
+		cms.challenges[id] = record
+	}
+	record.Attempts++
+	model := *record
+	return &model, nil
+}
+
+func (cms *ChallengeMemoryStore) Use(ctx context.Context, id string) (bool, error) {
+	cms.mu.Lock()
+	defer cms.mu.Unlock()
+	record, ok := cms.challenges[id]
+	if !ok || record.Used {
+		return false, nil
+	}
+	record.Used = true
+	return true, nil
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,10 +18,10 @@ func TestUserMemorySave(t *testing.T) {
 		CreatedAt:    time.Now(),
 	}
 	storage := new(UserMemoryStore)
-	if err := storage.Save(um); err != nil {
+	if err := storage.Save(context.Background(), um); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err != nil {
+	if _, err := storage.Find(context.Background(), id); err != nil {
 		t.Errorf("Error: %s", err.Error())
 	}
 }
@@ -33,10 +35,10 @@ func TestUserMemoryFind(t *testing.T) {
 		CreatedAt:    time.Now(),
 	}
 	storage := new(UserMemoryStore)
-	if err := storage.Save(um); err != nil {
+	if err := storage.Save(context.Background(), um); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := storage.Find(id)
+	record, err := storage.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -45,6 +47,31 @@ func TestUserMemoryFind(t *testing.T) {
 	}
 }
 
+func TestUserMemoryUpdate(t *testing.T) {
+	id := uuid.NewV4().String()
+	um := &UserModel{
+		ID:           uuid.FromStringOrNil(id),
+		Email:        "user@domain.com",
+		UserPassword: "password",
+		CreatedAt:    time.Now(),
+	}
+	storage := new(UserMemoryStore)
+	if err := storage.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	um.EmailVerified = true
+	if err := storage.Update(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	record, err := storage.Find(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !record.EmailVerified {
+		t.Errorf("Error: %s", "Record was not updated")
+	}
+}
+
 func TestUserMemoryDelete(t *testing.T) {
 	id := uuid.NewV4().String()
 	um := &UserModel{
@@ -54,32 +81,63 @@ func TestUserMemoryDelete(t *testing.T) {
 		CreatedAt:    time.Now(),
 	}
 	storage := new(UserMemoryStore)
-	if err := storage.Save(um); err != nil {
+	if err := storage.Save(context.Background(), um); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if err := storage.Delete(id); err != nil {
+	if err := storage.Delete(context.Background(), id); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err == nil {
+	if _, err := storage.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", err.Error())
 	}
 }
 
+func TestUserMemoryUseTOTPStep(t *testing.T) {
+	um := &UserModel{
+		ID:           uuid.NewV4(),
+		Email:        "user@domain.com",
+		UserPassword: "password",
+		CreatedAt:    time.Now(),
+	}
+	storage := new(UserMemoryStore)
+	if err := storage.Save(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	steps := []struct {
+		step     int64
+		accepted bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}}
+	for _, s := range steps {
+		ok, err := storage.UseTOTPStep(context.Background(), um.ID.String(), s.step)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if ok != s.accepted {
+			t.Errorf("Error: Step %d accepted: %t", s.step, ok)
+		}
+	}
+	if err := storage.Update(context.Background(), um); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if ok, _ := storage.UseTOTPStep(context.Background(), um.ID.String(), 101); ok {
+		t.Error("Error: Update moved the last step back")
+	}
+}
+
 func TestCompanyMemorySave(t *testing.T) {
 	id := uuid.NewV4().String()
 	cm := &CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
 	}
 	storage := new(CompanyMemoryStore)
-	if err := storage.Save(cm); err != nil {
+	if err := storage.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err != nil {
+	if _, err := storage.Find(context.Background(), id); err != nil {
 		t.Errorf("Error: %s", err.Error())
 	}
 }
@@ -87,18 +145,17 @@ func TestCompanyMemorySave(t *testing.T) {
 func TestCompanyMemoryList(t *testing.T) {
 	id := uuid.NewV4().String()
 	cm := &CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
 	}
 	storage := new(CompanyMemoryStore)
-	if err := storage.Save(cm); err != nil {
+	if err := storage.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	cms, err := storage.List()
+	cms, err := storage.List(context.Background())
 	if err != nil {
 		t.Fatalf("Error: %s", err)
 	}
@@ -110,18 +167,17 @@ func TestCompanyMemoryList(t *testing.T) {
 func TestCompanyMemoryFind(t *testing.T) {
 	id := uuid.NewV4().String()
 	cm := &CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
 	}
 	storage := new(CompanyMemoryStore)
-	if err := storage.Save(cm); err != nil {
+	if err := storage.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := storage.Find(id)
+	record, err := storage.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -133,21 +189,20 @@ func TestCompanyMemoryFind(t *testing.T) {
 func TestCompanyMemoryDelete(t *testing.T) {
 	id := uuid.NewV4().String()
 	cm := &CompanyModel{
-		ID:            uuid.FromStringOrNil(id),
-		CompanyUserID: uuid.NewV4().String(),
-		CompanyName:   "Company One",
-		Email:         "company@domain.com",
-		Logo:          "https://proddx.com/company-one/logo.png",
-		CreatedAt:     time.Now(),
+		ID:          uuid.FromStringOrNil(id),
+		CompanyName: "Company One",
+		Email:       "company@domain.com",
+		Logo:        "https://proddx.com/company-one/logo.png",
+		CreatedAt:   time.Now(),
 	}
 	storage := new(CompanyMemoryStore)
-	if err := storage.Save(cm); err != nil {
+	if err := storage.Save(context.Background(), cm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if err := storage.Delete(id); err != nil {
+	if err := storage.Delete(context.Background(), id); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err == nil {
+	if _, err := storage.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", "Record was not deleted")
 	}
 }
@@ -163,10 +218,10 @@ func TestProductMemorySave(t *testing.T) {
 		CreatedAt:   time.Now(),
 	}
 	storage := new(ProductMemoryStore)
-	if err := storage.Save(pm); err != nil {
+	if err := storage.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err != nil {
+	if _, err := storage.Find(context.Background(), id); err != nil {
 		t.Errorf("Error: %s", err.Error())
 	}
 }
@@ -182,10 +237,10 @@ func TestProductMemoryList(t *testing.T) {
 		CreatedAt:   time.Now(),
 	}
 	storage := new(ProductMemoryStore)
-	if err := storage.Save(pm); err != nil {
+	if err := storage.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	records, err := storage.List("")
+	records, err := storage.List(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -205,10 +260,10 @@ func TestProductMemoryFind(t *testing.T) {
 		CreatedAt:   time.Now(),
 	}
 	storage := new(ProductMemoryStore)
-	if err := storage.Save(pm); err != nil {
+	if err := storage.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := storage.Find(id)
+	record, err := storage.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -229,13 +284,13 @@ func TestProductMemoryDelete(t *testing.T) {
 		CreatedAt:   time.Now(),
 	}
 	storage := new(ProductMemoryStore)
-	if err := storage.Save(pm); err != nil {
+	if err := storage.Save(context.Background(), pm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if err := storage.Delete(id); err != nil {
+	if err := storage.Delete(context.Background(), id); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err == nil {
+	if _, err := storage.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", "Record not deleted")
 	}
 }
@@ -251,10 +306,10 @@ func TestReviewMemorySave(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 	storage := new(ReviewMemoryStore)
-	if err := storage.Save(rm); err != nil {
+	if err := storage.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err != nil {
+	if _, err := storage.Find(context.Background(), id); err != nil {
 		t.Errorf("Error: %s", err.Error())
 	}
 }
@@ -270,10 +325,10 @@ func TestReviewMemoryList(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 	storage := new(ReviewMemoryStore)
-	if err := storage.Save(rm); err != nil {
+	if err := storage.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	records, err := storage.List("", "")
+	records, err := storage.List(context.Background(), "", "")
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -293,10 +348,10 @@ func TestReviewMemoryFind(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 	storage := new(ReviewMemoryStore)
-	if err := storage.Save(rm); err != nil {
+	if err := storage.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	record, err := storage.Find(id)
+	record, err := storage.Find(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -316,13 +371,456 @@ func TestReviewMemoryDelete(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 	storage := new(ReviewMemoryStore)
-	if err := storage.Save(rm); err != nil {
+	if err := storage.Save(context.Background(), rm); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if err := storage.Delete(id); err != nil {
+	if err := storage.Delete(context.Background(), id); err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
-	if _, err := storage.Find(id); err == nil {
+	if _, err := storage.Find(context.Background(), id); err == nil {
 		t.Errorf("Error: %s", "Record not deleted")
 	}
 }
+
+func TestMemberMemorySave(t *testing.T) {
+	companyID := uuid.NewV4()
+	mm := &MemberModel{
+		CompanyID: companyID,
+		UserID:    uuid.NewV4(),
+		Role:      "viewer",
+		CreatedAt: time.Now(),
+	}
+	storage := new(MemberMemoryStore)
+	if err := storage.Save(context.Background(), mm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	mm.Role = "admin"
+	if err := storage.Save(context.Background(), mm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	record, err := storage.Find(context.Background(), companyID.String(), mm.UserID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.Role != "admin" {
+		t.Errorf("Error: %s: %s - %s", "Role inconsistency", record.Role, "admin")
+	}
+}
+
+func TestMemberMemoryList(t *testing.T) {
+	companyID := uuid.NewV4()
+	userID := uuid.NewV4()
+	storage := new(MemberMemoryStore)
+	for _, mm := range []*MemberModel{
+		{CompanyID: companyID, UserID: userID, Role: "owner", CreatedAt: time.Now()},
+		{CompanyID: companyID, UserID: uuid.NewV4(), Role: "viewer", CreatedAt: time.Now()},
+		{CompanyID: uuid.NewV4(), UserID: userID, Role: "analyst", CreatedAt: time.Now()},
+	} {
+		if err := storage.Save(context.Background(), mm); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	mms, err := storage.List(context.Background(), companyID.String(), "")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(mms) != 2 {
+		t.Errorf("Error: %s - %d", "Wrong number of records", len(mms))
+	}
+	mms, err = storage.List(context.Background(), "", userID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(mms) != 2 {
+		t.Errorf("Error: %s - %d", "Wrong number of records", len(mms))
+	}
+}
+
+func TestMemberMemoryDelete(t *testing.T) {
+	mm := &MemberModel{
+		CompanyID: uuid.NewV4(),
+		UserID:    uuid.NewV4(),
+		Role:      "viewer",
+		CreatedAt: time.Now(),
+	}
+	storage := new(MemberMemoryStore)
+	if err := storage.Save(context.Background(), mm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := storage.Delete(context.Background(), mm.CompanyID.String(), mm.UserID.String()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err := storage.Find(context.Background(), mm.CompanyID.String(), mm.UserID.String()); err == nil {
+		t.Errorf("Error: %s", "Record was not deleted")
+	}
+}
+
+func TestAPIKeyMemoryTouch(t *testing.T) {
+	km := &APIKeyModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		KeyName:   "Backend",
+		KeyPrefix: "pdx_abcdefgh",
+		KeyHash:   "hash",
+		Scope:     "read-only",
+		CreatedAt: time.Now(),
+	}
+	storage := new(APIKeyMemoryStore)
+	if err := storage.Save(context.Background(), km); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := storage.Touch(context.Background(), km.ID.String(), time.Now()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	record, err := storage.FindByHash(context.Background(), "hash")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if record.LastUsedAt == nil {
+		t.Errorf("Error: %s", "Record was not updated")
+	}
+}
+
+func TestAPIKeyMemoryDelete(t *testing.T) {
+	km := &APIKeyModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		KeyName:   "Backend",
+		KeyPrefix: "pdx_abcdefgh",
+		KeyHash:   "hash",
+		Scope:     "read-only",
+		CreatedAt: time.Now(),
+	}
+	storage := new(APIKeyMemoryStore)
+	if err := storage.Save(context.Background(), km); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := storage.Delete(context.Background(), uuid.NewV4().String(), km.ID.String()); err == nil {
+		t.Errorf("Error: %s", "Record of another company was deleted")
+	}
+	if err := storage.Delete(context.Background(), km.CompanyID.String(), km.ID.String()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err := storage.FindByHash(context.Background(), "hash"); err == nil {
+		t.Errorf("Error: %s", "Record was not deleted")
+	}
+}
+
+func TestWebhookMemoryDelete(t *testing.T) {
+	wm := &WebhookModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		URL:       "https://crm.domain.com/hooks/proddx",
+		Secret:    "whsec_secret",
+		Events:    []string{"review.created"},
+		CreatedAt: time.Now(),
+	}
+	storage := new(WebhookMemoryStore)
+	if err := storage.Save(context.Background(), wm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := storage.Delete(context.Background(), uuid.NewV4().String(), wm.ID.String()); err == nil {
+		t.Errorf("Error: %s", "Record of another company was deleted")
+	}
+	if err := storage.Delete(context.Background(), wm.CompanyID.String(), wm.ID.String()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err := storage.Find(context.Background(), wm.CompanyID.String(), wm.ID.String()); err == nil {
+		t.Errorf("Error: %s", "Record was not deleted")
+	}
+}
+
+func TestWebhookDeliveryMemoryClaim(t *testing.T) {
+	now := time.Now()
+	storage := new(WebhookDeliveryMemoryStore)
+	for _, status := range []string{DeliveryPending, DeliveryPending, DeliverySucceeded} {
+		dm := &WebhookDeliveryModel{
+			ID:            uuid.NewV4(),
+			WebhookID:     uuid.NewV4(),
+			CompanyID:     uuid.NewV4(),
+			Event:         "review.created",
+			Payload:       []byte("{}"),
+			Status:        status,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		if err := storage.Save(context.Background(), dm); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	claimed, err := storage.Claim(context.Background(), now, time.Minute, 1)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(claimed) != 1 {
+		t.Fatalf("Error: %s - %d", "Wrong number of records", len(claimed))
+	}
+	claimed, _ = storage.Claim(context.Background(), now, time.Minute, 10)
+	if len(claimed) != 1 {
+		t.Errorf("Error: %s - %d", "Wrong number of records", len(claimed))
+	}
+	claimed, _ = storage.Claim(context.Background(), now, time.Minute, 10)
+	if len(claimed) != 0 {
+		t.Errorf("Error: %s - %d", "Claimed deliveries were claimed again", len(claimed))
+	}
+	claimed, _ = storage.Claim(context.Background(), now.Add(time.Minute), time.Minute, 10)
+	if len(claimed) != 2 {
+		t.Errorf("Error: %s - %d", "Expired leases were not claimed again", len(claimed))
+	}
+}
+
+func TestNotificationPreferenceMemoryClaimDigests(t *testing.T) {
+	now := time.Now()
+	storage := new(NotificationPreferenceMemoryStore)
+	for _, nm := range []NotificationPreferenceModel{
+		{CompanyID: uuid.NewV4(), Mode: NotifyDigest, Frequency: DigestDaily, DigestedAt: now.Add(-25 * time.Hour)},
+		{CompanyID: uuid.NewV4(), Mode: NotifyDigest, Frequency: DigestWeekly, DigestedAt: now.Add(-25 * time.Hour)},
+		{CompanyID: uuid.NewV4(), Mode: NotifyEvery, Frequency: DigestDaily, DigestedAt: now.Add(-25 * time.Hour)},
+	} {
+		if err := storage.Save(context.Background(), &nm); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	claimed, err := storage.ClaimDigests(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(claimed) != 1 || claimed[0].Frequency != DigestDaily || !claimed[0].DigestedAt.Equal(now.Add(-25*time.Hour)) {
+		t.Fatalf("Error: %s - %v", "Wrong records claimed", claimed)
+	}
+	record, err := storage.Find(context.Background(), claimed[0].CompanyID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !record.DigestedAt.Equal(now) {
+		t.Errorf("Error: %s - %v", "Claimed digest was not moved", record.DigestedAt)
+	}
+	claimed, _ = storage.ClaimDigests(context.Background(), now)
+	if len(claimed) != 0 {
+		t.Errorf("Error: %s - %d", "Claimed digests were claimed again", len(claimed))
+	}
+	claimed, _ = storage.ClaimDigests(context.Background(), now.Add(7*24*time.Hour))
+	if len(claimed) != 2 {
+		t.Errorf("Error: %s - %d", "Due digests were not claimed", len(claimed))
+	}
+	if record, err = storage.Find(context.Background(), uuid.NewV4().String()); record != nil || err != nil {
+		t.Errorf("Error: %s - %v", "Expected no preferences", record)
+	}
+}
+
+func TestNotificationChannelMemoryDelete(t *testing.T) {
+	cm := &NotificationChannelModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		URL:       "https://hooks.slack.com/services/T000/B000/XXXX",
+		Format:    ChannelSlack,
+		CreatedAt: time.Now(),
+	}
+	storage := new(NotificationChannelMemoryStore)
+	if err := storage.Save(context.Background(), cm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	records, err := storage.List(context.Background(), cm.CompanyID.String())
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(records) != 1 || records[0].Format != ChannelSlack {
+		t.Errorf("Error: %s - %v", "Wrong records", records)
+	}
+	if err := storage.Delete(context.Background(), uuid.NewV4().String(), cm.ID.String()); err == nil {
+		t.Errorf("Error: %s", "Record of another company was deleted")
+	}
+	if err := storage.Delete(context.Background(), cm.CompanyID.String(), cm.ID.String()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err := storage.Find(context.Background(), cm.CompanyID.String(), cm.ID.String()); err == nil {
+		t.Errorf("Error: %s", "Record was not deleted")
+	}
+}
+
+func TestReviewMemoryEvents(t *testing.T) {
+	rm := &ReviewModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		ProductID: uuid.NewV4(),
+		Comment:   "Lorem ipsum dolor sit amet",
+		Rating:    3,
+		CreatedAt: time.Now(),
+	}
+	events := new(EventMemoryStore)
+	storage := &ReviewMemoryStore{Events: events}
+	if err := storage.Save(context.Background(), rm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	rm.Rating = 4
+	if err := storage.Save(context.Background(), rm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := storage.Delete(context.Background(), rm.ID.String()); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	n, err := events.Enqueue(context.Background(), []string{"sink"}, 10)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if n != 3 {
+		t.Fatalf("Error: Expected three events to be queued: %d", n)
+	}
+	if n, _ = events.Enqueue(context.Background(), []string{"sink"}, 10); n != 0 {
+		t.Errorf("Error: Expected events to be queued once: %d", n)
+	}
+	var types []string
+	for {
+		claimed, err := events.Claim(context.Background(), time.Now(), time.Minute, 10)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		if len(claimed) != 1 {
+			t.Fatalf("Error: Expected one delivery of the company at a time: %d", len(claimed))
+		}
+		types = append(types, claimed[0].Event.EventType)
+		claimed[0].Status = DeliverySucceeded
+		if err = events.SaveDelivery(context.Background(), &claimed[0]); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if strings.Join(types, ",") != "review.created,review.updated,review.deleted" {
+		t.Errorf("Error: Unexpected events: %v", types)
+	}
+}
+
+func TestEventMemoryClaim(t *testing.T) {
+	events := new(EventMemoryStore)
+	blocked, other := uuid.NewV4(), uuid.NewV4()
+	for _, companyID := range []uuid.UUID{blocked, blocked, other} {
+		if err := events.add(EventProductCreated, companyID, nil); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if _, err := events.Enqueue(context.Background(), []string{"a", "b"}, 10); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	now := time.Now()
+	claimed, _ := events.Claim(context.Background(), now, time.Minute, 10)
+	if len(claimed) != 4 {
+		t.Fatalf("Error: Expected the first event of each company for each sink: %d", len(claimed))
+	}
+	// The claimed deliveries hold up the later events of their companies.
+	if claimed, _ = events.Claim(context.Background(), now, time.Minute, 10); len(claimed) != 0 {
+		t.Errorf("Error: Expected nothing to be due while deliveries are claimed: %v", claimed)
+	}
+	if claimed, _ = events.Claim(context.Background(), now.Add(time.Minute), time.Minute, 10); len(claimed) != 4 {
+		t.Errorf("Error: Expected expired claims to be due again: %d", len(claimed))
+	}
+
+	// Events are kept until none of their deliveries is pending.
+	if n, _ := events.Prune(context.Background(), now.Add(time.Hour)); n != 0 {
+		t.Errorf("Error: Expected events with pending deliveries to be kept: %d", n)
+	}
+	for i := range claimed {
+		claimed[i].Status = DeliverySucceeded
+		if i == 0 {
+			claimed[i].Status = DeliveryFailed
+		}
+		if err := events.SaveDelivery(context.Background(), &claimed[i]); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if n, _ := events.Prune(context.Background(), now.Add(time.Hour)); n != 2 {
+		t.Errorf("Error: Expected the delivered events to be pruned: %d", n)
+	}
+	if claimed, _ = events.Claim(context.Background(), now.Add(time.Hour), time.Minute, 10); len(claimed) != 2 {
+		t.Errorf("Error: Expected the deliveries of the remaining event to be kept: %d", len(claimed))
+	}
+}
+
+func TestChallengeMemoryAttempt(t *testing.T) {
+	storage := new(ChallengeMemoryStore)
+	expiresAt := time.Now().Add(time.Minute)
+	for i := 1; i <= 3; i++ {
+		record, err := storage.Attempt(context.Background(), "challenge", expiresAt)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if record.Attempts != i || record.Used {
+			t.Errorf("Error: Attempt inconsistency: %+v", record)
+		}
+	}
+	if ok, _ := storage.Use(context.Background(), "challenge"); !ok {
+		t.Error("Error: Challenge was not used")
+	}
+	if ok, _ := storage.Use(context.Background(), "challenge"); ok {
+		t.Error("Error: Challenge was used twice")
+	}
+	record, err := storage.Attempt(context.Background(), "challenge", expiresAt)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !record.Used {
+		t.Error("Error: Used challenge attempted")
+	}
+}
+
+func TestEventMemoryList(t *testing.T) {
+	events := new(EventMemoryStore)
+	companyID := uuid.NewV4()
+	for _, id := range []uuid.UUID{companyID, uuid.NewV4(), companyID} {
+		if err := events.add(EventReviewCreated, id, nil); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if last, _ := events.Last(context.Background(), companyID.String()); last != 3 {
+		t.Errorf("Error: Unexpected last event: %d", last)
+	}
+	records, err := events.List(context.Background(), companyID.String(), 1, 10)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(records) != 1 || records[0].Sequence != 3 {
+		t.Errorf("Error: Expected the later event of the company: %v", records)
+	}
+}
+
+func TestSentNotificationMemoryExists(t *testing.T) {
+	sm := &SentNotificationModel{EventID: uuid.NewV4(), Recipient: "owner@domain.com", SentAt: time.Now()}
+	storage := new(SentNotificationMemoryStore)
+	if err := storage.Save(context.Background(), sm); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if exists, _ := storage.Exists(context.Background(), sm.EventID.String(), sm.Recipient); !exists {
+		t.Error("Error: Expected the notification to be sent")
+	}
+	if exists, _ := storage.Exists(context.Background(), sm.EventID.String(), "admin@domain.com"); exists {
+		t.Error("Error: Expected the notification not to be sent to other addresses")
+	}
+}
+
+func TestUserMemoryDeleteAccount(t *testing.T) {
+	companies := new(CompanyMemoryStore)
+	members := new(MemberMemoryStore)
+	storage := &UserMemoryStore{Companies: companies, Members: members}
+	um := &UserModel{ID: uuid.NewV4(), Email: "owner@domain.com", CreatedAt: time.Now()}
+	cm := &CompanyModel{ID: uuid.NewV4(), CompanyName: "Company One", CreatedAt: time.Now()}
+	storage.Save(context.Background(), um)
+	companies.Save(context.Background(), cm)
+	members.Save(context.Background(), &MemberModel{CompanyID: cm.ID, UserID: um.ID, Role: "owner", CreatedAt: time.Now()})
+	other := &MemberModel{CompanyID: cm.ID, UserID: uuid.NewV4(), Role: "viewer", CreatedAt: time.Now()}
+	members.Save(context.Background(), other)
+
+	if err := storage.DeleteAccount(context.Background(), um.ID.String(), []string{cm.ID.String()}); err != ErrMembersRemain {
+		t.Errorf("Error: Expected a company with other members to be kept: %v", err)
+	}
+	members.Delete(context.Background(), cm.ID.String(), other.UserID.String())
+	if err := storage.DeleteAccount(context.Background(), um.ID.String(), []string{cm.ID.String()}); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err := companies.Find(context.Background(), cm.ID.String()); err == nil {
+		t.Error("Error: Company was not deleted")
+	}
+	if records, _ := members.List(context.Background(), "", um.ID.String()); len(records) != 0 {
+		t.Errorf("Error: Memberships were not deleted: %v", records)
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "api.proddx.com/storage"
+
+// querier runs queries, like *pgxpool.Pool.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// tracedQuerier runs every query in a span that is a child of the span in
+// the query's context, such as the span of the request being handled.
+type tracedQuerier struct {
+	q querier
+}
+
+func traced(q querier) tracedQuerier {
+	return tracedQuerier{q: q}
+}
+
+func (tq tracedQuerier) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startQuery(ctx, sql)
+	tag, err := tq.q.Exec(ctx, sql, args...)
+	endQuery(span, err)
+	return tag, err
+}
+
+func (tq tracedQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startQuery(ctx, sql)
+	rows, err := tq.q.Query(ctx, sql, args...)
+	if err != nil {
+		endQuery(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (tq tracedQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, span := startQuery(ctx, sql)
+	return tracedRow{row: tq.q.QueryRow(ctx, sql, args...), span: span}
+}
+
+// tracedRows ends the span of its query once all rows have been read or
+// the rows are closed.
+type tracedRows struct {
+	pgx.Rows
+	span  trace.Span
+	ended bool
+}
+
+func (tr *tracedRows) Next() bool {
+	if tr.Rows.Next() {
+		return true
+	}
+	tr.end()
+	return false
+}
+
+func (tr *tracedRows) Close() {
+	tr.Rows.Close()
+	tr.end()
+}
+
+func (tr *tracedRows) end() {
+	if !tr.ended {
+		tr.ended = true
+		endQuery(tr.span, tr.Rows.Err())
+	}
+}
+
+// tracedRow ends the span of its query once the row has been scanned.
+type tracedRow struct {
+	row  pgx.Row
+	span trace.Span
+}
+
+func (tr tracedRow) Scan(dest ...interface{}) error {
+	err := tr.row.Scan(dest...)
+	endQuery(tr.span, err)
+	return err
+}
+
+// startQuery starts the span of a query, named after its operation and
+// table such as "select reviews".
+func startQuery(ctx context.Context, sql string) (context.Context, trace.Span) {
+	operation, table := describeQuery(sql)
+	tracer := otel.GetTracerProvider().Tracer(instrumentationName)
+	return tracer.Start(ctx, operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatementKey.String(sql),
+			semconv.DBOperationKey.String(operation),
+			semconv.DBSQLTableKey.String(table),
+		))
+}
+
+// endQuery ends the span of a query, marking it failed on errors other than
+// finding no rows.
+func endQuery(span trace.Span, err error) {
+	if err != nil && err != pgx.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// describeQuery returns the operation of a SQL statement and the table it
+// operates on.
+func describeQuery(sql string) (operation, table string) {
+	words := strings.Fields(sql)
+	if len(words) == 0 {
+		return "", ""
+	}
+	operation = strings.ToLower(words[0])
+	keyword := "from"
+	switch operation {
+	case "insert":
+		keyword = "into"
+	case "update":
+		keyword = "update"
+	}
+	for i, word := range words[:len(words)-1] {
+		if strings.ToLower(word) == keyword {
+			table = strings.SplitN(words[i+1], "(", 2)[0]
+			break
+		}
+	}
+	return operation, table
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+type fakeQuerier struct {
+	err error
+}
+
+func (fq fakeQuerier) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag("DELETE 1"), fq.err
+}
+
+func (fq fakeQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return &fakeRows{remaining: 2}, fq.err
+}
+
+func (fq fakeQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &fakeRows{err: fq.err}
+}
+
+type fakeRows struct {
+	remaining int
+	err       error
+}
+
+func (fr *fakeRows) Close()                                         {}
+func (fr *fakeRows) Err() error                                     { return fr.err }
+func (fr *fakeRows) CommandTag() pgconn.CommandTag                  { return nil }
+func (fr *fakeRows) FieldDescriptions() []pgproto3.FieldDescription { return nil }
+func (fr *fakeRows) Scan(dest ...interface{}) error                 { return fr.err }
+func (fr *fakeRows) Values() ([]interface{}, error)                 { return nil, fr.err }
+func (fr *fakeRows) RawValues() [][]byte                            { return nil }
+func (fr *fakeRows) Next() bool {
+	fr.remaining--
+	return fr.remaining >= 0
+}
+
+func TestTracedQueries(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "Request")
+	q := traced(fakeQuerier{})
+	if _, err := q.Exec(ctx, "delete from reviews where id=$1", "id"); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	rows, err := q.Query(ctx, "select * from products where company_id=$1", "id")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	for rows.Next() {
+	}
+	if len(recorder.Ended()) != 2 {
+		t.Fatalf("Error: Expected the span to end once the rows are read")
+	}
+	rows.Close()
+	if err := q.QueryRow(ctx, "insert into users(id, email) values($1, $2)", "id", "email").Scan(); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	names := []string{"delete reviews", "select products", "insert users", "Request"}
+	if len(spans) != len(names) {
+		t.Fatalf("Error: Expected %d spans, got %d", len(names), len(spans))
+	}
+	for i, span := range spans[:3] {
+		if span.Name() != names[i] {
+			t.Errorf("Error: Expected span %q, got %q", names[i], span.Name())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Error: Expected %q to be a child of the request span", span.Name())
+		}
+		attrs := map[string]string{}
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		if attrs[string(semconv.DBSystemKey)] != "postgresql" || attrs[string(semconv.DBStatementKey)] == "" {
+			t.Errorf("Error: Unexpected attributes of %q: %v", span.Name(), attrs)
+		}
+	}
+
+	recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	failing := traced(fakeQuerier{err: errors.New("connection reset")})
+	failing.Exec(context.Background(), "update reviews set comment=$2 where id=$1")
+	traced(fakeQuerier{err: pgx.ErrNoRows}).QueryRow(context.Background(), "select * from reviews where id=$1").Scan()
+	spans = recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "update reviews" || spans[0].Status().Code != codes.Error {
+		t.Errorf("Error: Expected the failed query to be marked as an error")
+	}
+	if len(spans) == 2 && spans[1].Status().Code == codes.Error {
+		t.Errorf("Error: Expected a missing row not to be an error")
+	}
+}
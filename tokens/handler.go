@@ -1,13 +1,39 @@
 package tokens
 
-import "net/http"
+import (
+	"context"
+	"net/http"
 
-func Validation(next http.Handler) http.Handler {
+	"api.proddx.com/logging"
+	"api.proddx.com/problem"
+)
+
+// StreamValidation authenticates requests with a token issued by NewStream
+// in the token query parameter. Handlers must check that the company they
+// stream is StreamCompanyID.
+func (i *Issuer) StreamValidation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := i.ParseStream(r.URL.Query().Get("token"))
+		if err != nil {
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "A valid stream token is required")
+			return
+		}
+		logging.AddFields(r.Context(), "user_id", stream.UserID)
+		ctx := context.WithValue(r.Context(), userIDKey, stream.UserID)
+		ctx = context.WithValue(ctx, streamCompanyIDKey, stream.CompanyID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (i *Issuer) Validation(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := tokenValid(r); err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		id, err := i.verifyToken(r)
+		if err != nil {
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "A valid bearer token is required")
 			return
 		}
-		next.ServeHTTP(w, r)
+		logging.AddFields(r.Context(), "user_id", id)
+		ctx := context.WithValue(r.Context(), userIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -1,20 +1,342 @@
 package tokens
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func New(id string) (string, error) {
+// issuerName is the iss claim of every token, so that tokens issued by other
+// services sharing a key are not accepted as sessions.
+const issuerName = "api.proddx.com"
+
+const (
+	sessionTTL      = 24 * time.Hour
+	verificationTTL = 24 * time.Hour
+	challengeTTL    = 5 * time.Minute
+	invitationTTL   = 7 * 24 * time.Hour
+	oidcStateTTL    = 10 * time.Minute
+	streamTTL       = 15 * time.Minute
+)
+
+const (
+	purposeVerifyEmail = "verify_email"
+	purposeTwoFactor   = "two_factor"
+	purposeInvitation  = "invitation"
+	purposeOIDCState   = "oidc_state"
+	purposeStream      = "stream"
+)
+
+type contextKey string
+
+const (
+	userIDKey          contextKey = "user_id"
+	streamCompanyIDKey contextKey = "stream_company_id"
+)
+
+// Issuer signs tokens with its signing key and verifies tokens signed by any
+// of its keys, which lets a new key be introduced before the previous one is
+// retired.
+type Issuer struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewIssuer returns an Issuer signing with signing and also accepting tokens
+// signed by others.
+func NewIssuer(signing *Key, others ...*Key) (*Issuer, error) {
+	if signing == nil || signing.Private == nil {
+		return nil, errors.New("A private signing key is required")
+	}
+	i := &Issuer{signing: signing, keys: map[string]*Key{signing.ID: signing}}
+	for _, key := range others {
+		if existing, ok := i.keys[key.ID]; ok && existing != key {
+			return nil, fmt.Errorf("Duplicate key ID %s", key.ID)
+		}
+		i.keys[key.ID] = key
+	}
+	return i, nil
+}
+
+// LoadIssuer loads every key in dir and signs with the key named signingID.
+func LoadIssuer(dir, signingID string) (*Issuer, error) {
+	keys, err := LoadKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+	for index, key := range keys {
+		if key.ID == signingID {
+			others := append(keys[:index:index], keys[index+1:]...)
+			return NewIssuer(key, others...)
+		}
+	}
+	return nil, fmt.Errorf("Signing key %s not found in %s", signingID, dir)
+}
+
+// New issues a session token for the user identified by id, which expires
+// after sessionTTL.
+func (i *Issuer) New(id string) (string, error) {
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{}
+	tokenClaims["id"] = id
+	tokenClaims["iat"] = now.Unix()
+	tokenClaims["exp"] = now.Add(sessionTTL).Unix()
+	return i.sign(tokenClaims)
+}
+
+// NewVerification issues a token proving ownership of email for the user
+// identified by id. It cannot be used to authenticate requests.
+func (i *Issuer) NewVerification(id, email string) (string, error) {
+	tokenClaims := jwt.MapClaims{}
+	tokenClaims["id"] = id
+	tokenClaims["email"] = email
+	tokenClaims["purpose"] = purposeVerifyEmail
+	tokenClaims["exp"] = time.Now().Add(verificationTTL).Unix()
+	return i.sign(tokenClaims)
+}
+
+// ParseVerification returns the user ID and email address carried by a
+// token issued with NewVerification.
+func (i *Issuer) ParseVerification(tokenString string) (string, string, error) {
+	claims, err := i.parse(tokenString)
+	if err != nil {
+		return "", "", err
+	}
+	if claims["purpose"] != purposeVerifyEmail {
+		return "", "", errors.New("Invalid verification token")
+	}
+	id, _ := claims["id"].(string)
+	email, _ := claims["email"].(string)
+	if id == "" || email == "" {
+		return "", "", errors.New("Invalid verification token")
+	}
+	return id, email, nil
+}
+
+// Challenge is the second step of a login by a user with two-factor
+// authentication enabled.
+type Challenge struct {
+	ID        string
+	UserID    string
+	ExpiresAt time.Time
+}
+
+// NewChallenge issues a short-lived token for a user who has passed the
+// password check but still has to present a second factor. Each challenge has
+// a unique ID so that it can be used only once.
+func (i *Issuer) NewChallenge(id string) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
 	tokenClaims := jwt.MapClaims{}
 	tokenClaims["id"] = id
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	tokenClaims["jti"] = jti
+	tokenClaims["purpose"] = purposeTwoFactor
+	tokenClaims["exp"] = time.Now().Add(challengeTTL).Unix()
+	return i.sign(tokenClaims)
+}
+
+// ParseChallenge returns the challenge carried by a token issued with
+// NewChallenge.
+func (i *Issuer) ParseChallenge(tokenString string) (*Challenge, error) {
+	claims, err := i.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Challenge)
+	c.ID, _ = claims["jti"].(string)
+	c.UserID, _ = claims["id"].(string)
+	exp, _ := claims["exp"].(float64)
+	if claims["purpose"] != purposeTwoFactor || c.ID == "" || c.UserID == "" || exp == 0 {
+		return nil, errors.New("Invalid challenge token")
+	}
+	c.ExpiresAt = time.Unix(int64(exp), 0)
+	return c, nil
+}
+
+// Invitation is an offer for the holder of Email to join a company.
+type Invitation struct {
+	CompanyID string
+	Email     string
+	Role      string
+}
+
+// NewInvitation issues a token that lets the owner of inv.Email join
+// inv.CompanyID with inv.Role.
+func (i *Issuer) NewInvitation(inv Invitation) (string, error) {
+	tokenClaims := jwt.MapClaims{}
+	tokenClaims["company_id"] = inv.CompanyID
+	tokenClaims["email"] = inv.Email
+	tokenClaims["role"] = inv.Role
+	tokenClaims["purpose"] = purposeInvitation
+	tokenClaims["exp"] = time.Now().Add(invitationTTL).Unix()
+	return i.sign(tokenClaims)
+}
+
+// ParseInvitation returns the invitation carried by a token issued with
+// NewInvitation.
+func (i *Issuer) ParseInvitation(tokenString string) (*Invitation, error) {
+	claims, err := i.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	inv := new(Invitation)
+	inv.CompanyID, _ = claims["company_id"].(string)
+	inv.Email, _ = claims["email"].(string)
+	inv.Role, _ = claims["role"].(string)
+	if claims["purpose"] != purposeInvitation || inv.CompanyID == "" || inv.Email == "" || inv.Role == "" {
+		return nil, errors.New("Invalid invitation token")
+	}
+	return inv, nil
+}
+
+// OIDCState is what a single sign-on callback needs to know about the login
+// it completes.
+type OIDCState struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// NewOIDCState issues a short-lived token binding a single sign-on login to
+// the browser that started it.
+func (i *Issuer) NewOIDCState(state OIDCState) (string, error) {
+	tokenClaims := jwt.MapClaims{}
+	tokenClaims["provider"] = state.Provider
+	tokenClaims["state"] = state.State
+	tokenClaims["nonce"] = state.Nonce
+	tokenClaims["verifier"] = state.Verifier
+	tokenClaims["purpose"] = purposeOIDCState
+	tokenClaims["exp"] = time.Now().Add(oidcStateTTL).Unix()
+	return i.sign(tokenClaims)
+}
+
+// ParseOIDCState returns the login state carried by a token issued with
+// NewOIDCState.
+func (i *Issuer) ParseOIDCState(tokenString string) (*OIDCState, error) {
+	claims, err := i.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	state := new(OIDCState)
+	state.Provider, _ = claims["provider"].(string)
+	state.State, _ = claims["state"].(string)
+	state.Nonce, _ = claims["nonce"].(string)
+	state.Verifier, _ = claims["verifier"].(string)
+	if claims["purpose"] != purposeOIDCState || state.Provider == "" || state.State == "" || state.Verifier == "" {
+		return nil, errors.New("Invalid login state")
+	}
+	return state, nil
+}
+
+// Stream lets a user follow the changes of a company from clients that
+// cannot send headers, such as EventSource, which pass it in the URL
+// instead.
+type Stream struct {
+	UserID    string
+	CompanyID string
+}
+
+// NewStream issues a short-lived token for s and returns when it expires.
+// It only grants streaming the company's changes, so a token leaked through
+// a URL in a log is of little use.
+func (i *Issuer) NewStream(s Stream) (string, time.Time, error) {
+	exp := time.Now().Add(streamTTL)
+	tokenClaims := jwt.MapClaims{}
+	tokenClaims["id"] = s.UserID
+	tokenClaims["company_id"] = s.CompanyID
+	tokenClaims["purpose"] = purposeStream
+	tokenClaims["exp"] = exp.Unix()
+	token, err := i.sign(tokenClaims)
+	return token, exp, err
+}
+
+// ParseStream returns the stream carried by a token issued with NewStream.
+func (i *Issuer) ParseStream(tokenString string) (*Stream, error) {
+	claims, err := i.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	s := new(Stream)
+	s.UserID, _ = claims["id"].(string)
+	s.CompanyID, _ = claims["company_id"].(string)
+	if claims["purpose"] != purposeStream || s.UserID == "" || s.CompanyID == "" || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("Invalid stream token")
+	}
+	return s, nil
+}
+
+// JWKS returns the public keys of the issuer, ordered by key ID.
+func (i *Issuer) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range i.keys {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	sort.Slice(set.Keys, func(a, b int) bool {
+		return set.Keys[a].KeyID < set.Keys[b].KeyID
+	})
+	return set
+}
+
+// UserID returns the ID of the user authenticated by Validation or
+// StreamValidation.
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+// StreamCompanyID returns the company of the stream token authenticated by
+// StreamValidation, or "" when the request was authenticated otherwise.
+func StreamCompanyID(ctx context.Context) string {
+	id, _ := ctx.Value(streamCompanyIDKey).(string)
+	return id
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (i *Issuer) sign(claims jwt.MapClaims) (string, error) {
+	claims["iss"] = issuerName
+	token := jwt.NewWithClaims(i.signing.Method, claims)
+	token.Header["kid"] = i.signing.ID
+	return token.SignedString(i.signing.Private)
+}
+
+func (i *Issuer) parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := i.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("Unknown key ID: %v", t.Header["kid"])
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
+		}
+		return key.Public, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Invalid token")
+	}
+	return claims, nil
 }
 
 func extractToken(r *http.Request) string {
@@ -26,23 +348,23 @@ func extractToken(r *http.Request) string {
 	return ""
 }
 
-func verifyToken(r *http.Request) (*jwt.Token, error) {
-	tokenString := extractToken(r)
-	return jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-}
-
-func tokenValid(r *http.Request) error {
-	token, err := verifyToken(r)
+func (i *Issuer) verifyToken(r *http.Request) (string, error) {
+	claims, err := i.parse(extractToken(r))
 	if err != nil {
-		return err
+		return "", err
+	}
+	if _, ok := claims["purpose"]; ok {
+		return "", errors.New("Invalid token")
+	}
+	// parse only checks exp and iat when they are present, but sessions
+	// must carry both.
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuedAt(now, true) || !claims.VerifyIssuer(issuerName, true) {
+		return "", errors.New("Invalid token")
 	}
-	if _, ok := token.Claims.(jwt.Claims); !ok && !token.Valid {
-		return errors.New("Invalid token")
+	id, _ := claims["id"].(string)
+	if id == "" {
+		return "", errors.New("Invalid token")
 	}
-	return nil
+	return id, nil
 }
@@ -0,0 +1,227 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func writeKey(t *testing.T, dir, id string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err = os.WriteFile(filepath.Join(dir, id+".pem"), data, 0600); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+}
+
+func testKeysDir(t *testing.T) string {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	writeKey(t, dir, "2022-01", rsaKey)
+	writeKey(t, dir, "2022-02", edKey)
+	return dir
+}
+
+func TestLoadIssuer(t *testing.T) {
+	dir := testKeysDir(t)
+	for _, kid := range []string{"2022-01", "2022-02"} {
+		issuer, err := LoadIssuer(dir, kid)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		token, err := issuer.New("user-id")
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		id, err := issuer.verifyToken(r)
+		if err != nil {
+			t.Fatalf("Error: %s: %s", kid, err.Error())
+		}
+		if id != "user-id" {
+			t.Errorf("Error: ID inconsistency: %s - %s", id, "user-id")
+		}
+	}
+	if _, err := LoadIssuer(dir, "missing"); err == nil {
+		t.Error("Error: Expected an error for a missing signing key")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := testKeysDir(t)
+	previous, err := LoadIssuer(dir, "2022-01")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	token, err := previous.New("user-id")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	current, err := LoadIssuer(dir, "2022-02")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err = current.parse(token); err != nil {
+		t.Errorf("Error: Token signed with a previous key rejected: %s", err.Error())
+	}
+
+	if err = os.Remove(filepath.Join(dir, "2022-01.pem")); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	retired, err := LoadIssuer(dir, "2022-02")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if _, err = retired.parse(token); err == nil {
+		t.Error("Error: Token signed with a retired key accepted")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	issuer, err := LoadIssuer(testKeysDir(t), "2022-02")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	set := issuer.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("Error: %s - %d", "Wrong number of keys", len(set.Keys))
+	}
+	if set.Keys[0].KeyID != "2022-01" || set.Keys[0].KeyType != "RSA" || set.Keys[0].Algorithm != "RS256" || set.Keys[0].N == "" {
+		t.Errorf("Error: Unexpected RSA key: %v", set.Keys[0])
+	}
+	if set.Keys[1].KeyID != "2022-02" || set.Keys[1].KeyType != "OKP" || set.Keys[1].Algorithm != "EdDSA" || set.Keys[1].X == "" {
+		t.Errorf("Error: Unexpected Ed25519 key: %v", set.Keys[1])
+	}
+}
+
+func TestValidation(t *testing.T) {
+	issuer, err := NewEphemeralIssuer()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	var userID string
+	handler := issuer.Validation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID = UserID(r.Context())
+	}))
+
+	signed := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(issuer.signing.Method, claims)
+		token.Header["kid"] = issuer.signing.ID
+		s, err := token.SignedString(issuer.signing.Private)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		return s
+	}
+	now := time.Now()
+	token, _ := issuer.New("user-id")
+	challenge, _ := issuer.NewChallenge("user-id")
+	cases := map[string]int{
+		token:                                  http.StatusOK,
+		challenge:                              http.StatusUnauthorized,
+		"invalid":                              http.StatusUnauthorized,
+		signed(jwt.MapClaims{"id": "user-id"}): http.StatusUnauthorized,
+		signed(jwt.MapClaims{"id": "user-id", "iss": issuerName, "iat": now.Add(-2 * sessionTTL).Unix(), "exp": now.Add(-sessionTTL).Unix()}): http.StatusUnauthorized,
+		signed(jwt.MapClaims{"id": "user-id", "iss": "other", "iat": now.Unix(), "exp": now.Add(sessionTTL).Unix()}):                          http.StatusUnauthorized,
+	}
+	for bearer, status := range cases {
+		userID = ""
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+bearer)
+		handler.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("Error: Expected status %d: %d", status, w.Code)
+		}
+		if status == http.StatusOK && userID != "user-id" {
+			t.Errorf("Error: ID inconsistency: %s - %s", userID, "user-id")
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	issuer, err := NewEphemeralIssuer()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	first, _ := issuer.NewChallenge("user-id")
+	second, _ := issuer.NewChallenge("user-id")
+	a, err := issuer.ParseChallenge(first)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	b, err := issuer.ParseChallenge(second)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if a.UserID != "user-id" || a.ExpiresAt.Before(time.Now()) {
+		t.Errorf("Error: Challenge inconsistency: %+v", a)
+	}
+	if a.ID == b.ID {
+		t.Error("Error: Challenges share an ID")
+	}
+	token, _ := issuer.New("user-id")
+	if _, err = issuer.ParseChallenge(token); err == nil {
+		t.Error("Error: Session token accepted as a challenge")
+	}
+}
+
+func TestStream(t *testing.T) {
+	issuer, err := NewEphemeralIssuer()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	token, exp, err := issuer.NewStream(Stream{UserID: "user-id", CompanyID: "company-id"})
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if exp.Before(time.Now()) || exp.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Error: Expected a short-lived token: %s", exp)
+	}
+
+	var userID, companyID string
+	handler := issuer.StreamValidation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, companyID = UserID(r.Context()), StreamCompanyID(r.Context())
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?token="+token, nil))
+	if w.Code != http.StatusOK || userID != "user-id" || companyID != "company-id" {
+		t.Errorf("Error: Expected the stream token to be accepted: %d %s %s", w.Code, userID, companyID)
+	}
+
+	session, _ := issuer.New("user-id")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?token="+session, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Error: Session token accepted as a stream token: %d", w.Code)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	issuer.Validation(handler).ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Error: Stream token accepted as a session: %d", w.Code)
+	}
+}
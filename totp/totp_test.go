@@ -0,0 +1,69 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// Test vectors from RFC 6238 Appendix B, truncated to 6 digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := Code(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		if code != expected {
+			t.Errorf("Error: Code inconsistency at %d: %s - %s", unix, code, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	now := time.Now()
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !Validate(code, secret, now) {
+		t.Error("Error: Current code rejected")
+	}
+	if !Validate(code, secret, now.Add(period*time.Second)) {
+		t.Error("Error: Code from the previous step rejected")
+	}
+	if Validate(code, secret, now.Add(3*period*time.Second)) {
+		t.Error("Error: Expired code accepted")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	step, ok := Match(code, secret, now.Add(period*time.Second))
+	if !ok {
+		t.Fatal("Error: Code from the previous step rejected")
+	}
+	if step != now.Unix()/period {
+		t.Errorf("Error: Step inconsistency: %d - %d", step, now.Unix()/period)
+	}
+}
@@ -0,0 +1,49 @@
+// Package tracing exports the OpenTelemetry traces of the API.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// Exporters accepted by NewProvider.
+const (
+	None   = "none"
+	OTLP   = "otlp"
+	Stdout = "stdout"
+)
+
+// ServiceName identifies the API in exported spans.
+const ServiceName = "proddx-api"
+
+// NewProvider returns a tracer provider that exports spans in batches with
+// exporter: OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_*
+// variables, or JSON written to w for Stdout. Spans are still created but
+// discarded for None or an empty exporter, so that trace IDs are logged and
+// propagated. Shut the provider down to flush the remaining spans.
+func NewProvider(ctx context.Context, exporter string, w io.Writer) (*sdktrace.TracerProvider, error) {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", None:
+		return sdktrace.NewTracerProvider(sdktrace.WithResource(res)), nil
+	case OTLP:
+		exp, err = otlptracehttp.New(ctx)
+	case Stdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("Unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res)), nil
+}
@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewProvider(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	tp, err := NewProvider(ctx, Stdout, &buf)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	_, span := tp.Tracer("test").Start(ctx, "Exported")
+	span.End()
+	if err = tp.Shutdown(ctx); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if !strings.Contains(buf.String(), `"Name":"Exported"`) || !strings.Contains(buf.String(), ServiceName) {
+		t.Errorf("Error: Expected the span to be exported: %s", buf.String())
+	}
+
+	for _, exporter := range []string{"", None} {
+		tp, err := NewProvider(ctx, exporter, nil)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+		_, span := tp.Tracer("test").Start(ctx, "Discarded")
+		if !span.SpanContext().IsValid() {
+			t.Errorf("Error: Expected a valid span context without an exporter")
+		}
+		span.End()
+	}
+
+	if _, err := NewProvider(ctx, "zipkin", nil); err == nil {
+		t.Errorf("Error: Expected an unknown exporter to be rejected")
+	}
+}
@@ -0,0 +1,181 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/storage"
+)
+
+// Defaults of a Dispatcher. With these a delivery is attempted for about a
+// day before it is marked as failed.
+const (
+	PollInterval = 5 * time.Second
+	MaxAttempts  = 10
+	BaseBackoff  = 30 * time.Second
+	MaxBackoff   = 6 * time.Hour
+
+	// deliveryTimeout bounds each attempt, and the lease on claimed
+	// deliveries outlasts it so that no other dispatcher sends them
+	// meanwhile. A batch is attempted at once, so the lease covers all of
+	// it.
+	deliveryTimeout = 10 * time.Second
+	claimLease      = 2 * deliveryTimeout
+	batchSize       = 20
+	userAgent       = "Proddx-Webhooks/1.0"
+)
+
+// Dispatcher sends queued deliveries and schedules retries with exponential
+// backoff until an endpoint responds with a 2xx status or MaxAttempts have
+// been made. Several dispatchers may share the same queue.
+type Dispatcher struct {
+	webhooks   storage.Webhook
+	deliveries storage.WebhookDelivery
+	client     *http.Client
+	logger     *logging.Logger
+
+	interval    time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	now         func() time.Time
+}
+
+func NewDispatcher(ws storage.Webhook, ds storage.WebhookDelivery, logger *logging.Logger) *Dispatcher {
+	return &Dispatcher{
+		webhooks:    ws,
+		deliveries:  ds,
+		client:      NewClient(deliveryTimeout),
+		logger:      logger,
+		interval:    PollInterval,
+		maxAttempts: MaxAttempts,
+		backoff:     BaseBackoff,
+		maxBackoff:  MaxBackoff,
+		now:         time.Now,
+	}
+}
+
+// Run sends due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				d.logger.Error("Webhook dispatch error", "error", err)
+			}
+			if n < batchSize || err != nil {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch claims a batch of due deliveries, attempts each once and returns
+// how many it claimed. The deliveries of a batch are attempted concurrently,
+// so that all of them are done before their lease runs out.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	claimed, err := d.deliveries.Claim(ctx, d.now(), claimLease, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	errs := make([]error, len(claimed))
+	var wg sync.WaitGroup
+	for i := range claimed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = d.attempt(ctx, &claimed[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// attempt sends a delivery and saves its outcome. Only errors saving it are
+// returned, since failed sends are recorded in the delivery.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *storage.WebhookDeliveryModel) error {
+	hook, err := d.webhooks.Find(ctx, delivery.CompanyID.String(), delivery.WebhookID.String())
+	now := d.now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = 0
+	delivery.LastError = ""
+	if err != nil {
+		// The endpoint was removed after the delivery was queued.
+		delivery.Status = storage.DeliveryFailed
+		delivery.LastError = "webhook not found"
+		return d.deliveries.Save(ctx, delivery)
+	}
+
+	status, err := d.send(ctx, hook, delivery, now)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = storage.DeliverySucceeded
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = storage.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.retryAfter(delivery.Attempts))
+	}
+	if err != nil {
+		d.logger.Warn("Webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", hook.ID, "attempts", delivery.Attempts, "error", err)
+	}
+	return d.deliveries.Save(ctx, delivery)
+}
+
+func (d *Dispatcher) send(ctx context.Context, hook *storage.WebhookModel, delivery *storage.WebhookDeliveryModel, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, now, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryAfter doubles the backoff with every failed attempt, up to the
+// maximum.
+func (d *Dispatcher) retryAfter(attempts int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.maxBackoff {
+		backoff = d.maxBackoff
+	}
+	return backoff
+}
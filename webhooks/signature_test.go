@@ -0,0 +1,33 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"review.created"}`)
+	now := time.Now()
+	header := Sign("whsec_secret", now, body)
+
+	if err := Verify("whsec_secret", header, body, now, DefaultTolerance); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := Verify("whsec_other", header, body, now, DefaultTolerance); err != ErrInvalidSignature {
+		t.Errorf("Expected a signature with another secret to be invalid: %v", err)
+	}
+	if err := Verify("whsec_secret", header, []byte(`{"type":"review.deleted"}`), now, DefaultTolerance); err != ErrInvalidSignature {
+		t.Errorf("Expected a signature of another body to be invalid: %v", err)
+	}
+	if err := Verify("whsec_secret", header, body, now.Add(time.Hour), DefaultTolerance); err != ErrExpiredSignature {
+		t.Errorf("Expected an old signature to be expired: %v", err)
+	}
+	if err := Verify("whsec_secret", "v1=abc", body, now, DefaultTolerance); err != ErrInvalidSignature {
+		t.Errorf("Expected a signature without timestamp to be invalid: %v", err)
+	}
+	// Receivers accept any of several signatures, for rotating secrets.
+	rotated := header + ",v1=" + Sign("whsec_old", now, body)[len("t=0000000000,v1="):]
+	if err := Verify("whsec_secret", rotated, body, now, DefaultTolerance); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+}
@@ -0,0 +1,128 @@
+// Package webhooks notifies the endpoints companies register of events on
+// their reviews and products. A Publisher queues deliveries of the events it
+// receives from the outbox, and a Dispatcher sends them in the background,
+// so that a slow or failing endpoint holds up neither requests nor other
+// sinks.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"api.proddx.com/outbox"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Event types endpoints can subscribe to.
+const (
+	ReviewCreated  = storage.EventReviewCreated
+	ReviewUpdated  = storage.EventReviewUpdated
+	ReviewDeleted  = storage.EventReviewDeleted
+	ProductCreated = storage.EventProductCreated
+	ProductUpdated = storage.EventProductUpdated
+	ProductDeleted = storage.EventProductDeleted
+)
+
+// Events lists every event type.
+var Events = []string{ReviewCreated, ReviewUpdated, ReviewDeleted, ProductCreated, ProductUpdated, ProductDeleted}
+
+const secretPrefix = "whsec_"
+
+// Known reports whether eventType is one of Events.
+func Known(eventType string) bool {
+	for _, known := range Events {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateSecret returns a new secret for signing the deliveries of an
+// endpoint.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Publisher is the outbox sink that queues a delivery of each event to every
+// endpoint of the company that subscribed to its type. The body of a
+// delivery is the outbox.Event, whose ID redeliveries keep, so receivers can
+// discard events they have already handled.
+type Publisher struct {
+	webhooks   storage.Webhook
+	deliveries storage.WebhookDelivery
+}
+
+func NewPublisher(ws storage.Webhook, ds storage.WebhookDelivery) *Publisher {
+	return &Publisher{webhooks: ws, deliveries: ds}
+}
+
+// Publish queues deliveries of an event. The outbox retries an event when
+// queueing fails, which may queue it twice for some endpoints.
+func (p *Publisher) Publish(ctx context.Context, event outbox.Event) error {
+	hooks, err := p.webhooks.List(ctx, event.CompanyID)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, hook := range hooks {
+		if !subscribed(hook.Events, event.Type) {
+			continue
+		}
+		err = p.deliveries.Save(ctx, &storage.WebhookDeliveryModel{
+			ID:            uuid.NewV4(),
+			WebhookID:     hook.ID,
+			CompanyID:     hook.CompanyID,
+			Event:         event.Type,
+			Payload:       payload,
+			Status:        storage.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redeliver queues a new delivery of the event of an earlier one, whatever
+// its outcome.
+func (p *Publisher) Redeliver(ctx context.Context, delivery *storage.WebhookDeliveryModel) (*storage.WebhookDeliveryModel, error) {
+	now := time.Now()
+	model := &storage.WebhookDeliveryModel{
+		ID:            uuid.NewV4(),
+		WebhookID:     delivery.WebhookID,
+		CompanyID:     delivery.CompanyID,
+		Event:         delivery.Event,
+		Payload:       delivery.Payload,
+		Status:        storage.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := p.deliveries.Save(ctx, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func subscribed(events []string, eventType string) bool {
+	for _, event := range events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
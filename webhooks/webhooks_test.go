@@ -0,0 +1,271 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"api.proddx.com/logging"
+	"api.proddx.com/outbox"
+	"api.proddx.com/storage"
+	uuid "github.com/satori/go.uuid"
+)
+
+// receiver records the deliveries it is sent and responds with the
+// statuses it is given, then with 204 No Content.
+type receiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []*http.Request
+	bodies     [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.deliveries = append(rc.deliveries, r)
+	rc.bodies = append(rc.bodies, body)
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func testEvent(companyID string, eventType string) outbox.Event {
+	return outbox.Event{
+		ID:        uuid.NewV4().String(),
+		Type:      eventType,
+		CompanyID: companyID,
+		CreatedAt: time.Now(),
+		Data:      json.RawMessage(`{"id":"1","rating":5}`),
+	}
+}
+
+func testDispatcher(t *testing.T, srv *httptest.Server, statuses ...int) (*Publisher, *Dispatcher, *storage.WebhookDeliveryMemoryStore, *storage.WebhookModel) {
+	t.Helper()
+	ws := new(storage.WebhookMemoryStore)
+	ds := new(storage.WebhookDeliveryMemoryStore)
+	hook := &storage.WebhookModel{
+		ID:        uuid.NewV4(),
+		CompanyID: uuid.NewV4(),
+		URL:       srv.URL,
+		Secret:    "whsec_secret",
+		Events:    []string{ReviewCreated},
+		CreatedAt: time.Now(),
+	}
+	if err := ws.Save(context.Background(), hook); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	logger, _ := logging.New(ioutil.Discard, logging.Logfmt, logging.Error)
+	d := NewDispatcher(ws, ds, logger)
+	d.client = srv.Client()
+	return NewPublisher(ws, ds), d, ds, hook
+}
+
+func TestDispatch(t *testing.T) {
+	rc := new(receiver)
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+	p, d, ds, hook := testDispatcher(t, srv)
+
+	published := testEvent(hook.CompanyID.String(), ReviewCreated)
+	if err := p.Publish(context.Background(), published); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := p.Publish(context.Background(), testEvent(hook.CompanyID.String(), ProductCreated)); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if err := p.Publish(context.Background(), testEvent(uuid.NewV4().String(), ReviewCreated)); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if n, err := d.Dispatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected one delivery for the subscribed event: %d - %v", n, err)
+	}
+
+	if len(rc.deliveries) != 1 {
+		t.Fatalf("Expected one delivery: %d", len(rc.deliveries))
+	}
+	r, body := rc.deliveries[0], rc.bodies[0]
+	if err := Verify(hook.Secret, r.Header.Get(SignatureHeader), body, time.Now(), DefaultTolerance); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+	if r.Header.Get(EventHeader) != ReviewCreated || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Error: Unexpected headers: %v", r.Header)
+	}
+	var event outbox.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if event.ID != published.ID || event.Type != ReviewCreated || string(event.Data) != string(published.Data) {
+		t.Errorf("Error: Unexpected event: %v", event)
+	}
+	records, _ := ds.List(context.Background(), hook.ID.String())
+	if len(records) != 1 || records[0].Status != storage.DeliverySucceeded || records[0].ResponseStatus != http.StatusNoContent {
+		t.Errorf("Error: Unexpected delivery log: %v", records)
+	}
+	if records[0].ID.String() != r.Header.Get(DeliveryHeader) {
+		t.Errorf("Error: Delivery ID inconsistency: %s - %s", records[0].ID, r.Header.Get(DeliveryHeader))
+	}
+}
+
+func TestDispatchConcurrently(t *testing.T) {
+	// The endpoint responds only once the whole batch is in flight, so
+	// deliveries sent one after another would time out.
+	var mu sync.Mutex
+	inFlight := 0
+	all := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if inFlight++; inFlight == batchSize {
+			close(all)
+		}
+		mu.Unlock()
+		select {
+		case <-all:
+			w.WriteHeader(http.StatusNoContent)
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	p, d, ds, hook := testDispatcher(t, srv)
+
+	for i := 0; i < batchSize; i++ {
+		if err := p.Publish(context.Background(), testEvent(hook.CompanyID.String(), ReviewCreated)); err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+	}
+	if n, err := d.Dispatch(context.Background()); err != nil || n != batchSize {
+		t.Fatalf("Expected a full batch to be claimed: %d - %v", n, err)
+	}
+	records, _ := ds.List(context.Background(), hook.ID.String())
+	for _, record := range records {
+		if record.Status != storage.DeliverySucceeded {
+			t.Fatalf("Error: Expected the batch to be delivered concurrently: %v", record)
+		}
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+	p, d, ds, hook := testDispatcher(t, srv)
+
+	if err := p.Publish(context.Background(), testEvent(hook.CompanyID.String(), ReviewCreated)); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	d.Dispatch(context.Background())
+	records, _ := ds.List(context.Background(), hook.ID.String())
+	if records[0].Status != storage.DeliveryPending || records[0].ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("Error: Expected the delivery to be retried: %v", records[0])
+	}
+	if !records[0].NextAttemptAt.Equal(now.Add(BaseBackoff)) {
+		t.Errorf("Error: Unexpected retry: %s", records[0].NextAttemptAt)
+	}
+	if n, _ := d.Dispatch(context.Background()); n != 0 {
+		t.Errorf("Error: Delivery was retried before its backoff: %d", n)
+	}
+
+	now = now.Add(BaseBackoff)
+	d.Dispatch(context.Background())
+	records, _ = ds.List(context.Background(), hook.ID.String())
+	if !records[0].NextAttemptAt.Equal(now.Add(2 * BaseBackoff)) {
+		t.Errorf("Error: Expected the backoff to double: %s", records[0].NextAttemptAt)
+	}
+
+	now = now.Add(2 * BaseBackoff)
+	d.Dispatch(context.Background())
+	records, _ = ds.List(context.Background(), hook.ID.String())
+	if records[0].Status != storage.DeliverySucceeded || records[0].Attempts != 3 || len(rc.deliveries) != 3 {
+		t.Errorf("Error: Expected the third attempt to succeed: %v", records[0])
+	}
+}
+
+func TestDispatchGivesUp(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusGone, http.StatusGone}}
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+	p, d, ds, hook := testDispatcher(t, srv)
+	d.maxAttempts = 2
+	d.backoff = 0
+
+	if err := p.Publish(context.Background(), testEvent(hook.CompanyID.String(), ReviewCreated)); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	d.Dispatch(context.Background())
+	d.Dispatch(context.Background())
+	records, _ := ds.List(context.Background(), hook.ID.String())
+	if records[0].Status != storage.DeliveryFailed || records[0].LastError == "" {
+		t.Fatalf("Error: Expected the delivery to fail: %v", records[0])
+	}
+
+	redelivery, err := p.Redeliver(context.Background(), &records[0])
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	d.Dispatch(context.Background())
+	record, _ := ds.Find(context.Background(), hook.ID.String(), redelivery.ID.String())
+	if record.Status != storage.DeliverySucceeded || string(rc.bodies[2]) != string(records[0].Payload) {
+		t.Errorf("Error: Expected the event to be redelivered: %v", record)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	d := NewDispatcher(nil, nil, nil)
+	if d.retryAfter(1) != BaseBackoff || d.retryAfter(3) != 4*BaseBackoff {
+		t.Errorf("Error: Unexpected backoff: %s - %s", d.retryAfter(1), d.retryAfter(3))
+	}
+	if d.retryAfter(MaxAttempts*10) != MaxBackoff {
+		t.Errorf("Error: Backoff exceeds maximum: %s", d.retryAfter(MaxAttempts*10))
+	}
+}
+
+func TestRoutable(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"64:ff9b::a01:203":     false,
+	}
+	for address, expected := range cases {
+		if Routable(net.ParseIP(address)) != expected {
+			t.Errorf("Error: Expected %s to be routable: %t", address, expected)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	rc := new(receiver)
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+
+	_, err := NewClient(time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Error: Expected a loopback address to be refused: %v", err)
+	}
+	if len(rc.bodies) != 0 {
+		t.Error("Error: The request reached the server")
+	}
+}